@@ -5,6 +5,7 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
@@ -13,6 +14,8 @@ import (
 
 	"github.com/mcyprian/postgresql-operator/pkg/apis"
 	"github.com/mcyprian/postgresql-operator/pkg/controller"
+	"github.com/mcyprian/postgresql-operator/pkg/k8shandler"
+	"github.com/mcyprian/postgresql-operator/pkg/webhook"
 
 	"github.com/operator-framework/operator-sdk/pkg/k8sutil"
 	kubemetrics "github.com/operator-framework/operator-sdk/pkg/kube-metrics"
@@ -38,6 +41,11 @@ var (
 )
 var log = logf.Log.WithName("cmd")
 
+var (
+	webhookPort    = pflag.Int("webhook-port", 9443, "Port the conversion webhook of the CRD is served at")
+	webhookCertDir = pflag.String("webhook-cert-dir", "/etc/webhook/certs", "Directory with tls.crt and tls.key of the webhook server")
+)
+
 func printVersion() {
 	log.Info(fmt.Sprintf("Go Version: %s", runtime.Version()))
 	log.Info(fmt.Sprintf("Go OS/Arch: %s/%s", runtime.GOOS, runtime.GOARCH))
@@ -67,6 +75,11 @@ func main() {
 
 	printVersion()
 
+	if err := k8shandler.LoadDefaults(); err != nil {
+		log.Error(err, "Failed to load defaults of PostgreSQL nodes")
+		os.Exit(1)
+	}
+
 	namespace, err := k8sutil.GetWatchNamespace()
 	if err != nil {
 		log.Error(err, "Failed to get watch namespace")
@@ -113,6 +126,16 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Serve conversion webhook between versions of the API, if its certificate is mounted
+	if _, err := os.Stat(filepath.Join(*webhookCertDir, "tls.crt")); err == nil {
+		if err := mgr.Add(&webhook.Server{Port: *webhookPort, CertDir: *webhookCertDir}); err != nil {
+			log.Error(err, "")
+			os.Exit(1)
+		}
+	} else {
+		log.Info("Conversion webhook disabled, certificate not found", "dir", *webhookCertDir)
+	}
+
 	if err = serveCRMetrics(cfg); err != nil {
 		log.Info("Could not generate and serve custom resource metrics", "error", err.Error())
 	}
@@ -1,12 +1,15 @@
 module github.com/mcyprian/postgresql-operator
 
 require (
+	github.com/google/gofuzz v1.0.0
 	github.com/lib/pq v1.2.0
 	github.com/operator-framework/operator-sdk v0.10.1-0.20190820174346-abac23c897b8
+	github.com/prometheus/client_golang v0.9.3-0.20190127221311-3c4408c8b829
 	github.com/sethvargo/go-password v0.1.2
 	github.com/sirupsen/logrus v1.4.1
 	github.com/spf13/pflag v1.0.3
 	k8s.io/api v0.0.0-20190612125737-db0771252981
+	k8s.io/apiextensions-apiserver v0.0.0-20190228180357-d002e88f6236
 	k8s.io/apimachinery v0.0.0-20190612125636-6a5db36e93ad
 	k8s.io/client-go v11.0.0+incompatible
 	k8s.io/kube-openapi v0.0.0-20190603182131-db7b694dc208 // indirect
@@ -5,6 +5,8 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -36,6 +38,241 @@ func (in *PostgreSQL) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLBootstrapSpec) DeepCopyInto(out *PostgreSQLBootstrapSpec) {
+	*out = *in
+	if in.Import != nil {
+		in, out := &in.Import, &out.Import
+		*out = new(PostgreSQLImportSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLBootstrapSpec.
+func (in *PostgreSQLBootstrapSpec) DeepCopy() *PostgreSQLBootstrapSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLBootstrapSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLCondition) DeepCopyInto(out *PostgreSQLCondition) {
+	*out = *in
+	in.LastTransitionTime.DeepCopyInto(&out.LastTransitionTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLCondition.
+func (in *PostgreSQLCondition) DeepCopy() *PostgreSQLCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLCredentialsSpec) DeepCopyInto(out *PostgreSQLCredentialsSpec) {
+	*out = *in
+	if in.RotationPeriod != nil {
+		in, out := &in.RotationPeriod, &out.RotationPeriod
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Vault != nil {
+		in, out := &in.Vault, &out.Vault
+		*out = new(PostgreSQLVaultSpec)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLCredentialsSpec.
+func (in *PostgreSQLCredentialsSpec) DeepCopy() *PostgreSQLCredentialsSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLCredentialsSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLCredentialsStatus) DeepCopyInto(out *PostgreSQLCredentialsStatus) {
+	*out = *in
+	if in.LastRotation != nil {
+		in, out := &in.LastRotation, &out.LastRotation
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLCredentialsStatus.
+func (in *PostgreSQLCredentialsStatus) DeepCopy() *PostgreSQLCredentialsStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLCredentialsStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLDump) DeepCopyInto(out *PostgreSQLDump) {
+	*out = *in
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	in.Destination.DeepCopyInto(&out.Destination)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLDump.
+func (in *PostgreSQLDump) DeepCopy() *PostgreSQLDump {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLDump)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLDumpDestination) DeepCopyInto(out *PostgreSQLDumpDestination) {
+	*out = *in
+	if in.Volume != nil {
+		in, out := &in.Volume, &out.Volume
+		*out = new(PostgreSQLStorageSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ObjectStore != nil {
+		in, out := &in.ObjectStore, &out.ObjectStore
+		*out = new(PostgreSQLObjectStore)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLDumpDestination.
+func (in *PostgreSQLDumpDestination) DeepCopy() *PostgreSQLDumpDestination {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLDumpDestination)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLDumpStatus) DeepCopyInto(out *PostgreSQLDumpStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastSuccessfulTime != nil {
+		in, out := &in.LastSuccessfulTime, &out.LastSuccessfulTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLDumpStatus.
+func (in *PostgreSQLDumpStatus) DeepCopy() *PostgreSQLDumpStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLDumpStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLHibernationStatus) DeepCopyInto(out *PostgreSQLHibernationStatus) {
+	*out = *in
+	in.Since.DeepCopyInto(&out.Since)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLHibernationStatus.
+func (in *PostgreSQLHibernationStatus) DeepCopy() *PostgreSQLHibernationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLHibernationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLImportSource) DeepCopyInto(out *PostgreSQLImportSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLImportSource.
+func (in *PostgreSQLImportSource) DeepCopy() *PostgreSQLImportSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLImportSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLImportSpec) DeepCopyInto(out *PostgreSQLImportSpec) {
+	*out = *in
+	out.Source = in.Source
+	if in.Databases != nil {
+		in, out := &in.Databases, &out.Databases
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLImportSpec.
+func (in *PostgreSQLImportSpec) DeepCopy() *PostgreSQLImportSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLImportSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLImportStatus) DeepCopyInto(out *PostgreSQLImportStatus) {
+	*out = *in
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLImportStatus.
+func (in *PostgreSQLImportStatus) DeepCopy() *PostgreSQLImportStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLImportStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgreSQLList) DeepCopyInto(out *PostgreSQLList) {
 	*out = *in
@@ -90,6 +327,11 @@ func (in *PostgreSQLNode) DeepCopy() *PostgreSQLNode {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgreSQLNodeStatus) DeepCopyInto(out *PostgreSQLNodeStatus) {
 	*out = *in
+	if in.Rejoin != nil {
+		in, out := &in.Rejoin, &out.Rejoin
+		*out = new(PostgreSQLRejoinStatus)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -103,9 +345,173 @@ func (in *PostgreSQLNodeStatus) DeepCopy() *PostgreSQLNodeStatus {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLNodeTemplate) DeepCopyInto(out *PostgreSQLNodeTemplate) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	in.Storage.DeepCopyInto(&out.Storage)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLNodeTemplate.
+func (in *PostgreSQLNodeTemplate) DeepCopy() *PostgreSQLNodeTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLNodeTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLObjectStore) DeepCopyInto(out *PostgreSQLObjectStore) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLObjectStore.
+func (in *PostgreSQLObjectStore) DeepCopy() *PostgreSQLObjectStore {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLObjectStore)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLPublication) DeepCopyInto(out *PostgreSQLPublication) {
+	*out = *in
+	if in.Tables != nil {
+		in, out := &in.Tables, &out.Tables
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLPublication.
+func (in *PostgreSQLPublication) DeepCopy() *PostgreSQLPublication {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLPublication)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLRejoinStatus) DeepCopyInto(out *PostgreSQLRejoinStatus) {
+	*out = *in
+	in.StartTime.DeepCopyInto(&out.StartTime)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLRejoinStatus.
+func (in *PostgreSQLRejoinStatus) DeepCopy() *PostgreSQLRejoinStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLRejoinStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLReplicaSource) DeepCopyInto(out *PostgreSQLReplicaSource) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLReplicaSource.
+func (in *PostgreSQLReplicaSource) DeepCopy() *PostgreSQLReplicaSource {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLReplicaSource)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLReplicaSpec) DeepCopyInto(out *PostgreSQLReplicaSpec) {
+	*out = *in
+	out.Source = in.Source
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLReplicaSpec.
+func (in *PostgreSQLReplicaSpec) DeepCopy() *PostgreSQLReplicaSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLReplicaSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLReplicaStatus) DeepCopyInto(out *PostgreSQLReplicaStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLReplicaStatus.
+func (in *PostgreSQLReplicaStatus) DeepCopy() *PostgreSQLReplicaStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLReplicaStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLReplicationSlotStatus) DeepCopyInto(out *PostgreSQLReplicationSlotStatus) {
+	*out = *in
+	out.RetainedWAL = in.RetainedWAL.DeepCopy()
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLReplicationSlotStatus.
+func (in *PostgreSQLReplicationSlotStatus) DeepCopy() *PostgreSQLReplicationSlotStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLReplicationSlotStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLReplicationSpec) DeepCopyInto(out *PostgreSQLReplicationSpec) {
+	*out = *in
+	if in.MaxSlotRetainedWAL != nil {
+		in, out := &in.MaxSlotRetainedWAL, &out.MaxSlotRetainedWAL
+		x := (*in).DeepCopy()
+		*out = &x
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLReplicationSpec.
+func (in *PostgreSQLReplicationSpec) DeepCopy() *PostgreSQLReplicationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLReplicationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *PostgreSQLSpec) DeepCopyInto(out *PostgreSQLSpec) {
 	*out = *in
+	if in.Template != nil {
+		in, out := &in.Template, &out.Template
+		*out = new(PostgreSQLNodeTemplate)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Nodes != nil {
 		in, out := &in.Nodes, &out.Nodes
 		*out = make(map[string]PostgreSQLNode, len(*in))
@@ -113,6 +519,62 @@ func (in *PostgreSQLSpec) DeepCopyInto(out *PostgreSQLSpec) {
 			(*out)[key] = *val.DeepCopy()
 		}
 	}
+	if in.Instances != nil {
+		in, out := &in.Instances, &out.Instances
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(PostgreSQLCredentialsSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.HealthCheckInterval != nil {
+		in, out := &in.HealthCheckInterval, &out.HealthCheckInterval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Replication != nil {
+		in, out := &in.Replication, &out.Replication
+		*out = new(PostgreSQLReplicationSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Witness != nil {
+		in, out := &in.Witness, &out.Witness
+		*out = new(PostgreSQLWitnessSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Replica != nil {
+		in, out := &in.Replica, &out.Replica
+		*out = new(PostgreSQLReplicaSpec)
+		**out = **in
+	}
+	if in.Bootstrap != nil {
+		in, out := &in.Bootstrap, &out.Bootstrap
+		*out = new(PostgreSQLBootstrapSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Publications != nil {
+		in, out := &in.Publications, &out.Publications
+		*out = make([]PostgreSQLPublication, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Subscriptions != nil {
+		in, out := &in.Subscriptions, &out.Subscriptions
+		*out = make([]PostgreSQLSubscription, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Dumps != nil {
+		in, out := &in.Dumps, &out.Dumps
+		*out = make([]PostgreSQLDump, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 	return
 }
 
@@ -133,7 +595,65 @@ func (in *PostgreSQLStatus) DeepCopyInto(out *PostgreSQLStatus) {
 		in, out := &in.Nodes, &out.Nodes
 		*out = make(map[string]PostgreSQLNodeStatus, len(*in))
 		for key, val := range *in {
-			(*out)[key] = val
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]PostgreSQLCondition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Hibernation != nil {
+		in, out := &in.Hibernation, &out.Hibernation
+		*out = new(PostgreSQLHibernationStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Credentials != nil {
+		in, out := &in.Credentials, &out.Credentials
+		*out = new(PostgreSQLCredentialsStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ReplicationSlots != nil {
+		in, out := &in.ReplicationSlots, &out.ReplicationSlots
+		*out = make(map[string]PostgreSQLReplicationSlotStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Witness != nil {
+		in, out := &in.Witness, &out.Witness
+		*out = new(PostgreSQLWitnessStatus)
+		**out = **in
+	}
+	if in.Replica != nil {
+		in, out := &in.Replica, &out.Replica
+		*out = new(PostgreSQLReplicaStatus)
+		**out = **in
+	}
+	if in.Import != nil {
+		in, out := &in.Import, &out.Import
+		*out = new(PostgreSQLImportStatus)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Publications != nil {
+		in, out := &in.Publications, &out.Publications
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Subscriptions != nil {
+		in, out := &in.Subscriptions, &out.Subscriptions
+		*out = make(map[string]PostgreSQLSubscriptionStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
+		}
+	}
+	if in.Dumps != nil {
+		in, out := &in.Dumps, &out.Dumps
+		*out = make(map[string]PostgreSQLDumpStatus, len(*in))
+		for key, val := range *in {
+			(*out)[key] = *val.DeepCopy()
 		}
 	}
 	return
@@ -174,3 +694,99 @@ func (in *PostgreSQLStorageSpec) DeepCopy() *PostgreSQLStorageSpec {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLSubscription) DeepCopyInto(out *PostgreSQLSubscription) {
+	*out = *in
+	if in.Publications != nil {
+		in, out := &in.Publications, &out.Publications
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.ConnectionSecret != nil {
+		in, out := &in.ConnectionSecret, &out.ConnectionSecret
+		*out = new(corev1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLSubscription.
+func (in *PostgreSQLSubscription) DeepCopy() *PostgreSQLSubscription {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLSubscription)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLSubscriptionStatus) DeepCopyInto(out *PostgreSQLSubscriptionStatus) {
+	*out = *in
+	if in.Lag != nil {
+		in, out := &in.Lag, &out.Lag
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLSubscriptionStatus.
+func (in *PostgreSQLSubscriptionStatus) DeepCopy() *PostgreSQLSubscriptionStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLSubscriptionStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLVaultSpec) DeepCopyInto(out *PostgreSQLVaultSpec) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLVaultSpec.
+func (in *PostgreSQLVaultSpec) DeepCopy() *PostgreSQLVaultSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLVaultSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLWitnessSpec) DeepCopyInto(out *PostgreSQLWitnessSpec) {
+	*out = *in
+	in.Resources.DeepCopyInto(&out.Resources)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLWitnessSpec.
+func (in *PostgreSQLWitnessSpec) DeepCopy() *PostgreSQLWitnessSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLWitnessSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PostgreSQLWitnessStatus) DeepCopyInto(out *PostgreSQLWitnessStatus) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PostgreSQLWitnessStatus.
+func (in *PostgreSQLWitnessStatus) DeepCopy() *PostgreSQLWitnessStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(PostgreSQLWitnessStatus)
+	in.DeepCopyInto(out)
+	return out
+}
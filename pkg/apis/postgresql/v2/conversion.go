@@ -0,0 +1,550 @@
+package v2
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// ConvertTo converts the cluster to v1, the storage version of the API
+func (src *PostgreSQL) ConvertTo(dst *v1.PostgreSQL) error {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	dst.Spec = v1.PostgreSQLSpec{
+		ManagementState: v1.ManagementState(src.Spec.ManagementState),
+	}
+	if src.Spec.HealthCheckInterval != nil {
+		interval := *src.Spec.HealthCheckInterval
+		dst.Spec.HealthCheckInterval = &interval
+	}
+	if src.Spec.Instances != nil {
+		instances := *src.Spec.Instances
+		dst.Spec.Instances = &instances
+	}
+	if src.Spec.Credentials != nil {
+		dst.Spec.Credentials = convertCredentialsTo(src.Spec.Credentials)
+	}
+	if src.Spec.Replication != nil {
+		replication := src.Spec.Replication.DeepCopy()
+		dst.Spec.Replication = &v1.PostgreSQLReplicationSpec{
+			UseSlots:           replication.UseSlots,
+			MaxSlotRetainedWAL: replication.MaxSlotRetainedWAL,
+			SlotLimitAction:    v1.SlotLimitAction(replication.SlotLimitAction),
+		}
+	}
+	if src.Spec.Witness != nil {
+		dst.Spec.Witness = &v1.PostgreSQLWitnessSpec{
+			Image:     src.Spec.Witness.Image,
+			Resources: *src.Spec.Witness.Resources.DeepCopy(),
+		}
+	}
+	if src.Spec.Replica != nil {
+		dst.Spec.Replica = &v1.PostgreSQLReplicaSpec{
+			Source: v1.PostgreSQLReplicaSource(src.Spec.Replica.Source),
+		}
+	}
+	if src.Spec.Bootstrap != nil {
+		dst.Spec.Bootstrap = &v1.PostgreSQLBootstrapSpec{}
+		if imp := src.Spec.Bootstrap.Import; imp != nil {
+			dst.Spec.Bootstrap.Import = &v1.PostgreSQLImportSpec{
+				Type:   v1.ImportType(imp.Type),
+				Source: v1.PostgreSQLImportSource(imp.Source),
+			}
+			if imp.Databases != nil {
+				dst.Spec.Bootstrap.Import.Databases = make([]string, len(imp.Databases))
+				copy(dst.Spec.Bootstrap.Import.Databases, imp.Databases)
+			}
+		}
+	}
+	if src.Spec.Publications != nil {
+		dst.Spec.Publications = make([]v1.PostgreSQLPublication, 0, len(src.Spec.Publications))
+	}
+	for _, publication := range src.Spec.Publications {
+		dst.Spec.Publications = append(dst.Spec.Publications, v1.PostgreSQLPublication(*publication.DeepCopy()))
+	}
+	if src.Spec.Subscriptions != nil {
+		dst.Spec.Subscriptions = make([]v1.PostgreSQLSubscription, 0, len(src.Spec.Subscriptions))
+	}
+	for _, subscription := range src.Spec.Subscriptions {
+		dst.Spec.Subscriptions = append(dst.Spec.Subscriptions, v1.PostgreSQLSubscription(*subscription.DeepCopy()))
+	}
+	if src.Spec.Dumps != nil {
+		dst.Spec.Dumps = make([]v1.PostgreSQLDump, 0, len(src.Spec.Dumps))
+	}
+	for i := range src.Spec.Dumps {
+		dst.Spec.Dumps = append(dst.Spec.Dumps, convertDumpTo(&src.Spec.Dumps[i]))
+	}
+	if src.Spec.Template != nil {
+		template := src.Spec.Template.DeepCopy()
+		dst.Spec.Template = &v1.PostgreSQLNodeTemplate{
+			Image:     template.Image,
+			Resources: template.Resources,
+			Storage:   convertStorageTo(&template.Storage),
+		}
+	}
+	if src.Spec.Nodes != nil {
+		dst.Spec.Nodes = make(map[string]v1.PostgreSQLNode, len(src.Spec.Nodes))
+	}
+	for _, node := range src.Spec.Nodes {
+		if _, ok := dst.Spec.Nodes[node.Name]; ok {
+			return fmt.Errorf("Node %v is listed more than once", node.Name)
+		}
+		dst.Spec.Nodes[node.Name] = v1.PostgreSQLNode{
+			Image:     node.Image,
+			Priority:  node.Priority,
+			Resources: *node.Resources.DeepCopy(),
+			Storage:   convertStorageTo(&node.Storage),
+		}
+	}
+	return convertStatusTo(&src.Status, &dst.Status)
+}
+
+// ConvertFrom converts the cluster from v1, the storage version of the API
+func (dst *PostgreSQL) ConvertFrom(src *v1.PostgreSQL) error {
+	src.ObjectMeta.DeepCopyInto(&dst.ObjectMeta)
+	dst.Spec = PostgreSQLSpec{
+		ManagementState: ManagementState(src.Spec.ManagementState),
+	}
+	if src.Spec.HealthCheckInterval != nil {
+		interval := *src.Spec.HealthCheckInterval
+		dst.Spec.HealthCheckInterval = &interval
+	}
+	if src.Spec.Instances != nil {
+		instances := *src.Spec.Instances
+		dst.Spec.Instances = &instances
+	}
+	if src.Spec.Credentials != nil {
+		dst.Spec.Credentials = convertCredentialsFrom(src.Spec.Credentials)
+	}
+	if src.Spec.Replication != nil {
+		replication := src.Spec.Replication.DeepCopy()
+		dst.Spec.Replication = &PostgreSQLReplicationSpec{
+			UseSlots:           replication.UseSlots,
+			MaxSlotRetainedWAL: replication.MaxSlotRetainedWAL,
+			SlotLimitAction:    SlotLimitAction(replication.SlotLimitAction),
+		}
+	}
+	if src.Spec.Witness != nil {
+		dst.Spec.Witness = &PostgreSQLWitnessSpec{
+			Image:     src.Spec.Witness.Image,
+			Resources: *src.Spec.Witness.Resources.DeepCopy(),
+		}
+	}
+	if src.Spec.Replica != nil {
+		dst.Spec.Replica = &PostgreSQLReplicaSpec{
+			Source: PostgreSQLReplicaSource(src.Spec.Replica.Source),
+		}
+	}
+	if src.Spec.Bootstrap != nil {
+		dst.Spec.Bootstrap = &PostgreSQLBootstrapSpec{}
+		if imp := src.Spec.Bootstrap.Import; imp != nil {
+			dst.Spec.Bootstrap.Import = &PostgreSQLImportSpec{
+				Type:   ImportType(imp.Type),
+				Source: PostgreSQLImportSource(imp.Source),
+			}
+			if imp.Databases != nil {
+				dst.Spec.Bootstrap.Import.Databases = make([]string, len(imp.Databases))
+				copy(dst.Spec.Bootstrap.Import.Databases, imp.Databases)
+			}
+		}
+	}
+	if src.Spec.Publications != nil {
+		dst.Spec.Publications = make([]PostgreSQLPublication, 0, len(src.Spec.Publications))
+	}
+	for _, publication := range src.Spec.Publications {
+		dst.Spec.Publications = append(dst.Spec.Publications, PostgreSQLPublication(*publication.DeepCopy()))
+	}
+	if src.Spec.Subscriptions != nil {
+		dst.Spec.Subscriptions = make([]PostgreSQLSubscription, 0, len(src.Spec.Subscriptions))
+	}
+	for _, subscription := range src.Spec.Subscriptions {
+		dst.Spec.Subscriptions = append(dst.Spec.Subscriptions, PostgreSQLSubscription(*subscription.DeepCopy()))
+	}
+	if src.Spec.Dumps != nil {
+		dst.Spec.Dumps = make([]PostgreSQLDump, 0, len(src.Spec.Dumps))
+	}
+	for i := range src.Spec.Dumps {
+		dst.Spec.Dumps = append(dst.Spec.Dumps, convertDumpFrom(&src.Spec.Dumps[i]))
+	}
+	if src.Spec.Template != nil {
+		template := src.Spec.Template.DeepCopy()
+		dst.Spec.Template = &PostgreSQLNodeTemplate{
+			Image:     template.Image,
+			Resources: template.Resources,
+			Storage:   convertStorageFrom(&template.Storage),
+		}
+	}
+	if src.Spec.Nodes != nil {
+		dst.Spec.Nodes = make([]PostgreSQLNode, 0, len(src.Spec.Nodes))
+	}
+	for _, name := range sortedNames(src.Spec.Nodes) {
+		node := src.Spec.Nodes[name]
+		dst.Spec.Nodes = append(dst.Spec.Nodes, PostgreSQLNode{
+			Name:      name,
+			Priority:  node.Priority,
+			Image:     node.Image,
+			Resources: *node.Resources.DeepCopy(),
+			Storage:   convertStorageFrom(&node.Storage),
+		})
+	}
+	return convertStatusFrom(&src.Status, &dst.Status)
+}
+
+func sortedNames(nodes map[string]v1.PostgreSQLNode) []string {
+	names := make([]string, 0, len(nodes))
+	for name := range nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func convertStorageTo(src *PostgreSQLStorageSpec) v1.PostgreSQLStorageSpec {
+	storage := src.DeepCopy()
+	return v1.PostgreSQLStorageSpec{StorageClassName: storage.StorageClassName, Size: storage.Size}
+}
+
+func convertStorageFrom(src *v1.PostgreSQLStorageSpec) PostgreSQLStorageSpec {
+	storage := src.DeepCopy()
+	return PostgreSQLStorageSpec{StorageClassName: storage.StorageClassName, Size: storage.Size}
+}
+
+func convertDumpTo(src *PostgreSQLDump) v1.PostgreSQLDump {
+	dump := src.DeepCopy()
+	dst := v1.PostgreSQLDump{
+		Name:      dump.Name,
+		Schedule:  dump.Schedule,
+		Databases: dump.Databases,
+		Format:    v1.DumpFormat(dump.Format),
+		Image:     dump.Image,
+		Retention: dump.Retention,
+	}
+	if dump.Destination.Volume != nil {
+		volume := convertStorageTo(dump.Destination.Volume)
+		dst.Destination.Volume = &volume
+	}
+	if dump.Destination.ObjectStore != nil {
+		store := v1.PostgreSQLObjectStore(*dump.Destination.ObjectStore)
+		dst.Destination.ObjectStore = &store
+	}
+	return dst
+}
+
+func convertDumpFrom(src *v1.PostgreSQLDump) PostgreSQLDump {
+	dump := src.DeepCopy()
+	dst := PostgreSQLDump{
+		Name:      dump.Name,
+		Schedule:  dump.Schedule,
+		Databases: dump.Databases,
+		Format:    DumpFormat(dump.Format),
+		Image:     dump.Image,
+		Retention: dump.Retention,
+	}
+	if dump.Destination.Volume != nil {
+		volume := convertStorageFrom(dump.Destination.Volume)
+		dst.Destination.Volume = &volume
+	}
+	if dump.Destination.ObjectStore != nil {
+		store := PostgreSQLObjectStore(*dump.Destination.ObjectStore)
+		dst.Destination.ObjectStore = &store
+	}
+	return dst
+}
+
+func convertCredentialsTo(src *PostgreSQLCredentialsSpec) *v1.PostgreSQLCredentialsSpec {
+	credentials := src.DeepCopy()
+	dst := &v1.PostgreSQLCredentialsSpec{
+		RotationPeriod:      credentials.RotationPeriod,
+		SecretName:          credentials.SecretRef.Name,
+		DatabasePasswordKey: credentials.SecretRef.DatabasePasswordKey,
+		RepmgrPasswordKey:   credentials.SecretRef.RepmgrPasswordKey,
+		Provider:            v1.CredentialsProvider(credentials.Provider),
+	}
+	if credentials.Vault != nil {
+		dst.Vault = &v1.PostgreSQLVaultSpec{Mount: credentials.Vault.Mount, Path: credentials.Vault.Path}
+	}
+	return dst
+}
+
+func convertCredentialsFrom(src *v1.PostgreSQLCredentialsSpec) *PostgreSQLCredentialsSpec {
+	credentials := src.DeepCopy()
+	dst := &PostgreSQLCredentialsSpec{
+		RotationPeriod: credentials.RotationPeriod,
+		SecretRef: PostgreSQLSecretReference{
+			Name:                credentials.SecretName,
+			DatabasePasswordKey: credentials.DatabasePasswordKey,
+			RepmgrPasswordKey:   credentials.RepmgrPasswordKey,
+		},
+		Provider: CredentialsProvider(credentials.Provider),
+	}
+	if credentials.Vault != nil {
+		dst.Vault = &PostgreSQLVaultSpec{Mount: credentials.Vault.Mount, Path: credentials.Vault.Path}
+	}
+	return dst
+}
+
+func convertStatusTo(src *PostgreSQLStatus, dst *v1.PostgreSQLStatus) error {
+	*dst = v1.PostgreSQLStatus{
+		Instances: src.Instances,
+		Selector:  src.Selector,
+	}
+	if src.Nodes != nil {
+		dst.Nodes = make(map[string]v1.PostgreSQLNodeStatus, len(src.Nodes))
+	}
+	for _, node := range src.Nodes {
+		if _, ok := dst.Nodes[node.Name]; ok {
+			return fmt.Errorf("Status of node %v is listed more than once", node.Name)
+		}
+		var rejoin *v1.PostgreSQLRejoinStatus
+		if node.Rejoin != nil {
+			rejoin = &v1.PostgreSQLRejoinStatus{
+				StartTime: *node.Rejoin.StartTime.DeepCopy(),
+				Wiping:    node.Rejoin.Wiping,
+				NodeID:    node.Rejoin.NodeID,
+			}
+		}
+		dst.Nodes[node.Name] = v1.PostgreSQLNodeStatus{
+			DeploymentName: node.DeploymentName,
+			ServiceName:    node.ServiceName,
+			PgVersion:      node.PgVersion,
+			Status:         node.Status,
+			Role:           v1.PostgreSQLNodeRole(node.Role),
+			Priority:       node.Priority,
+			Fenced:         node.Fenced,
+			RejoinStrategy: node.RejoinStrategy,
+			Rejoin:         rejoin,
+		}
+	}
+	if src.Conditions != nil {
+		dst.Conditions = make([]v1.PostgreSQLCondition, 0, len(src.Conditions))
+	}
+	for _, condition := range src.Conditions {
+		dst.Conditions = append(dst.Conditions, v1.PostgreSQLCondition{
+			Type:               v1.PostgreSQLConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: *condition.LastTransitionTime.DeepCopy(),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	if src.Hibernation != nil {
+		dst.Hibernation = &v1.PostgreSQLHibernationStatus{
+			Phase:   v1.HibernationPhase(src.Hibernation.Phase),
+			Primary: src.Hibernation.Primary,
+			Since:   *src.Hibernation.Since.DeepCopy(),
+		}
+	}
+	if src.Credentials != nil {
+		dst.Credentials = &v1.PostgreSQLCredentialsStatus{
+			LastRotation:  src.Credentials.LastRotation.DeepCopy(),
+			SecretVersion: src.Credentials.SecretVersion,
+		}
+	}
+	if src.ReplicationSlots != nil {
+		dst.ReplicationSlots = make(map[string]v1.PostgreSQLReplicationSlotStatus, len(src.ReplicationSlots))
+	}
+	for _, slot := range src.ReplicationSlots {
+		if _, ok := dst.ReplicationSlots[slot.Name]; ok {
+			return fmt.Errorf("Status of replication slot %v is listed more than once", slot.Name)
+		}
+		dst.ReplicationSlots[slot.Name] = v1.PostgreSQLReplicationSlotStatus{
+			Node:        slot.Node,
+			Active:      slot.Active,
+			RetainedWAL: slot.RetainedWAL.DeepCopy(),
+			Invalidated: slot.Invalidated,
+		}
+	}
+	if src.Witness != nil {
+		witness := v1.PostgreSQLWitnessStatus(*src.Witness)
+		dst.Witness = &witness
+	}
+	if src.Replica != nil {
+		dst.Replica = &v1.PostgreSQLReplicaStatus{
+			LeadNode: src.Replica.LeadNode,
+			Phase:    v1.ReplicaPhase(src.Replica.Phase),
+		}
+	}
+	if src.Import != nil {
+		dst.Import = &v1.PostgreSQLImportStatus{
+			Type:           v1.ImportType(src.Import.Type),
+			Phase:          v1.ImportPhase(src.Import.Phase),
+			StartTime:      src.Import.StartTime.DeepCopy(),
+			CompletionTime: src.Import.CompletionTime.DeepCopy(),
+			Message:        src.Import.Message,
+		}
+	}
+	if src.Publications != nil {
+		dst.Publications = make([]string, len(src.Publications))
+		copy(dst.Publications, src.Publications)
+	}
+	if src.Subscriptions != nil {
+		dst.Subscriptions = make(map[string]v1.PostgreSQLSubscriptionStatus, len(src.Subscriptions))
+	}
+	for _, subscription := range src.Subscriptions {
+		if _, ok := dst.Subscriptions[subscription.Name]; ok {
+			return fmt.Errorf("Status of subscription %v is listed more than once", subscription.Name)
+		}
+		dst.Subscriptions[subscription.Name] = v1.PostgreSQLSubscriptionStatus{
+			State: v1.SubscriptionState(subscription.State),
+			Lag:   subscription.DeepCopy().Lag,
+		}
+	}
+	if src.Dumps != nil {
+		dst.Dumps = make(map[string]v1.PostgreSQLDumpStatus, len(src.Dumps))
+	}
+	for _, dump := range src.Dumps {
+		if _, ok := dst.Dumps[dump.Name]; ok {
+			return fmt.Errorf("Status of dump %v is listed more than once", dump.Name)
+		}
+		dst.Dumps[dump.Name] = v1.PostgreSQLDumpStatus{
+			CronJobName:        dump.CronJobName,
+			LastScheduleTime:   dump.LastScheduleTime.DeepCopy(),
+			LastJob:            dump.LastJob,
+			LastResult:         v1.DumpResult(dump.LastResult),
+			LastSuccessfulTime: dump.LastSuccessfulTime.DeepCopy(),
+			Message:            dump.Message,
+		}
+	}
+	return nil
+}
+
+func convertStatusFrom(src *v1.PostgreSQLStatus, dst *PostgreSQLStatus) error {
+	*dst = PostgreSQLStatus{
+		Instances: src.Instances,
+		Selector:  src.Selector,
+	}
+	if src.Nodes != nil {
+		dst.Nodes = make([]PostgreSQLNodeStatus, 0, len(src.Nodes))
+	}
+	names := make([]string, 0, len(src.Nodes))
+	for name := range src.Nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		node := src.Nodes[name]
+		var rejoin *PostgreSQLRejoinStatus
+		if node.Rejoin != nil {
+			rejoin = &PostgreSQLRejoinStatus{
+				StartTime: *node.Rejoin.StartTime.DeepCopy(),
+				Wiping:    node.Rejoin.Wiping,
+				NodeID:    node.Rejoin.NodeID,
+			}
+		}
+		dst.Nodes = append(dst.Nodes, PostgreSQLNodeStatus{
+			Name:           name,
+			DeploymentName: node.DeploymentName,
+			ServiceName:    node.ServiceName,
+			PgVersion:      node.PgVersion,
+			Status:         node.Status,
+			Role:           PostgreSQLNodeRole(node.Role),
+			Priority:       node.Priority,
+			Fenced:         node.Fenced,
+			RejoinStrategy: node.RejoinStrategy,
+			Rejoin:         rejoin,
+		})
+	}
+	if src.Conditions != nil {
+		dst.Conditions = make([]PostgreSQLCondition, 0, len(src.Conditions))
+	}
+	for _, condition := range src.Conditions {
+		dst.Conditions = append(dst.Conditions, PostgreSQLCondition{
+			Type:               PostgreSQLConditionType(condition.Type),
+			Status:             condition.Status,
+			LastTransitionTime: *condition.LastTransitionTime.DeepCopy(),
+			Reason:             condition.Reason,
+			Message:            condition.Message,
+		})
+	}
+	if src.Hibernation != nil {
+		dst.Hibernation = &PostgreSQLHibernationStatus{
+			Phase:   HibernationPhase(src.Hibernation.Phase),
+			Primary: src.Hibernation.Primary,
+			Since:   *src.Hibernation.Since.DeepCopy(),
+		}
+	}
+	if src.Credentials != nil {
+		dst.Credentials = &PostgreSQLCredentialsStatus{
+			LastRotation:  src.Credentials.LastRotation.DeepCopy(),
+			SecretVersion: src.Credentials.SecretVersion,
+		}
+	}
+	if src.ReplicationSlots != nil {
+		dst.ReplicationSlots = make([]PostgreSQLReplicationSlotStatus, 0, len(src.ReplicationSlots))
+	}
+	slots := make([]string, 0, len(src.ReplicationSlots))
+	for name := range src.ReplicationSlots {
+		slots = append(slots, name)
+	}
+	sort.Strings(slots)
+	for _, name := range slots {
+		slot := src.ReplicationSlots[name]
+		dst.ReplicationSlots = append(dst.ReplicationSlots, PostgreSQLReplicationSlotStatus{
+			Name:        name,
+			Node:        slot.Node,
+			Active:      slot.Active,
+			RetainedWAL: slot.RetainedWAL.DeepCopy(),
+			Invalidated: slot.Invalidated,
+		})
+	}
+	if src.Witness != nil {
+		witness := PostgreSQLWitnessStatus(*src.Witness)
+		dst.Witness = &witness
+	}
+	if src.Replica != nil {
+		dst.Replica = &PostgreSQLReplicaStatus{
+			LeadNode: src.Replica.LeadNode,
+			Phase:    ReplicaPhase(src.Replica.Phase),
+		}
+	}
+	if src.Import != nil {
+		dst.Import = &PostgreSQLImportStatus{
+			Type:           ImportType(src.Import.Type),
+			Phase:          ImportPhase(src.Import.Phase),
+			StartTime:      src.Import.StartTime.DeepCopy(),
+			CompletionTime: src.Import.CompletionTime.DeepCopy(),
+			Message:        src.Import.Message,
+		}
+	}
+	if src.Publications != nil {
+		dst.Publications = make([]string, len(src.Publications))
+		copy(dst.Publications, src.Publications)
+	}
+	if src.Subscriptions != nil {
+		dst.Subscriptions = make([]PostgreSQLSubscriptionStatus, 0, len(src.Subscriptions))
+	}
+	subscriptions := make([]string, 0, len(src.Subscriptions))
+	for name := range src.Subscriptions {
+		subscriptions = append(subscriptions, name)
+	}
+	sort.Strings(subscriptions)
+	for _, name := range subscriptions {
+		subscription := src.Subscriptions[name]
+		dst.Subscriptions = append(dst.Subscriptions, PostgreSQLSubscriptionStatus{
+			Name:  name,
+			State: SubscriptionState(subscription.State),
+			Lag:   subscription.DeepCopy().Lag,
+		})
+	}
+	if src.Dumps != nil {
+		dst.Dumps = make([]PostgreSQLDumpStatus, 0, len(src.Dumps))
+	}
+	dumps := make([]string, 0, len(src.Dumps))
+	for name := range src.Dumps {
+		dumps = append(dumps, name)
+	}
+	sort.Strings(dumps)
+	for _, name := range dumps {
+		dump := src.Dumps[name]
+		dst.Dumps = append(dst.Dumps, PostgreSQLDumpStatus{
+			Name:               name,
+			CronJobName:        dump.CronJobName,
+			LastScheduleTime:   dump.LastScheduleTime.DeepCopy(),
+			LastJob:            dump.LastJob,
+			LastResult:         DumpResult(dump.LastResult),
+			LastSuccessfulTime: dump.LastSuccessfulTime.DeepCopy(),
+			Message:            dump.Message,
+		})
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package v2
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+
+	fuzz "github.com/google/gofuzz"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/diff"
+
+	v1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const fuzzIterations = 200
+
+// newFuzzer returns fuzzer producing valid clusters of both versions
+func newFuzzer(seed int64) *fuzz.Fuzzer {
+	return fuzz.New().NilChance(0.2).NumElements(0, 3).RandSource(rand.NewSource(seed)).Funcs(
+		// type is set by the caller of the conversion
+		func(meta *metav1.TypeMeta, c fuzz.Continue) {
+			*meta = metav1.TypeMeta{}
+		},
+		func(t *metav1.Time, c fuzz.Continue) {
+			*t = metav1.Unix(c.Int63n(1<<32), 0)
+		},
+		func(q *resource.Quantity, c fuzz.Continue) {
+			*q = *resource.NewQuantity(c.Int63n(1<<32), resource.BinarySI)
+		},
+		// names of nodes are unique, conversion orders nodes by name
+		func(spec *PostgreSQLSpec, c fuzz.Continue) {
+			c.FuzzNoCustom(spec)
+			for i := range spec.Nodes {
+				spec.Nodes[i].Name = fmt.Sprintf("node-%d", i)
+			}
+		},
+		func(status *PostgreSQLStatus, c fuzz.Continue) {
+			c.FuzzNoCustom(status)
+			for i := range status.Nodes {
+				status.Nodes[i].Name = fmt.Sprintf("node-%d", i)
+			}
+			for i := range status.ReplicationSlots {
+				status.ReplicationSlots[i].Name = fmt.Sprintf("repmgr_slot_%d", i)
+			}
+			for i := range status.Subscriptions {
+				status.Subscriptions[i].Name = fmt.Sprintf("subscription-%d", i)
+			}
+			for i := range status.Dumps {
+				status.Dumps[i].Name = fmt.Sprintf("dump-%d", i)
+			}
+		},
+	)
+}
+
+func TestRoundTripFromV2(t *testing.T) {
+	f := newFuzzer(1)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &PostgreSQL{}
+		f.Fuzz(original)
+
+		stored := &v1.PostgreSQL{}
+		if err := original.ConvertTo(stored); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		actual := &PostgreSQL{}
+		if err := actual.ConvertFrom(stored); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(original, actual) {
+			t.Fatalf("Test failed, round trip changed the cluster: %v", diff.ObjectReflectDiff(original, actual))
+		}
+	}
+}
+
+func TestRoundTripFromV1(t *testing.T) {
+	f := newFuzzer(2)
+	for i := 0; i < fuzzIterations; i++ {
+		original := &v1.PostgreSQL{}
+		f.Fuzz(original)
+
+		converted := &PostgreSQL{}
+		if err := converted.ConvertFrom(original); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		actual := &v1.PostgreSQL{}
+		if err := converted.ConvertTo(actual); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if !equality.Semantic.DeepEqual(original, actual) {
+			t.Fatalf("Test failed, round trip changed the cluster: %v", diff.ObjectReflectDiff(original, actual))
+		}
+	}
+}
+
+func TestConvertTemplate(t *testing.T) {
+	size := resource.MustParse("1Gi")
+	cluster := &PostgreSQL{
+		Spec: PostgreSQLSpec{
+			Template: &PostgreSQLNodeTemplate{
+				Image:   "postgresql:10",
+				Storage: PostgreSQLStorageSpec{Size: &size},
+			},
+			Nodes: []PostgreSQLNode{
+				{Name: "node-one", Priority: 100},
+				{Name: "node-two", Priority: 50, Image: "postgresql:11"},
+			},
+		},
+	}
+	stored := &v1.PostgreSQL{}
+	if err := cluster.ConvertTo(stored); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if stored.Spec.Template == nil || stored.Spec.Template.Image != "postgresql:10" {
+		t.Fatalf("Test failed, expected: '%v', got: '%v'", "postgresql:10", stored.Spec.Template)
+	}
+	if stored.Spec.Template.Storage.Size == nil || stored.Spec.Template.Storage.Size.Cmp(size) != 0 {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", size.String(), stored.Spec.Template.Storage.Size)
+	}
+	table := []struct {
+		name  string
+		image string
+	}{
+		{"node-one", ""},
+		{"node-two", "postgresql:11"},
+	}
+	for _, tt := range table {
+		if image := stored.Spec.Nodes[tt.name].Image; image != tt.image {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.image, image)
+		}
+	}
+}
+
+func TestConvertDuplicateNodes(t *testing.T) {
+	cluster := &PostgreSQL{
+		Spec: PostgreSQLSpec{
+			Nodes: []PostgreSQLNode{{Name: "node-one"}, {Name: "node-one"}},
+		},
+	}
+	if err := cluster.ConvertTo(&v1.PostgreSQL{}); err == nil {
+		t.Errorf("Test failed, expected error for duplicate nodes")
+	}
+}
@@ -0,0 +1,4 @@
+// Package v2 contains API Schema definitions for the postgresql v2 API group
+// +k8s:deepcopy-gen=package,register
+// +groupName=postgresql.openshift.io
+package v2
@@ -0,0 +1,535 @@
+package v2
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PostgreSQLList contains a list of PostgreSQL
+type PostgreSQLList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PostgreSQL `json:"items"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// PostgreSQL is the Schema for the postgresqls API
+// +k8s:openapi-gen=true
+type PostgreSQL struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PostgreSQLSpec   `json:"spec,omitempty"`
+	Status PostgreSQLStatus `json:"status,omitempty"`
+}
+
+type ManagementState string
+
+const (
+	// Managed means that the operator is actively managing its resources and trying to keep the component active.
+	ManagementStateManaged = "managed"
+	// Unmanaged means that the operator will not take any action related to the component
+	ManagementStateUnmanaged = "unmanaged"
+	// Hibernated means that the operator shuts down all nodes and keeps only their data
+	ManagementStateHibernated = "hibernated"
+)
+
+// PostgreSQLSpec defines the desired state of PostgreSQL
+// +k8s:openapi-gen=true
+type PostgreSQLSpec struct {
+	ManagementState ManagementState `json:"managementState"`
+	// Template holds defaults applied to all nodes, which don't set the value themselves
+	Template *PostgreSQLNodeTemplate `json:"template,omitempty"`
+	// Nodes of the cluster, names of the nodes are unique
+	// +listType=map
+	// +listMapKey=name
+	Nodes []PostgreSQLNode `json:"nodes,omitempty"`
+	// Instances is the number of nodes of the cluster, the operator generates
+	// names and priorities of nodes not listed in Nodes
+	Instances   *int32                     `json:"instances,omitempty"`
+	Credentials *PostgreSQLCredentialsSpec `json:"credentials,omitempty"`
+	// HealthCheckInterval overrides the operator default interval of periodic
+	// checks of the cluster, zero disables them
+	HealthCheckInterval *metav1.Duration           `json:"healthCheckInterval,omitempty"`
+	Replication         *PostgreSQLReplicationSpec `json:"replication,omitempty"`
+	// Witness deploys a repmgr witness node, which holds no data and only
+	// helps to decide about failover
+	Witness *PostgreSQLWitnessSpec `json:"witness,omitempty"`
+	// Replica makes the cluster a standby of another cluster, its lead node
+	// streams from the source and other nodes cascade from the lead node
+	Replica *PostgreSQLReplicaSpec `json:"replica,omitempty"`
+	// Bootstrap configures initialization of a new cluster
+	Bootstrap *PostgreSQLBootstrapSpec `json:"bootstrap,omitempty"`
+	// Publications and Subscriptions configure logical replication of the
+	// application database with other clusters
+	// +listType=map
+	// +listMapKey=name
+	Publications []PostgreSQLPublication `json:"publications,omitempty"`
+	// +listType=map
+	// +listMapKey=name
+	Subscriptions []PostgreSQLSubscription `json:"subscriptions,omitempty"`
+	// Dumps schedule logical dumps of databases of the cluster
+	// +listType=map
+	// +listMapKey=name
+	Dumps []PostgreSQLDump `json:"dumps,omitempty"`
+}
+
+// PostgreSQLNodeTemplate defines values shared by nodes of the cluster
+// +k8s:openapi-gen=true
+type PostgreSQLNodeTemplate struct {
+	Image     string                      `json:"image,omitempty"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	Storage   PostgreSQLStorageSpec       `json:"storage,omitempty"`
+}
+
+// PostgreSQLNode defines individual node in PostgreSQL cluster, values which
+// are not set are taken from the template
+// +k8s:openapi-gen=true
+type PostgreSQLNode struct {
+	Name      string                      `json:"name"`
+	Priority  int                         `json:"priority"`
+	Image     string                      `json:"image,omitempty"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+	Storage   PostgreSQLStorageSpec       `json:"storage,omitempty"`
+}
+
+type PostgreSQLStorageSpec struct {
+	StorageClassName *string            `json:"storageClassName,omitempty"`
+	Size             *resource.Quantity `json:"size,omitempty"`
+}
+
+// PostgreSQLReplicationSpec configures streaming replication between nodes
+// +k8s:openapi-gen=true
+type PostgreSQLReplicationSpec struct {
+	// UseSlots makes standbys stream from physical replication slots on the primary
+	UseSlots bool `json:"useSlots,omitempty"`
+	// MaxSlotRetainedWAL limits amount of WAL retained by a single slot
+	MaxSlotRetainedWAL *resource.Quantity `json:"maxSlotRetainedWAL,omitempty"`
+	// SlotLimitAction is taken when a slot retains more WAL than the limit, defaults to alert
+	SlotLimitAction SlotLimitAction `json:"slotLimitAction,omitempty"`
+}
+
+// PostgreSQLWitnessSpec defines the repmgr witness node of the cluster, values
+// which are not set are taken from the template or the operator defaults
+// +k8s:openapi-gen=true
+type PostgreSQLWitnessSpec struct {
+	Image     string                      `json:"image,omitempty"`
+	Resources corev1.ResourceRequirements `json:"resources,omitempty"`
+}
+
+// PostgreSQLReplicaSpec defines the source of the replica cluster
+// +k8s:openapi-gen=true
+type PostgreSQLReplicaSpec struct {
+	Source PostgreSQLReplicaSource `json:"source"`
+}
+
+// PostgreSQLReplicaSource is the primary of another cluster, the replica
+// cluster is a physical copy of the source, so it uses passwords of the source
+// +k8s:openapi-gen=true
+type PostgreSQLReplicaSource struct {
+	Host string `json:"host"`
+	// Port defaults to 5432
+	Port int32 `json:"port,omitempty"`
+	// CredentialsSecret is name of the secret holding passwords of the source
+	// cluster under database-password and repmgr-password keys
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// PostgreSQLBootstrapSpec defines how a new cluster is initialized
+// +k8s:openapi-gen=true
+type PostgreSQLBootstrapSpec struct {
+	// Import copies data of an external database into the new cluster, it's
+	// ignored by clusters which were already initialized
+	Import *PostgreSQLImportSpec `json:"import,omitempty"`
+}
+
+// PostgreSQLImportSpec defines import of an external database
+// +k8s:openapi-gen=true
+type PostgreSQLImportSpec struct {
+	// Type is dump, which restores dumps of the databases into the new
+	// primary, or clone, which copies the whole source server, defaults to dump
+	Type   ImportType             `json:"type,omitempty"`
+	Source PostgreSQLImportSource `json:"source"`
+	// Databases are imported by the dump import, defaults to the application database
+	Databases []string `json:"databases,omitempty"`
+}
+
+// PostgreSQLImportSource is the external PostgreSQL server to be imported
+// +k8s:openapi-gen=true
+type PostgreSQLImportSource struct {
+	Host string `json:"host"`
+	// Port defaults to 5432
+	Port int32 `json:"port,omitempty"`
+	// CredentialsSecret is name of the secret holding username and password
+	// keys, the clone import requires a user with replication privilege
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// ImportType is the method of the import
+type ImportType string
+
+const (
+	// ImportTypeDump restores dumps of the databases using pg_dump and pg_restore in a job
+	ImportTypeDump ImportType = "dump"
+	// ImportTypeClone clones the source server into the primary node
+	ImportTypeClone ImportType = "clone"
+)
+
+// PostgreSQLPublication defines a logical replication publication
+// +k8s:openapi-gen=true
+type PostgreSQLPublication struct {
+	Name string `json:"name"`
+	// Tables are published tables as [schema.]table, all tables of the
+	// database are published if empty
+	Tables []string `json:"tables,omitempty"`
+}
+
+// PostgreSQLSubscription defines a subscription to publications of another
+// database, the publisher is either a cluster managed by the operator or the
+// connection string stored in a secret
+// +k8s:openapi-gen=true
+type PostgreSQLSubscription struct {
+	Name         string   `json:"name"`
+	Publications []string `json:"publications"`
+	// Cluster is name of the publishing PostgreSQL cluster in the same
+	// namespace, host, port and database of its primary are prepended to the
+	// connection string
+	Cluster string `json:"cluster,omitempty"`
+	// ConnectionSecret selects the key of a secret holding connection string
+	// of the publisher, including credentials of a role allowed to replicate
+	ConnectionSecret *corev1.SecretKeySelector `json:"connectionSecret"`
+}
+
+// PostgreSQLDump defines a logical dump of databases run on schedule, a
+// standby is dumped if any is ready, the primary otherwise
+// +k8s:openapi-gen=true
+type PostgreSQLDump struct {
+	Name string `json:"name"`
+	// Schedule in the cron format, e.g. "0 3 * * *"
+	Schedule string `json:"schedule"`
+	// Databases defaults to the application database
+	Databases []string `json:"databases,omitempty"`
+	// Format is custom, restored by pg_restore, or plain SQL, defaults to custom
+	Format DumpFormat `json:"format,omitempty"`
+	// Image of the dump job defaults to the image of the template, it must
+	// provide aws CLI to store dumps in an object store
+	Image       string                    `json:"image,omitempty"`
+	Destination PostgreSQLDumpDestination `json:"destination"`
+	// Retention is the number of dumps of every database kept, zero keeps all dumps
+	Retention int32 `json:"retention,omitempty"`
+}
+
+// PostgreSQLDumpDestination defines where dumps are written, either to a
+// volume or to an object store
+// +k8s:openapi-gen=true
+type PostgreSQLDumpDestination struct {
+	// Volume is PersistentVolumeClaim created by the operator, it's kept
+	// when the dump is removed
+	Volume      *PostgreSQLStorageSpec `json:"volume,omitempty"`
+	ObjectStore *PostgreSQLObjectStore `json:"objectStore,omitempty"`
+}
+
+// PostgreSQLObjectStore is a bucket of S3 compatible object store
+// +k8s:openapi-gen=true
+type PostgreSQLObjectStore struct {
+	// Endpoint defaults to AWS S3
+	Endpoint string `json:"endpoint,omitempty"`
+	Bucket   string `json:"bucket"`
+	// Prefix is prepended to names of the objects
+	Prefix string `json:"prefix,omitempty"`
+	// CredentialsSecret is name of the secret holding AWS_ACCESS_KEY_ID and
+	// AWS_SECRET_ACCESS_KEY keys
+	CredentialsSecret string `json:"credentialsSecret"`
+}
+
+// DumpFormat is the output format of pg_dump
+type DumpFormat string
+
+const (
+	// DumpFormatCustom is the compressed archive restored by pg_restore
+	DumpFormatCustom DumpFormat = "custom"
+	// DumpFormatPlain is a plain SQL script
+	DumpFormatPlain DumpFormat = "plain"
+)
+
+// SlotLimitAction is the action taken on a slot exceeding the limit of retained WAL
+type SlotLimitAction string
+
+const (
+	// SlotLimitActionAlert reports the slot in conditions and events of the cluster
+	SlotLimitActionAlert SlotLimitAction = "alert"
+	// SlotLimitActionInvalidate drops the slot, its standby may need to be reinitialized
+	SlotLimitActionInvalidate SlotLimitAction = "invalidate"
+)
+
+// PostgreSQLCredentialsSpec defines how passwords of the cluster are managed
+// +k8s:openapi-gen=true
+type PostgreSQLCredentialsSpec struct {
+	// RotationPeriod enables periodic rotation of generated passwords
+	RotationPeriod *metav1.Duration `json:"rotationPeriod,omitempty"`
+	// SecretRef references existing secret with passwords, the secret is never modified by the operator
+	SecretRef PostgreSQLSecretReference `json:"secretRef,omitempty"`
+	// Provider stores generated passwords, ignored when SecretRef is set
+	Provider CredentialsProvider `json:"provider,omitempty"`
+	// Vault configures location of passwords stored by the vault provider
+	Vault *PostgreSQLVaultSpec `json:"vault,omitempty"`
+}
+
+// PostgreSQLSecretReference locates passwords in a secret provided by the user
+type PostgreSQLSecretReference struct {
+	Name string `json:"name,omitempty"`
+	// DatabasePasswordKey is the key of the database user password in the secret
+	DatabasePasswordKey string `json:"databasePasswordKey,omitempty"`
+	// RepmgrPasswordKey is the key of the repmgr user password in the secret
+	RepmgrPasswordKey string `json:"repmgrPasswordKey,omitempty"`
+}
+
+// CredentialsProvider is the store of generated passwords
+type CredentialsProvider string
+
+const (
+	// CredentialsProviderSecret stores passwords in a Kubernetes secret
+	CredentialsProviderSecret CredentialsProvider = "secret"
+	// CredentialsProviderVault stores passwords in Vault KV version 2 secrets engine
+	CredentialsProviderVault CredentialsProvider = "vault"
+)
+
+// PostgreSQLVaultSpec defines location of passwords in Vault
+// +k8s:openapi-gen=true
+type PostgreSQLVaultSpec struct {
+	// Mount is the path the KV secrets engine is mounted at, it must match
+	// the mount configured for the operator
+	Mount string `json:"mount,omitempty"`
+	// Path of the passwords in the secrets engine, it must lie within
+	// "<prefix>/<namespace>/<name>", which is the default
+	Path string `json:"path,omitempty"`
+}
+
+// PostgreSQLStatus defines the observed state of PostgreSQL
+// +k8s:openapi-gen=true
+type PostgreSQLStatus struct {
+	// +listType=map
+	// +listMapKey=name
+	Nodes       []PostgreSQLNodeStatus       `json:"nodes,omitempty"`
+	Conditions  []PostgreSQLCondition        `json:"conditions,omitempty"`
+	Hibernation *PostgreSQLHibernationStatus `json:"hibernation,omitempty"`
+	Credentials *PostgreSQLCredentialsStatus `json:"credentials,omitempty"`
+	// Instances is the number of deployed nodes, Selector selects their pods
+	Instances int32  `json:"instances,omitempty"`
+	Selector  string `json:"selector,omitempty"`
+	// ReplicationSlots of standbys on the primary
+	// +listType=map
+	// +listMapKey=name
+	ReplicationSlots []PostgreSQLReplicationSlotStatus `json:"replicationSlots,omitempty"`
+	// Witness is the observed state of the witness node
+	Witness *PostgreSQLWitnessStatus `json:"witness,omitempty"`
+	// Replica is the state of the replica cluster
+	Replica *PostgreSQLReplicaStatus `json:"replica,omitempty"`
+	// Import is the result of the import of an external database
+	Import *PostgreSQLImportStatus `json:"import,omitempty"`
+	// Publications lists publications created by the operator
+	Publications []string `json:"publications,omitempty"`
+	// Subscriptions created by the operator and their state
+	// +listType=map
+	// +listMapKey=name
+	Subscriptions []PostgreSQLSubscriptionStatus `json:"subscriptions,omitempty"`
+	// Dumps are the outcomes of the last jobs of scheduled dumps
+	// +listType=map
+	// +listMapKey=name
+	Dumps []PostgreSQLDumpStatus `json:"dumps,omitempty"`
+}
+
+// PostgreSQLReplicaStatus describes the replica cluster and its promotion
+type PostgreSQLReplicaStatus struct {
+	// LeadNode is the node streaming from the source cluster
+	LeadNode string       `json:"leadNode"`
+	Phase    ReplicaPhase `json:"phase"`
+}
+
+// ReplicaPhase is the phase of the replica cluster
+type ReplicaPhase string
+
+const (
+	// ReplicaPhaseReplicating means the cluster streams from the source cluster
+	ReplicaPhaseReplicating ReplicaPhase = "replicating"
+	// ReplicaPhasePromoting means the lead node is being promoted to the primary
+	ReplicaPhasePromoting ReplicaPhase = "promoting"
+	// ReplicaPhasePromoted means the cluster is independent of the source cluster
+	ReplicaPhasePromoted ReplicaPhase = "promoted"
+)
+
+// PostgreSQLImportStatus describes the import of an external database
+type PostgreSQLImportStatus struct {
+	Type           ImportType   `json:"type"`
+	Phase          ImportPhase  `json:"phase"`
+	StartTime      *metav1.Time `json:"startTime,omitempty"`
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+	// Message describes failure of the import
+	Message string `json:"message,omitempty"`
+}
+
+// ImportPhase is the phase of the import
+type ImportPhase string
+
+const (
+	// ImportPhaseRunning means data of the source are being copied
+	ImportPhaseRunning ImportPhase = "running"
+	// ImportPhaseSucceeded means the import finished
+	ImportPhaseSucceeded ImportPhase = "succeeded"
+	// ImportPhaseFailed means the import failed, the cluster is initialized without the data
+	ImportPhaseFailed ImportPhase = "failed"
+)
+
+// PostgreSQLWitnessStatus describes health of the witness node
+type PostgreSQLWitnessStatus struct {
+	DeploymentName string `json:"deploymentName,omitempty"`
+	ServiceName    string `json:"serviceName,omitempty"`
+	Ready          bool   `json:"ready"`
+	// Registered means the witness is registered in repmgr cluster of the primary
+	Registered bool `json:"registered"`
+}
+
+// PostgreSQLSubscriptionStatus describes state of a subscription
+type PostgreSQLSubscriptionStatus struct {
+	Name  string            `json:"name"`
+	State SubscriptionState `json:"state"`
+	// Lag is time since the last position reported by the publisher was received
+	Lag *metav1.Duration `json:"lag,omitempty"`
+}
+
+// SubscriptionState is the state of the apply worker of a subscription
+type SubscriptionState string
+
+const (
+	// SubscriptionStateStreaming means the apply worker receives changes from the publisher
+	SubscriptionStateStreaming SubscriptionState = "streaming"
+	// SubscriptionStateDown means the enabled subscription has no running apply worker
+	SubscriptionStateDown SubscriptionState = "down"
+	// SubscriptionStateDisabled means the subscription is disabled
+	SubscriptionStateDisabled SubscriptionState = "disabled"
+)
+
+// PostgreSQLDumpStatus describes the last job of a scheduled dump
+type PostgreSQLDumpStatus struct {
+	Name             string       `json:"name"`
+	CronJobName      string       `json:"cronJobName"`
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+	// LastJob is name of the most recent job of the dump
+	LastJob    string     `json:"lastJob,omitempty"`
+	LastResult DumpResult `json:"lastResult,omitempty"`
+	// LastSuccessfulTime is completion time of the last successful job
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+	// Message describes failure of the last job
+	Message string `json:"message,omitempty"`
+}
+
+// DumpResult is the outcome of a dump job
+type DumpResult string
+
+const (
+	// DumpResultRunning means the job didn't finish yet
+	DumpResultRunning DumpResult = "running"
+	// DumpResultSucceeded means all databases were dumped
+	DumpResultSucceeded DumpResult = "succeeded"
+	// DumpResultFailed means the job failed
+	DumpResultFailed DumpResult = "failed"
+)
+
+// PostgreSQLReplicationSlotStatus describes replication slot of a standby
+type PostgreSQLReplicationSlotStatus struct {
+	Name   string `json:"name"`
+	Node   string `json:"node"`
+	Active bool   `json:"active"`
+	// RetainedWAL is the amount of WAL kept on the primary for the standby
+	RetainedWAL resource.Quantity `json:"retainedWAL"`
+	// Invalidated is set when the slot was dropped for retaining too much WAL,
+	// the slot is not recreated until the standby is recloned
+	Invalidated bool `json:"invalidated,omitempty"`
+}
+
+// PostgreSQLCredentialsStatus records passwords applied to the database roles
+type PostgreSQLCredentialsStatus struct {
+	LastRotation *metav1.Time `json:"lastRotation,omitempty"`
+	// SecretVersion is UID and resource version of the secret whose passwords
+	// were applied to the database roles
+	SecretVersion string `json:"secretVersion,omitempty"`
+}
+
+type HibernationPhase string
+
+const (
+	// HibernationPhaseHibernating means standby nodes are being shut down
+	HibernationPhaseHibernating HibernationPhase = "hibernating"
+	// HibernationPhaseHibernated means all nodes are shut down
+	HibernationPhaseHibernated HibernationPhase = "hibernated"
+	// HibernationPhaseResuming means the primary node is being started
+	HibernationPhaseResuming HibernationPhase = "resuming"
+)
+
+// PostgreSQLHibernationStatus records state of the cluster shut down by hibernation
+type PostgreSQLHibernationStatus struct {
+	Phase   HibernationPhase `json:"phase"`
+	Primary string           `json:"primary"`
+	Since   metav1.Time      `json:"since,omitempty"`
+}
+
+type PostgreSQLConditionType string
+
+const (
+	// SplitBrain means more than one node of the cluster reports itself as primary
+	SplitBrain PostgreSQLConditionType = "SplitBrain"
+	// ReplicationSlotLimitExceeded means a replication slot retains more WAL than the configured limit
+	ReplicationSlotLimitExceeded PostgreSQLConditionType = "ReplicationSlotLimitExceeded"
+	// NodeCleanupFailed means repmgr record or replication slot of a removed node couldn't be dropped
+	NodeCleanupFailed PostgreSQLConditionType = "NodeCleanupFailed"
+)
+
+// PostgreSQLCondition describes the state of the cluster at a certain point
+type PostgreSQLCondition struct {
+	Type               PostgreSQLConditionType `json:"type"`
+	Status             corev1.ConditionStatus  `json:"status"`
+	LastTransitionTime metav1.Time             `json:"lastTransitionTime,omitempty"`
+	Reason             string                  `json:"reason,omitempty"`
+	Message            string                  `json:"message,omitempty"`
+}
+
+type PostgreSQLNodeRole string
+
+const (
+	PostgreSQLNodeRolePrimary = "primary"
+	PostgreSQLNodeRoleStandby = "standby"
+	PostgreSQLNodeRoleWitness = "witness"
+	PostgreSQLNodeRoleUnknown = "unknown"
+)
+
+// PostgreSQLNodeStatus represents the status of individual node
+type PostgreSQLNodeStatus struct {
+	Name           string             `json:"name"`
+	DeploymentName string             `json:"deploymentName,omitempty"`
+	ServiceName    string             `json:"serviceName,omitempty"`
+	PgVersion      string             `json:"pgversion,omitempty"`
+	Status         string             `json:"status,omitempty"`
+	Role           PostgreSQLNodeRole `json:"role,omitempty"`
+	Priority       int                `json:"priority"`
+	Fenced         bool               `json:"fenced,omitempty"`
+	RejoinStrategy string             `json:"rejoinStrategy,omitempty"`
+	// Rejoin is set while the node rejoins the cluster
+	Rejoin *PostgreSQLRejoinStatus `json:"rejoin,omitempty"`
+}
+
+// PostgreSQLRejoinStatus is the state of the rejoin of the node in progress
+type PostgreSQLRejoinStatus struct {
+	StartTime metav1.Time `json:"startTime"`
+	// Wiping is set while deployment and data volume of the node are deleted
+	Wiping bool `json:"wiping,omitempty"`
+	// NodeID is the repmgr id the wiped node is cloned with
+	NodeID int `json:"nodeID,omitempty"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PostgreSQL{}, &PostgreSQLList{})
+}
@@ -0,0 +1,48 @@
+package postgresql
+
+import (
+	"sync"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// requeueBackoff computes exponentially growing delays of clusters requeued
+// repeatedly, e.g. while a standby is being cloned
+type requeueBackoff struct {
+	mutex    sync.Mutex
+	attempts map[types.NamespacedName]uint
+	base     time.Duration
+	max      time.Duration
+}
+
+func newRequeueBackoff(base, max time.Duration) *requeueBackoff {
+	return &requeueBackoff{
+		attempts: make(map[types.NamespacedName]uint),
+		base:     base,
+		max:      max,
+	}
+}
+
+// next returns delay of the next requeue of the cluster
+func (b *requeueBackoff) next(key types.NamespacedName) time.Duration {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	attempt := b.attempts[key]
+	b.attempts[key]++
+	delay := b.base
+	for i := uint(0); i < attempt && delay < b.max; i++ {
+		delay *= 2
+	}
+	if delay > b.max {
+		return b.max
+	}
+	return delay
+}
+
+// reset forgets previous requeues of the cluster
+func (b *requeueBackoff) reset(key types.NamespacedName) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	delete(b.attempts, key)
+}
@@ -0,0 +1,27 @@
+package postgresql
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestRequeueBackoff(t *testing.T) {
+	backoff := newRequeueBackoff(time.Second, 10*time.Second)
+	key := types.NamespacedName{Name: "example", Namespace: "default"}
+	other := types.NamespacedName{Name: "other", Namespace: "default"}
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second}
+	for _, delay := range expected {
+		if actual := backoff.next(key); actual != delay {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", delay, actual)
+		}
+	}
+	if actual := backoff.next(other); actual != time.Second {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", time.Second, actual)
+	}
+	backoff.reset(key)
+	if actual := backoff.next(key); actual != time.Second {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", time.Second, actual)
+	}
+}
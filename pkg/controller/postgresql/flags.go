@@ -0,0 +1,17 @@
+package postgresql
+
+import (
+	"flag"
+	"time"
+)
+
+var (
+	healthCheckInterval = flag.Duration("health-check-interval", 30*time.Second,
+		"Default interval of periodic health checks of PostgreSQL clusters, zero disables them")
+	requeueBaseDelay = flag.Duration("requeue-base-delay", time.Second,
+		"Initial delay of requeued reconcile of a cluster which is not ready yet")
+	requeueMaxDelay = flag.Duration("requeue-max-delay", 2*time.Minute,
+		"Maximal delay of requeued reconcile of a cluster which is not ready yet")
+	reconcileTimeout = flag.Duration("reconcile-timeout", 2*time.Minute,
+		"Deadline of a single reconcile of a PostgreSQL cluster, including all database calls")
+)
@@ -2,12 +2,18 @@ package postgresql
 
 import (
 	"context"
+	"time"
 
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	k8shandler "github.com/mcyprian/postgresql-operator/pkg/k8shandler"
+	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller"
 	"sigs.k8s.io/controller-runtime/pkg/handler"
@@ -27,7 +33,17 @@ func Add(mgr manager.Manager) error {
 
 // newReconciler returns a new reconcile.Reconciler
 func newReconciler(mgr manager.Manager) reconcile.Reconciler {
-	return &ReconcilePostgreSQL{client: mgr.GetClient(), scheme: mgr.GetScheme()}
+	executor, err := k8shandler.NewPodExecutor(mgr.GetConfig())
+	if err != nil {
+		log.Error(err, "Failed to create pod executor")
+	}
+	return &ReconcilePostgreSQL{
+		client:   mgr.GetClient(),
+		scheme:   mgr.GetScheme(),
+		recorder: mgr.GetRecorder("postgresql-operator"),
+		executor: executor,
+		backoff:  newRequeueBackoff(*requeueBaseDelay, *requeueMaxDelay),
+	}
 }
 
 // add adds a new Controller to mgr with r as the reconcile.Reconciler
@@ -39,16 +55,34 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	}
 
 	// Watch for changes to primary resource PostgreSQL
-	err = c.Watch(&source.Kind{Type: &postgresqlv1.PostgreSQL{}}, &handler.EnqueueRequestForObject{})
+	err = c.Watch(&source.Kind{Type: &postgresqlv1.PostgreSQL{}}, &handler.EnqueueRequestForObject{}, ignoreStatusUpdates)
 	if err != nil {
 		return err
 	}
 
-	// Watch for changes to secondary resource Pods and requeue the owner PostgreSQL
-	err = c.Watch(&source.Kind{Type: &corev1.Pod{}}, &handler.EnqueueRequestForOwner{
-		IsController: true,
-		OwnerType:    &postgresqlv1.PostgreSQL{},
-	})
+	// Watch for changes to owned resources and requeue the owner PostgreSQL, so
+	// resources deleted or modified outside of the operator are restored
+	for _, owned := range []runtime.Object{
+		&appsv1.Deployment{},
+		&corev1.Service{},
+		&corev1.PersistentVolumeClaim{},
+	} {
+		err = c.Watch(&source.Kind{Type: owned}, &handler.EnqueueRequestForOwner{
+			IsController: true,
+			OwnerType:    &postgresqlv1.PostgreSQL{},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	// Watch for changes to Secrets generated by the operator or provided by the
+	// user and requeue the PostgreSQL owning or using them
+	err = c.Watch(&source.Kind{Type: &corev1.Secret{}}, &handler.EnqueueRequestsFromMapFunc{
+		ToRequests: handler.ToRequestsFunc(func(obj handler.MapObject) []reconcile.Request {
+			return clustersUsingSecret(mgr.GetClient(), obj.Meta)
+		}),
+	}, ignoreStatusUpdates)
 	if err != nil {
 		return err
 	}
@@ -56,14 +90,48 @@ func add(mgr manager.Manager, r reconcile.Reconciler) error {
 	return nil
 }
 
+// clustersUsingSecret returns requests for the PostgreSQL cluster owning the
+// secret and for all clusters in the namespace which passwords are stored in
+// the secret provided by the user
+func clustersUsingSecret(c client.Client, secret metav1.Object) []reconcile.Request {
+	var requests []reconcile.Request
+	namespace, secretName := secret.GetNamespace(), secret.GetName()
+	if owner := metav1.GetControllerOf(secret); owner != nil && owner.Kind == "PostgreSQL" {
+		if gv, err := schema.ParseGroupVersion(owner.APIVersion); err == nil && gv.Group == postgresqlv1.SchemeGroupVersion.Group {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: owner.Name, Namespace: namespace},
+			})
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), *reconcileTimeout)
+	defer cancel()
+	clusterList := &postgresqlv1.PostgreSQLList{}
+	if err := c.List(ctx, client.InNamespace(namespace), clusterList); err != nil {
+		log.Error(err, "Failed to list PostgreSQL clusters", "namespace", namespace)
+		return requests
+	}
+	for i := range clusterList.Items {
+		cluster := &clusterList.Items[i]
+		if cluster.Spec.Credentials != nil && cluster.Spec.Credentials.SecretName == secretName {
+			requests = append(requests, reconcile.Request{
+				NamespacedName: types.NamespacedName{Name: cluster.Name, Namespace: cluster.Namespace},
+			})
+		}
+	}
+	return requests
+}
+
 var _ reconcile.Reconciler = &ReconcilePostgreSQL{}
 
 // ReconcilePostgreSQL reconciles a PostgreSQL object
 type ReconcilePostgreSQL struct {
 	// This client, initialized using mgr.Client() above, is a split client
 	// that reads objects from the cache and writes to the apiserver
-	client client.Client
-	scheme *runtime.Scheme
+	client   client.Client
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	executor k8shandler.PodExecutor
+	backoff  *requeueBackoff
 }
 
 // Reconcile reads that state of the cluster for a PostgreSQL object and makes changes based on the state read
@@ -71,14 +139,20 @@ type ReconcilePostgreSQL struct {
 // The Controller will requeue the Request to be processed again if the returned error is non-nil or
 // Result.Requeue is true, otherwise upon completion it will remove the work from the queue.
 func (r *ReconcilePostgreSQL) Reconcile(request reconcile.Request) (reconcile.Result, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), *reconcileTimeout)
+	defer cancel()
 	// Fetch the PostgreSQL instance
 	instance := &postgresqlv1.PostgreSQL{}
-	err := r.client.Get(context.TODO(), request.NamespacedName, instance)
+	err := r.client.Get(ctx, request.NamespacedName, instance)
 	if err != nil {
 		if errors.IsNotFound(err) {
 			// Request object not found, could have been deleted after reconcile request.
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
+			r.backoff.reset(request.NamespacedName)
+			k8shandler.CloseConnections(request.Namespace, request.Name)
+			k8shandler.DeleteMetrics(request.Namespace, request.Name)
+			k8shandler.DeleteClusterState(request.Namespace, request.Name)
 			return reconcile.Result{}, nil
 		}
 		// Error reading the object - requeue the request.
@@ -87,13 +161,27 @@ func (r *ReconcilePostgreSQL) Reconcile(request reconcile.Request) (reconcile.Re
 	if instance.Spec.ManagementState == postgresqlv1.ManagementStateUnmanaged {
 		return reconcile.Result{}, nil
 	}
-	postgresqlRequest := k8shandler.NewPostgreSQLRequest(r.client, instance, r.scheme)
+	postgresqlRequest := k8shandler.NewPostgreSQLRequest(ctx, r.client, instance, r.scheme, r.recorder, r.executor)
 	requeue, err := postgresqlRequest.Reconcile()
 	if err != nil {
 		return reconcile.Result{}, err
 	}
 	if requeue {
-		return reconcile.Result{Requeue: true}, nil
+		return reconcile.Result{RequeueAfter: r.backoff.next(request.NamespacedName)}, nil
+	}
+	r.backoff.reset(request.NamespacedName)
+	if instance.Spec.ManagementState == postgresqlv1.ManagementStateHibernated {
+		return reconcile.Result{}, nil
+	}
+	// Poll the cluster periodically, failovers done by repmgr don't generate
+	// any event of the watched resources
+	return reconcile.Result{RequeueAfter: clusterHealthCheckInterval(instance)}, nil
+}
+
+// clusterHealthCheckInterval returns interval of periodic health checks of the cluster
+func clusterHealthCheckInterval(cluster *postgresqlv1.PostgreSQL) time.Duration {
+	if cluster.Spec.HealthCheckInterval != nil {
+		return cluster.Spec.HealthCheckInterval.Duration
 	}
-	return reconcile.Result{}, nil
+	return *healthCheckInterval
 }
@@ -0,0 +1,76 @@
+package postgresql
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mcyprian/postgresql-operator/pkg/apis"
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+)
+
+func TestClustersUsingSecret(t *testing.T) {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	generated := &postgresqlv1.PostgreSQL{
+		ObjectMeta: metav1.ObjectMeta{Name: "generated", Namespace: "default"},
+	}
+	provided := &postgresqlv1.PostgreSQL{
+		ObjectMeta: metav1.ObjectMeta{Name: "provided", Namespace: "default"},
+		Spec: postgresqlv1.PostgreSQLSpec{
+			Credentials: &postgresqlv1.PostgreSQLCredentialsSpec{SecretName: "user-credentials"},
+		},
+	}
+	c := fake.NewFakeClientWithScheme(s, generated, provided)
+	isController := true
+	tests := []struct {
+		name     string
+		secret   *corev1.Secret
+		expected []reconcile.Request
+	}{
+		{"generated", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "generated",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: postgresqlv1.SchemeGroupVersion.String(),
+				Kind:       "PostgreSQL",
+				Name:       "generated",
+				Controller: &isController,
+			}},
+		}}, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "generated", Namespace: "default"}}}},
+		{"provided", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "user-credentials",
+			Namespace: "default",
+		}}, []reconcile.Request{{NamespacedName: types.NamespacedName{Name: "provided", Namespace: "default"}}}},
+		{"foreign owner", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+			OwnerReferences: []metav1.OwnerReference{{
+				APIVersion: "apps/v1",
+				Kind:       "PostgreSQL",
+				Name:       "generated",
+				Controller: &isController,
+			}},
+		}}, nil},
+		{"unrelated", &corev1.Secret{ObjectMeta: metav1.ObjectMeta{
+			Name:      "other",
+			Namespace: "default",
+		}}, nil},
+	}
+	for _, test := range tests {
+		if actual := clustersUsingSecret(c, test.secret); !reflect.DeepEqual(actual, test.expected) {
+			t.Errorf("Test %v failed, expected: '%v', got: '%v'", test.name, test.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package postgresql
+
+import (
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/event"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+)
+
+// ignoreStatusUpdates filters out update events of PostgreSQL resources and
+// secrets provided by the user which didn't change their metadata or desired
+// state, owned resources are not filtered so readiness changes are observed
+var ignoreStatusUpdates = predicate.Funcs{
+	UpdateFunc: func(e event.UpdateEvent) bool {
+		if e.MetaOld == nil || e.MetaNew == nil {
+			return true
+		}
+		return metadataChanged(e.MetaOld, e.MetaNew) || specChanged(e.ObjectOld, e.ObjectNew)
+	},
+}
+
+// metadataChanged compares fields of metadata managed by the operator, the
+// generation changes with the spec of the PostgreSQL resource
+func metadataChanged(old, new metav1.Object) bool {
+	return old.GetGeneration() != new.GetGeneration() ||
+		!equality.Semantic.DeepEqual(old.GetLabels(), new.GetLabels()) ||
+		!equality.Semantic.DeepEqual(old.GetAnnotations(), new.GetAnnotations()) ||
+		!equality.Semantic.DeepEqual(old.GetOwnerReferences(), new.GetOwnerReferences()) ||
+		(old.GetDeletionTimestamp() == nil) != (new.GetDeletionTimestamp() == nil)
+}
+
+// specChanged compares desired state of the objects, unknown kinds are always
+// considered changed
+func specChanged(old, new runtime.Object) bool {
+	switch old := old.(type) {
+	case *postgresqlv1.PostgreSQL:
+		if new, ok := new.(*postgresqlv1.PostgreSQL); ok {
+			return !equality.Semantic.DeepEqual(old.Spec, new.Spec)
+		}
+	case *corev1.Secret:
+		if new, ok := new.(*corev1.Secret); ok {
+			return !equality.Semantic.DeepEqual(old.Data, new.Data) || old.Type != new.Type
+		}
+	}
+	return true
+}
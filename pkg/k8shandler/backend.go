@@ -0,0 +1,83 @@
+package k8shandler
+
+import (
+	"context"
+	"time"
+)
+
+// sqlBackend queries a single PostgreSQL node and repmgr metadata of its cluster,
+// database implements it using lib/pq
+type sqlBackend interface {
+	version(ctx context.Context) (string, error)
+	isRegistered(ctx context.Context, nodeName string) (bool, error)
+	getNodeInfo(ctx context.Context, nodeName string) (*nodeInfo, error)
+	updateNodePriority(ctx context.Context, nodeName string, priority int) error
+	unregisterNode(ctx context.Context, nodeName string) error
+	registeredNodes(ctx context.Context) ([]string, error)
+	dropNodeSlot(ctx context.Context, nodeName string) (string, error)
+	replicationStats(ctx context.Context) ([]replicationStat, error)
+	replicationSlots(ctx context.Context) ([]replicationSlot, error)
+	createReplicationSlot(ctx context.Context, slot string) error
+	dropReplicationSlot(ctx context.Context, slot string) error
+	isInRecovery(ctx context.Context) (bool, error)
+	timeline(ctx context.Context) (int, error)
+	currentLSN(ctx context.Context) (string, error)
+	timelineHistory(ctx context.Context, timeline int) (string, error)
+	checkpoint(ctx context.Context) error
+	setPassword(ctx context.Context, role, password string) error
+	publications(ctx context.Context, dbname string) ([]publication, error)
+	createPublication(ctx context.Context, dbname string, pub publication) error
+	alterPublication(ctx context.Context, dbname string, pub publication) error
+	dropPublication(ctx context.Context, dbname, name string) error
+	subscriptions(ctx context.Context, dbname string) ([]subscription, error)
+	createSubscription(ctx context.Context, dbname string, sub subscription) error
+	alterSubscription(ctx context.Context, dbname string, sub subscription) error
+	dropSubscription(ctx context.Context, dbname, name string) error
+}
+
+// replicationStat describes a standby streaming from the node
+type replicationStat struct {
+	applicationName string
+	state           string
+	// lagBytes is amount of WAL written by the node but not replayed by the standby yet
+	lagBytes int64
+}
+
+// replicationSlot describes a physical replication slot on the node
+type replicationSlot struct {
+	name   string
+	active bool
+	// retainedBytes is amount of WAL the node keeps for the slot
+	retainedBytes int64
+}
+
+// publication describes a logical replication publication in a database of the
+// node, tables are qualified by schema
+type publication struct {
+	name      string
+	allTables bool
+	tables    []string
+}
+
+// subscription describes a logical replication subscription in a database of
+// the node and its apply worker
+type subscription struct {
+	name         string
+	conninfo     string
+	publications []string
+	enabled      bool
+	// streaming means the apply worker of the subscription is running
+	streaming bool
+	// lag is time since the last position reported by the publisher, negative if unknown
+	lag time.Duration
+}
+
+// connector provides clients of the nodes
+type connector interface {
+	// client returns client of the node without checking its connection
+	client(key connectionKey, host, password string) sqlBackend
+	// connect returns client of the node connected to the host using the password
+	connect(ctx context.Context, key connectionKey, host, password string) (sqlBackend, error)
+	close(key connectionKey)
+	closeCluster(namespace, cluster string)
+}
@@ -4,9 +4,12 @@ import (
 	"context"
 	"fmt"
 	"sort"
+	"strings"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
@@ -23,6 +26,16 @@ const (
 	StandbyRegister = "standby register"
 	// NodeRejoin rejoins the node, which was previously deleted
 	NodeRejoin = "node rejoin"
+	// NodeRewind rejoins the node after its data directory is synchronized using pg_rewind
+	NodeRewind = "node rewind"
+	// WitnessRegister registers witness node into the cluster of the primary
+	WitnessRegister = "witness register"
+	// ReplicaClone clones the node from its upstream and streams from it without registering to repmgr
+	ReplicaClone = "replica clone"
+	// ReplicaPromote promotes the lead node of the replica cluster and registers it as the primary
+	ReplicaPromote = "replica promote"
+	// ImportClone clones the primary node from the import source, promotes it and registers it as the primary
+	ImportClone = "import clone"
 )
 
 // CreateOrUpdateCluster iterates over all nodes in the current spec
@@ -42,35 +55,81 @@ func (request *PostgreSQLRequest) CreateOrUpdateCluster() (bool, error) {
 		request.cluster.Status.Nodes = make(map[string]postgresqlv1.PostgreSQLNodeStatus)
 	}
 	if primaryNode == nil {
-		primaryNode, err = getPrimaryNode()
-		if err != nil {
-			if err := createPrimaryNode(request); err != nil {
-				return true, err
-			}
+		primaryNode = request.leadNode()
+	}
+	if primaryNode == nil {
+		primaryNode, _ = getPrimaryNode(request.ctx)
+	}
+	specNodes := request.specNodes()
+	if primaryNode == nil {
+		if err := createPrimaryNode(request, specNodes); err != nil {
+			return true, err
 		}
 	}
 	logrus.Info("Running create or update for primary service")
+	start := time.Now()
 	err = request.CreateOrUpdateService("postgresql-primary", primaryNode.name())
+	request.observePhase(phaseServices, start, err)
 	if err != nil {
 		logrus.Errorf("Failed to create or update primary service: %v", err)
 		requeue = true
 	}
+	if err := request.reinitializeNodes(); err != nil {
+		logrus.Errorf("Failed to update %v annotation: %v", ReinitializeAnnotation, err)
+	}
 	clusterStatus := request.cluster.Status.DeepCopy()
+	replicaPending, err := request.reconcileReplica(clusterStatus)
+	if err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	if replicaPending {
+		repmgrClusterUp = false
+	}
+	importPending, err := request.reconcileImport(clusterStatus)
+	if err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	if importPending {
+		repmgrClusterUp = false
+	}
+	start = time.Now()
+	var nodesErr error
 	// Loop over all nodes listed in the spec
-	for name, specNode := range request.cluster.Spec.Nodes {
+	for name, specNode := range specNodes {
 		node, ok := nodes[name]
 		if ok {
 			if node.isReady() {
-				status = node.status()
+				if err := unfenceRejoinedNode(request, node); err != nil {
+					logrus.Errorf("Failed to unfence node %v: %v", name, err)
+				}
+				status = node.status(request.ctx)
+				if request.isReplica() {
+					// repmgr metadata of the replica cluster is a copy of the source cluster
+					status.Role = postgresqlv1.PostgreSQLNodeRoleStandby
+				}
 				clusterStatus.Nodes[node.name()] = status
 				if status.Role == postgresqlv1.PostgreSQLNodeRolePrimary && name != primaryNode.name() {
-					logrus.Infof("Failover detected: the new primary node is %v", name)
-					primaryNode = node
-					logrus.Infof("Updating primary service selector to %v", primaryNode.name())
-					err = request.CreateOrUpdateService("postgresql-primary", primaryNode.name())
-					if err != nil {
-						logrus.Errorf("Failed to create or update primary service: %v", err)
-						requeue = true
+					newPrimary, stale := resolvePrimary(request.ctx, primaryNode, node)
+					if stale != nil {
+						if err := fenceStaleNode(request, stale, newPrimary, clusterStatus); err != nil {
+							logrus.Errorf("Failed to fence node %v: %v", stale.name(), err)
+							requeue = true
+						}
+						repmgrClusterUp = false
+					}
+					if newPrimary != primaryNode {
+						logrus.Infof("Failover detected: the new primary node is %v", name)
+						failoversTotal.WithLabelValues(request.seriesLabels(failoversTotal)...).Inc()
+						request.recordWarning(FailoverDetected, "Failover detected, node %v was promoted from %v", name, primaryNode.name())
+						primaryNode = newPrimary
+						logrus.Infof("Updating primary service selector to %v", primaryNode.name())
+						err = request.CreateOrUpdateService("postgresql-primary", primaryNode.name())
+						if err != nil {
+							logrus.Errorf("Failed to create or update primary service: %v", err)
+							requeue = true
+						} else {
+							request.recordNormal(PrimaryServiceRepointed, "Primary service points to node %v", primaryNode.name())
+						}
 					}
 				}
 			} else {
@@ -79,20 +138,51 @@ func (request *PostgreSQLRequest) CreateOrUpdateCluster() (bool, error) {
 		} else {
 			repmgrClusterUp = false
 		}
-		requeue, err = createOrUpdateNode(request, name, &specNode)
+		// failures above already requested requeue, it must not be reset here
+		nodeRequeue, err := createOrUpdateNode(request, name, &specNode)
+		requeue = requeue || nodeRequeue
 		if err != nil {
 			logrus.Errorf("Non-critical issue: %v", err)
 			repmgrClusterUp = false
+			nodesErr = err
 		}
 	}
-	if err := deleteExtraNodes(request, clusterStatus); err != nil {
+	if err := deleteExtraNodes(request, specNodes, clusterStatus); err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+		nodesErr = err
+	}
+	recordRejoinState(clusterStatus)
+	witnessPending, err := request.reconcileWitness(specNodes, clusterStatus)
+	if err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+		nodesErr = err
+	}
+	if witnessPending {
+		repmgrClusterUp = false
+	}
+	if err := request.reconcileSlots(specNodes, clusterStatus); err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	if err := request.reconcileLogicalReplication(clusterStatus); err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	if err := request.reconcileDumps(clusterStatus); err != nil {
 		logrus.Errorf("Non-critical issue: %v", err)
 	}
+	request.instancesStatus(clusterStatus)
+	updateSplitBrainCondition(clusterStatus)
+	request.observePhase(phaseNodes, start, nodesErr)
+	request.observeNodes(clusterStatus.Nodes)
 	logrus.Infof("Nodes after update: %v", nodes)
 
-	if err := UpdateClusterStatus(request, clusterStatus); err != nil {
+	start = time.Now()
+	err = UpdateClusterStatus(request, clusterStatus)
+	request.observePhase(phaseStatus, start, err)
+	if err != nil {
 		logrus.Errorf("Non-critical issue: %v", err)
 		requeue = true
+	} else {
+		statusUpdates.succeeded(request.cluster.Namespace, request.cluster.Name)
 	}
 	if !repmgrClusterUp {
 		requeue = true
@@ -133,7 +223,7 @@ func getNodes(request *PostgreSQLRequest) {
 
 	listOpts := client.MatchingLabels(newLabels(request.cluster.Name, ""))
 	deploymentList := &appsv1.DeploymentList{}
-	err := request.client.List(context.TODO(), listOpts, deploymentList)
+	err := request.client.List(request.ctx, listOpts, deploymentList)
 	if err != nil {
 		logrus.Errorf("Failed to retrieve list of deployments for cluster %v: %v", request.cluster.Name, err)
 	}
@@ -148,13 +238,19 @@ func getNodes(request *PostgreSQLRequest) {
 			logrus.Infof("Attaching existing deployment: %v", deployment.ObjectMeta.Name)
 			nodes[deployment.ObjectMeta.Name] = attachDeploymentNode(request, deployment.ObjectMeta.Name, &deployment, repmgrPassword)
 		}
+		// refresh connections of running nodes, pods may have been recreated
+		if deployment.Status.ReadyReplicas == 1 {
+			if err := nodes[deployment.ObjectMeta.Name].connect(request); err != nil {
+				logrus.Errorf("Non-critical issue: %v", err)
+			}
+		}
 	}
 }
 
 // getPrimaryNode searches for primary node in nodes map
-func getPrimaryNode() (Node, error) {
+func getPrimaryNode(ctx context.Context) (Node, error) {
 	for name, node := range nodes {
-		status := node.status()
+		status := node.status(ctx)
 		if status.Role == postgresqlv1.PostgreSQLNodeRolePrimary {
 			logrus.Infof("Lost primary node %v discovered.", name)
 			return node, nil
@@ -165,14 +261,16 @@ func getPrimaryNode() (Node, error) {
 
 // getRepmgrPassword retrieves Repmgr password from the secret
 func getRepmgrPassword(request *PostgreSQLRequest) (string, error) {
-	secretData, err := extractSecret(request.cluster.Name, request.cluster.Namespace, request.client)
+	ref := request.credentialsSecret()
+	secretData, err := extractSecret(request.ctx, ref.name, request.cluster.Namespace, request.client)
 	if err != nil {
-		logrus.Errorf("Failed to extract secret %v: %v", request.cluster.Name, err)
+		logrus.Errorf("Failed to extract secret %v: %v", ref.name, err)
 		return "", err
 	}
-	repmgrPassword, ok := secretData["repmgr-password"]
+	repmgrPassword, ok := secretData[ref.repmgrKey]
 	if !ok {
-		logrus.Errorf("Repmgr password not found in secret %v: %v", request.cluster.Name, err)
+		err = fmt.Errorf("Repmgr password not found in secret %v", ref.name)
+		logrus.Error(err)
 		return "", err
 	}
 	return string(repmgrPassword), nil
@@ -181,11 +279,14 @@ func getRepmgrPassword(request *PostgreSQLRequest) (string, error) {
 // createNode creates a new node, asigns an id to it and adds it to the nodes map
 func createNode(request *PostgreSQLRequest, name string, specNode *postgresqlv1.PostgreSQLNode, operation string) (Node, error) {
 	var id = -1
-	// Try to get existing id
-	if primaryNode != nil {
+	if request.isReplica() {
+		// nodes of the replica cluster are registered once the cluster is promoted
+		operation = ReplicaClone
+	} else if primaryNode != nil {
+		// Try to get existing id
 		db := primaryNode.dbClient()
-		info := db.getNodeInfo(name)
-		if err := db.err(); err == nil && info.id > 0 {
+		info, err := db.getNodeInfo(request.ctx, name)
+		if err == nil && info.id > 0 {
 			id = info.id
 			operation = NodeRejoin
 		}
@@ -195,6 +296,10 @@ func createNode(request *PostgreSQLRequest, name string, specNode *postgresqlv1.
 		idSequence++
 		id = idSequence
 	}
+	// pods of the node can't start until the secret holds all passwords
+	if err := request.validateCredentialsSecret(); err != nil {
+		return nil, fmt.Errorf("Node %v not created: %v", name, err)
+	}
 	repmgrPassword, err := getRepmgrPassword(request)
 	if err != nil {
 		return nil, err
@@ -204,18 +309,27 @@ func createNode(request *PostgreSQLRequest, name string, specNode *postgresqlv1.
 	if err := node.create(request); err != nil {
 		return nil, err
 	}
+	if operation == NodeRejoin {
+		request.recordNormal(NodeRejoined, "Node %v rejoined the cluster with id %v", name, id)
+	} else {
+		request.recordNormal(NodeCreated, "Node %v created, operation: %v", name, operation)
+	}
 	nodes[name] = node
 	return node, nil
 }
 
 // createPrimaryNode creates primary node if it doesn't exists
-func createPrimaryNode(request *PostgreSQLRequest) error {
-	name, specNode, err := getHighestPriority(request.cluster.Spec.Nodes)
+func createPrimaryNode(request *PostgreSQLRequest, specNodes map[string]postgresqlv1.PostgreSQLNode) error {
+	name, specNode, err := getHighestPriority(specNodes)
 	logrus.Infof("Creating new primary node %v", name)
 	if err != nil {
 		return fmt.Errorf("Nodes spec is empty, cannot choose master node")
 	}
-	node, err := createNode(request, name, specNode, PrimaryRegister)
+	operation := PrimaryRegister
+	if request.cloneImport() {
+		operation = ImportClone
+	}
+	node, err := createNode(request, name, specNode, operation)
 	if err != nil {
 		return err
 	}
@@ -235,6 +349,15 @@ func createOrUpdateNode(request *PostgreSQLRequest, name string, specNode *postg
 		if err != nil {
 			return requeue, err
 		}
+	} else if status, ok := request.cluster.Status.Nodes[name]; ok && status.Rejoin != nil && status.Rejoin.Wiping {
+		// the wipe of the node was interrupted, its fresh copy keeps the id
+		repmgrPassword, err := getRepmgrPassword(request)
+		if err != nil {
+			return true, err
+		}
+		node := resumeWipedNode(request, name, status.Rejoin, repmgrPassword)
+		nodes[name] = node
+		return node.update(request, specNode, primaryNode.dbClient())
 	} else {
 		// Create a new node
 		_, err := createNode(request, name, specNode, StandbyRegister)
@@ -246,9 +369,9 @@ func createOrUpdateNode(request *PostgreSQLRequest, name string, specNode *postg
 }
 
 // deleteExtraNodes deletes all nodes which are not listed in current spec
-func deleteExtraNodes(request *PostgreSQLRequest, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+func deleteExtraNodes(request *PostgreSQLRequest, specNodes map[string]postgresqlv1.PostgreSQLNode, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
 	for name, deployedNode := range nodes {
-		_, ok := request.cluster.Spec.Nodes[name]
+		_, ok := specNodes[name]
 		if !ok {
 			logrus.Infof("Deleting node %v", name)
 			if err := deployedNode.delete(request); err != nil {
@@ -256,7 +379,66 @@ func deleteExtraNodes(request *PostgreSQLRequest, clusterStatus *postgresqlv1.Po
 			}
 			delete(nodes, name)
 			delete(clusterStatus.Nodes, name)
+			request.recordNormal(NodeDeleted, "Node %v deleted", name)
 		}
 	}
+	return unregisterRemovedNodes(request, specNodes, clusterStatus)
+}
+
+// unregisterRemovedNodes drops repmgr records and replication slots of nodes
+// which are neither listed in the spec nor deployed, so a new node with the
+// same name doesn't rejoin with the old id
+func unregisterRemovedNodes(request *PostgreSQLRequest, specNodes map[string]postgresqlv1.PostgreSQLNode, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	if primaryNode == nil || !primaryNode.isReady() || request.isReplica() {
+		return nil
+	}
+	db := primaryNode.dbClient()
+	registered, err := db.registeredNodes(request.ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to list nodes registered in repmgr: %v", err)
+	}
+	var failed []string
+	for _, name := range registered {
+		// the witness is unregistered by reconcileWitness
+		if _, ok := specNodes[name]; ok || name == primaryNode.name() || name == witnessName(request.cluster.Name) {
+			continue
+		}
+		if _, ok := nodes[name]; ok {
+			continue
+		}
+		// the record keeps name of the slot, so it is removed only after the slot
+		slot, err := db.dropNodeSlot(request.ctx, name)
+		if err != nil {
+			logrus.Errorf("Failed to drop replication slot of removed node %v: %v", name, err)
+			request.recordWarning(NodeCleanupFailed, "Failed to drop replication slot of removed node %v: %v", name, err)
+			failed = append(failed, fmt.Sprintf("%v (replication slot: %v)", name, err))
+			continue
+		}
+		if err := db.unregisterNode(request.ctx, name); err != nil {
+			logrus.Errorf("Failed to unregister removed node %v: %v", name, err)
+			request.recordWarning(NodeCleanupFailed, "Failed to unregister removed node %v: %v", name, err)
+			failed = append(failed, fmt.Sprintf("%v (repmgr record: %v)", name, err))
+			continue
+		}
+		if slot != "" {
+			request.recordNormal(NodeUnregistered, "Node %v unregistered from repmgr, replication slot %v dropped", name, slot)
+		} else {
+			request.recordNormal(NodeUnregistered, "Node %v unregistered from repmgr", name)
+		}
+	}
+	updateNodeCleanupCondition(clusterStatus, failed)
 	return nil
 }
+
+// updateNodeCleanupCondition reports removed nodes which couldn't be cleaned up
+func updateNodeCleanupCondition(clusterStatus *postgresqlv1.PostgreSQLStatus, failed []string) {
+	if len(failed) > 0 {
+		sort.Strings(failed)
+		setCondition(clusterStatus, postgresqlv1.NodeCleanupFailed, corev1.ConditionTrue, "CleanupFailed",
+			fmt.Sprintf("Removed nodes not cleaned up: %v", strings.Join(failed, ", ")))
+		return
+	}
+	if condition := getCondition(clusterStatus, postgresqlv1.NodeCleanupFailed); condition != nil && condition.Status == corev1.ConditionTrue {
+		setCondition(clusterStatus, postgresqlv1.NodeCleanupFailed, corev1.ConditionFalse, "Resolved", "All removed nodes were cleaned up")
+	}
+}
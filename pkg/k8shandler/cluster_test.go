@@ -1,8 +1,22 @@
 package k8shandler
 
 import (
+	"context"
+	"fmt"
+	"strings"
 	"testing"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	"github.com/mcyprian/postgresql-operator/pkg/apis"
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 )
 
@@ -56,3 +70,308 @@ func TestGetHighestPriority(t *testing.T) {
 		}
 	}
 }
+
+// newTestRequest returns request of the example cluster backed by fake client,
+// deployments of the deployed nodes are created ready with the given ids
+func newTestRequest(t *testing.T, specNodes map[string]int, deployed map[string]int) (*PostgreSQLRequest, *record.FakeRecorder) {
+	s := runtime.NewScheme()
+	if err := scheme.AddToScheme(s); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if err := apis.AddToScheme(s); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	cluster := &postgresqlv1.PostgreSQL{
+		TypeMeta:   metav1.TypeMeta{APIVersion: postgresqlv1.SchemeGroupVersion.String(), Kind: "PostgreSQL"},
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec: postgresqlv1.PostgreSQLSpec{
+			ManagementState: postgresqlv1.ManagementStateManaged,
+			Nodes:           make(map[string]postgresqlv1.PostgreSQLNode),
+		},
+		Status: postgresqlv1.PostgreSQLStatus{Nodes: make(map[string]postgresqlv1.PostgreSQLNodeStatus)},
+	}
+	for name, priority := range specNodes {
+		cluster.Spec.Nodes[name] = postgresqlv1.PostgreSQLNode{Priority: priority}
+	}
+	for name := range deployed {
+		cluster.Status.Nodes[name] = postgresqlv1.PostgreSQLNodeStatus{DeploymentName: name, ServiceName: name}
+	}
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Data: map[string][]byte{
+			defaultDatabasePasswordKey: []byte("database-secret"),
+			defaultRepmgrPasswordKey:   []byte("repmgr-secret"),
+		},
+	}
+	recorder := record.NewFakeRecorder(100)
+	request := NewPostgreSQLRequest(context.Background(), fake.NewFakeClientWithScheme(s, cluster, secret), cluster.DeepCopy(), s, recorder, nil)
+	for name, id := range deployed {
+		specNode := cluster.Spec.Nodes[name]
+		deployment := newDeployment(request, name, &specNode, id, StandbyRegister)
+		deployment.Status.ReadyReplicas = 1
+		if err := request.client.Create(request.ctx, deployment); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+	}
+	return request, recorder
+}
+
+// containerEnv returns value of the environment variable of the first container
+func containerEnv(deployment *appsv1.Deployment, name string) string {
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// recordedReasons returns reasons of all events emitted so far
+func recordedReasons(recorder *record.FakeRecorder) []string {
+	var reasons []string
+	for {
+		select {
+		case event := <-recorder.Events:
+			reasons = append(reasons, strings.Fields(event)[1])
+		default:
+			return reasons
+		}
+	}
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+func TestCreateOrUpdateCluster(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	two, three := int32(2), int32(3)
+
+	table := []struct {
+		name      string
+		specNodes map[string]int
+		instances *int32
+		deployed  map[string]int
+		setup     func(repmgr *fakeRepmgr)
+		// change is applied to the topology between the first and the second reconcile
+		change func(repmgr *fakeRepmgr)
+		verify func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error
+	}{
+		{
+			name:      "failover detection",
+			specNodes: map[string]int{"node-one": 100, "node-two": 80},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+			},
+			change: func(repmgr *fakeRepmgr) {
+				repmgr.Stop("node-one")
+				repmgr.Promote("node-two")
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if primaryNode.name() != "node-two" {
+					return fmt.Errorf("expected primary: 'node-two', got: '%v'", primaryNode.name())
+				}
+				service := &corev1.Service{}
+				if err := request.client.Get(request.ctx, types.NamespacedName{Name: "postgresql-primary", Namespace: "default"}, service); err != nil {
+					return err
+				}
+				if selector := service.Spec.Selector["node-name"]; selector != "node-two" {
+					return fmt.Errorf("expected primary service selector: 'node-two', got: '%v'", selector)
+				}
+				if !containsString(reasons, FailoverDetected) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", FailoverDetected, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "rejoin with id of the deleted node",
+			specNodes: map[string]int{"node-one": 100, "node-two": 80},
+			deployed:  map[string]int{"node-one": 1},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.Register("node-two", 7, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				deployment := &appsv1.Deployment{}
+				if err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-two", Namespace: "default"}, deployment); err != nil {
+					return err
+				}
+				if id := containerEnv(deployment, "NODE_ID"); id != "7" {
+					return fmt.Errorf("expected NODE_ID: '7', got: '%v'", id)
+				}
+				if operation := containerEnv(deployment, "STARTUP_OPERATION"); operation != NodeRejoin {
+					return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", NodeRejoin, operation)
+				}
+				if idSequence != 0 {
+					return fmt.Errorf("expected id sequence: '0', got: '%v'", idSequence)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "deletion of extra node",
+			specNodes: map[string]int{"node-one": 100},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				deployment := &appsv1.Deployment{}
+				err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-two", Namespace: "default"}, deployment)
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("expected deployment of node-two to be deleted, got: '%v'", err)
+				}
+				if _, ok := nodes["node-two"]; ok {
+					return fmt.Errorf("node-two is still present in nodes")
+				}
+				if _, ok := request.cluster.Status.Nodes["node-two"]; ok {
+					return fmt.Errorf("node-two is still present in status of the cluster")
+				}
+				if !containsString(reasons, NodeDeleted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", NodeDeleted, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "scale up generates nodes",
+			instances: &three,
+			deployed:  map[string]int{"example-1": 1},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("example-1", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				for _, name := range []string{"example-2", "example-3"} {
+					if err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: "default"}, &appsv1.Deployment{}); err != nil {
+						return fmt.Errorf("expected deployment of %v, got: '%v'", name, err)
+					}
+				}
+				if instances := request.cluster.Status.Instances; instances != 3 {
+					return fmt.Errorf("expected instances: '3', got: '%v'", instances)
+				}
+				if selector := request.cluster.Status.Selector; selector != "cluster-name=example" {
+					return fmt.Errorf("expected selector: 'cluster-name=example', got: '%v'", selector)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "scale down removes the most lagging standby",
+			instances: &two,
+			deployed:  map[string]int{"example-1": 1, "example-2": 2, "example-3": 3},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("example-1", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("example-2", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 99)
+				repmgr.AddNode("example-3", 3, postgresqlv1.PostgreSQLNodeRoleStandby, 98)
+				repmgr.SetLag("example-2", 4096)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				err := request.client.Get(request.ctx, types.NamespacedName{Name: "example-2", Namespace: "default"}, &appsv1.Deployment{})
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("expected deployment of example-2 to be deleted, got: '%v'", err)
+				}
+				if _, ok := nodes["example-3"]; !ok {
+					return fmt.Errorf("example-3 was removed instead of the lagging standby")
+				}
+				registered, err := primaryNode.dbClient().isRegistered(request.ctx, "example-2")
+				if err != nil || registered {
+					return fmt.Errorf("expected example-2 to be unregistered, got: '%v', '%v'", registered, err)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "cleanup of removed nodes",
+			specNodes: map[string]int{"node-one": 100},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+				repmgr.Stop("node-two")
+				repmgr.Register("node-three", 3, postgresqlv1.PostgreSQLNodeRoleStandby, 60)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				for _, name := range []string{"node-two", "node-three"} {
+					registered, err := primaryNode.dbClient().isRegistered(request.ctx, name)
+					if err != nil || registered {
+						return fmt.Errorf("expected %v to be unregistered, got: '%v', '%v'", name, registered, err)
+					}
+				}
+				if repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("replication slot of node-two was not dropped")
+				}
+				if !containsString(reasons, NodeUnregistered) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", NodeUnregistered, reasons)
+				}
+				if condition := getCondition(&request.cluster.Status, postgresqlv1.NodeCleanupFailed); condition != nil {
+					return fmt.Errorf("unexpected condition: '%v'", condition)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "cleanup of node with active slot",
+			specNodes: map[string]int{"node-one": 100},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				registered, err := primaryNode.dbClient().isRegistered(request.ctx, "node-two")
+				if err != nil || !registered {
+					return fmt.Errorf("expected node-two to stay registered until its slot is dropped, got: '%v', '%v'", registered, err)
+				}
+				if !containsString(reasons, NodeCleanupFailed) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", NodeCleanupFailed, reasons)
+				}
+				condition := getCondition(&request.cluster.Status, postgresqlv1.NodeCleanupFailed)
+				if condition == nil || condition.Status != corev1.ConditionTrue || !strings.Contains(condition.Message, "node-two") {
+					return fmt.Errorf("expected condition %v reporting node-two, got: '%v'", postgresqlv1.NodeCleanupFailed, condition)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		tt.setup(repmgr)
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, tt.specNodes, tt.deployed)
+		if tt.instances != nil {
+			request.cluster.Spec.Instances = tt.instances
+			if err := request.client.Update(request.ctx, request.cluster); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if tt.change != nil {
+			tt.change(repmgr)
+			if _, err := request.CreateOrUpdateCluster(); err != nil {
+				t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+				continue
+			}
+		}
+		if err := tt.verify(request, repmgr, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
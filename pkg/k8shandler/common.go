@@ -1,10 +1,17 @@
 package k8shandler
 
 import (
+	"strconv"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
 
+// fencedLabel marks pods of fenced nodes, the read-only service selects only
+// pods which are not fenced
+const fencedLabel = "fenced"
+
 func newLabels(clusterName, selectorName string) map[string]string {
 	labels := map[string]string{
 		"cluster-name": clusterName,
@@ -15,54 +22,61 @@ func newLabels(clusterName, selectorName string) map[string]string {
 	return labels
 }
 
-func newImage(image string) string {
-	if image == "" {
-		return defaultPgImage
-	}
-	return image
+// newPodLabels returns labels of pods of the node, the deployment selects them
+// by labels of the node only, so the fenced label may change on running pods
+func newPodLabels(clusterName, nodeName string, fenced bool) map[string]string {
+	labels := newLabels(clusterName, nodeName)
+	labels[fencedLabel] = strconv.FormatBool(fenced)
+	return labels
 }
 
-func newResourceRequirements(resRequirements corev1.ResourceRequirements) corev1.ResourceRequirements {
-	var requestMem *resource.Quantity
-	var limitMem *resource.Quantity
-	var requestCPU *resource.Quantity
-	var limitCPU *resource.Quantity
-
-	// Memory
-	if resRequirements.Requests.Memory().IsZero() {
-		rMem, _ := resource.ParseQuantity(defaultMemoryRequest)
-		requestMem = &rMem
-	} else {
-		requestMem = resRequirements.Requests.Memory()
-	}
-	if resRequirements.Limits.Memory().IsZero() {
-		lMem, _ := resource.ParseQuantity(defaultMemoryLimit)
-		limitMem = &lMem
-	} else {
-		limitMem = resRequirements.Limits.Memory()
-	}
-	// CPU
-	if resRequirements.Requests.Cpu().IsZero() {
-		rCPU, _ := resource.ParseQuantity(defaultCPURequest)
-		requestCPU = &rCPU
-	} else {
-		requestCPU = resRequirements.Requests.Cpu()
-	}
-	if resRequirements.Limits.Cpu().IsZero() {
-		lCPU, _ := resource.ParseQuantity(defaultCPULimit)
-		limitCPU = &lCPU
-	} else {
-		limitCPU = resRequirements.Limits.Cpu()
+// newImage returns image of the node, falling back to the template of the
+// cluster and the operator default
+func newImage(template *postgresqlv1.PostgreSQLNodeTemplate, image string) string {
+	switch {
+	case image != "":
+		return image
+	case template.Image != "":
+		return template.Image
 	}
+	return *defaultPgImage
+}
 
+// newResourceRequirements returns resources of the node, each quantity not set
+// on the node is taken from the template of the cluster or the operator default
+func newResourceRequirements(template *postgresqlv1.PostgreSQLNodeTemplate, resRequirements corev1.ResourceRequirements) corev1.ResourceRequirements {
 	return corev1.ResourceRequirements{
 		Limits: corev1.ResourceList{
-			corev1.ResourceCPU:    *limitCPU,
-			corev1.ResourceMemory: *limitMem,
+			corev1.ResourceCPU:    mergeQuantity(corev1.ResourceCPU, *defaultCPULimit, resRequirements.Limits, template.Resources.Limits),
+			corev1.ResourceMemory: mergeQuantity(corev1.ResourceMemory, *defaultMemoryLimit, resRequirements.Limits, template.Resources.Limits),
 		},
 		Requests: corev1.ResourceList{
-			corev1.ResourceCPU:    *requestCPU,
-			corev1.ResourceMemory: *requestMem,
+			corev1.ResourceCPU:    mergeQuantity(corev1.ResourceCPU, *defaultCPURequest, resRequirements.Requests, template.Resources.Requests),
+			corev1.ResourceMemory: mergeQuantity(corev1.ResourceMemory, *defaultMemoryRequest, resRequirements.Requests, template.Resources.Requests),
 		},
 	}
 }
+
+// mergeQuantity returns the first non-zero quantity of the resource in the lists
+func mergeQuantity(name corev1.ResourceName, fallback string, lists ...corev1.ResourceList) resource.Quantity {
+	for _, list := range lists {
+		if quantity, ok := list[name]; ok && !quantity.IsZero() {
+			return quantity
+		}
+	}
+	quantity, _ := resource.ParseQuantity(fallback)
+	return quantity
+}
+
+// mergeStorage returns storage of the node, fields not set on the node are
+// taken from the template of the cluster
+func mergeStorage(template *postgresqlv1.PostgreSQLNodeTemplate, specVol *postgresqlv1.PostgreSQLStorageSpec) *postgresqlv1.PostgreSQLStorageSpec {
+	storage := specVol.DeepCopy()
+	if storage.StorageClassName == nil {
+		storage.StorageClassName = template.Storage.DeepCopy().StorageClassName
+	}
+	if storage.Size == nil {
+		storage.Size = template.Storage.DeepCopy().Size
+	}
+	return storage
+}
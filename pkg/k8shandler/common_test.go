@@ -4,6 +4,7 @@ import (
 	"reflect"
 	"testing"
 
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/resource"
 )
@@ -38,11 +39,11 @@ func TestNewLabels(t *testing.T) {
 }
 
 func TestNewResourceRequirements(t *testing.T) {
-	lCPU, _ := resource.ParseQuantity(defaultCPULimit)
-	lMem, _ := resource.ParseQuantity(defaultMemoryLimit)
+	lCPU, _ := resource.ParseQuantity(*defaultCPULimit)
+	lMem, _ := resource.ParseQuantity(*defaultMemoryLimit)
 
-	rCPU, _ := resource.ParseQuantity(defaultCPURequest)
-	rMem, _ := resource.ParseQuantity(defaultMemoryRequest)
+	rCPU, _ := resource.ParseQuantity(*defaultCPURequest)
+	rMem, _ := resource.ParseQuantity(*defaultMemoryRequest)
 
 	lCPUCustom, _ := resource.ParseQuantity("2000m")
 	lMemCustom, _ := resource.ParseQuantity("500Mi")
@@ -50,11 +51,26 @@ func TestNewResourceRequirements(t *testing.T) {
 	rCPUCustom, _ := resource.ParseQuantity("50m")
 	rMemCustom, _ := resource.ParseQuantity("250Mi")
 
+	lMemTemplate, _ := resource.ParseQuantity("2Gi")
+	rCPUTemplate, _ := resource.ParseQuantity("200m")
+	template := &postgresqlv1.PostgreSQLNodeTemplate{
+		Resources: corev1.ResourceRequirements{
+			Limits: corev1.ResourceList{
+				corev1.ResourceMemory: lMemTemplate,
+			},
+			Requests: corev1.ResourceList{
+				corev1.ResourceCPU: rCPUTemplate,
+			},
+		},
+	}
+
 	table := []struct {
+		template        *postgresqlv1.PostgreSQLNodeTemplate
 		resRequirements corev1.ResourceRequirements
 		expected        corev1.ResourceRequirements
 	}{
 		{
+			&postgresqlv1.PostgreSQLNodeTemplate{},
 			corev1.ResourceRequirements{},
 			corev1.ResourceRequirements{
 				Limits: corev1.ResourceList{
@@ -68,6 +84,7 @@ func TestNewResourceRequirements(t *testing.T) {
 			},
 		},
 		{
+			template,
 			corev1.ResourceRequirements{
 				Limits: corev1.ResourceList{
 					corev1.ResourceCPU:    lCPUCustom,
@@ -90,12 +107,48 @@ func TestNewResourceRequirements(t *testing.T) {
 				},
 			},
 		},
+		{
+			template,
+			corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU: lCPUCustom,
+				},
+			},
+			corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{
+					corev1.ResourceCPU:    lCPUCustom,
+					corev1.ResourceMemory: lMemTemplate,
+				},
+				Requests: corev1.ResourceList{
+					corev1.ResourceCPU:    rCPUTemplate,
+					corev1.ResourceMemory: rMem,
+				},
+			},
+		},
 	}
 	for _, tt := range table {
-		actual := newResourceRequirements(tt.resRequirements)
+		actual := newResourceRequirements(tt.template, tt.resRequirements)
 		eq := reflect.DeepEqual(actual, tt.expected)
 		if !eq {
 			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
 		}
 	}
 }
+
+func TestNewImage(t *testing.T) {
+	table := []struct {
+		template *postgresqlv1.PostgreSQLNodeTemplate
+		image    string
+		expected string
+	}{
+		{&postgresqlv1.PostgreSQLNodeTemplate{}, "", *defaultPgImage},
+		{&postgresqlv1.PostgreSQLNodeTemplate{Image: "postgresql:11"}, "", "postgresql:11"},
+		{&postgresqlv1.PostgreSQLNodeTemplate{Image: "postgresql:11"}, "postgresql:12", "postgresql:12"},
+	}
+	for _, tt := range table {
+		actual := newImage(tt.template, tt.image)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
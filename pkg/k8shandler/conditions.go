@@ -0,0 +1,40 @@
+package k8shandler
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// setCondition adds or updates condition of the given type, transition time is
+// changed only if the condition status differs from the previous one
+func setCondition(status *postgresqlv1.PostgreSQLStatus, conditionType postgresqlv1.PostgreSQLConditionType, conditionStatus corev1.ConditionStatus, reason, message string) {
+	condition := postgresqlv1.PostgreSQLCondition{
+		Type:               conditionType,
+		Status:             conditionStatus,
+		LastTransitionTime: metav1.Now(),
+		Reason:             reason,
+		Message:            message,
+	}
+	for i, current := range status.Conditions {
+		if current.Type == conditionType {
+			if current.Status == conditionStatus {
+				condition.LastTransitionTime = current.LastTransitionTime
+			}
+			status.Conditions[i] = condition
+			return
+		}
+	}
+	status.Conditions = append(status.Conditions, condition)
+}
+
+// getCondition returns condition of the given type or nil if it isn't present
+func getCondition(status *postgresqlv1.PostgreSQLStatus, conditionType postgresqlv1.PostgreSQLConditionType) *postgresqlv1.PostgreSQLCondition {
+	for i := range status.Conditions {
+		if status.Conditions[i].Type == conditionType {
+			return &status.Conditions[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,36 @@
+package k8shandler
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestSetCondition(t *testing.T) {
+	status := &postgresqlv1.PostgreSQLStatus{}
+
+	setCondition(status, postgresqlv1.SplitBrain, corev1.ConditionTrue, "MultiplePrimaries", "first")
+	if len(status.Conditions) != 1 {
+		t.Fatalf("Test failed, expected 1 condition, got: %v", len(status.Conditions))
+	}
+	transition := status.Conditions[0].LastTransitionTime
+
+	setCondition(status, postgresqlv1.SplitBrain, corev1.ConditionTrue, "MultiplePrimaries", "second")
+	condition := getCondition(status, postgresqlv1.SplitBrain)
+	if len(status.Conditions) != 1 || condition == nil {
+		t.Fatalf("Test failed, expected a single SplitBrain condition, got: %v", status.Conditions)
+	}
+	if condition.Message != "second" {
+		t.Errorf("Test failed, expected message: 'second', got: '%v'", condition.Message)
+	}
+	if !condition.LastTransitionTime.Equal(&transition) {
+		t.Errorf("Test failed, transition time changed without status change")
+	}
+
+	setCondition(status, postgresqlv1.SplitBrain, corev1.ConditionFalse, "Resolved", "")
+	if condition := getCondition(status, postgresqlv1.SplitBrain); condition.Status != corev1.ConditionFalse {
+		t.Errorf("Test failed, expected status: '%v', got: '%v'", corev1.ConditionFalse, condition.Status)
+	}
+}
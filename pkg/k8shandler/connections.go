@@ -0,0 +1,149 @@
+package k8shandler
+
+import (
+	"context"
+	"database/sql"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	maxOpenConnections    = 2
+	maxIdleConnections    = 1
+	connectionMaxLifetime = 10 * time.Minute
+	// connectionCheckPeriod is the minimal interval between health checks of a client
+	connectionCheckPeriod = 30 * time.Second
+)
+
+// connectionKey identifies repmgr database client of a single node
+type connectionKey struct {
+	namespace string
+	cluster   string
+	node      string
+}
+
+// connectionManager keeps one repmgr database client per node of every cluster
+type connectionManager struct {
+	mutex   sync.Mutex
+	clients map[connectionKey]*database
+}
+
+var connections connector = newConnectionManager()
+
+func newConnectionManager() *connectionManager {
+	return &connectionManager{clients: make(map[connectionKey]*database)}
+}
+
+// client returns not yet opened client of the node, it is opened by connect
+func (m *connectionManager) client(key connectionKey, host, password string) sqlBackend {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if db, ok := m.clients[key]; ok {
+		return db
+	}
+	return newRepmgrDatabase(host, password)
+}
+
+// connect returns client of the node connected to the host with the password.
+// The client is reopened when the host or the password changed and health of its
+// connections is checked at most once per connectionCheckPeriod. The check runs
+// without holding the lock, so unreachable nodes don't block other clusters.
+func (m *connectionManager) connect(ctx context.Context, key connectionKey, host, password string) (sqlBackend, error) {
+	db, engine, err := m.prepare(key, host, password)
+	if err != nil || engine == nil {
+		return db, err
+	}
+	pingCtx, cancel := db.withTimeout(ctx)
+	err = engine.PingContext(pingCtx)
+	cancel()
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if db.engine != engine {
+		// the client was reopened or closed meanwhile, the result is stale
+		return db, err
+	}
+	if err != nil {
+		// drop possibly broken connections, the pool is opened again on the next connect
+		db.close()
+		return db, err
+	}
+	db.lastCheck = time.Now()
+	return db, nil
+}
+
+// prepare returns client of the node opened with the host and the password,
+// the engine is returned only when health check of the client is due
+func (m *connectionManager) prepare(key connectionKey, host, password string) (*database, *sql.DB, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	db, ok := m.clients[key]
+	if !ok {
+		db = newRepmgrDatabase(host, password)
+		m.clients[key] = db
+	}
+	if db.engine != nil && (db.info.host != host || db.info.password != password) {
+		logrus.Infof("Connection parameters of node %v changed, reconnecting", key.node)
+		if err := db.close(); err != nil {
+			logrus.Errorf("Failed to close connections of node %v: %v", key.node, err)
+		}
+	}
+	db.info.host = host
+	db.info.password = password
+	if db.engine == nil {
+		if err := db.open(); err != nil {
+			return db, nil, err
+		}
+	}
+	if time.Since(db.lastCheck) < connectionCheckPeriod {
+		return db, nil, nil
+	}
+	return db, db.engine, nil
+}
+
+// close closes client of the node
+func (m *connectionManager) close(key connectionKey) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	if db, ok := m.clients[key]; ok {
+		if err := db.close(); err != nil {
+			logrus.Errorf("Failed to close connections of node %v: %v", key.node, err)
+		}
+		delete(m.clients, key)
+	}
+}
+
+// closeCluster closes clients of all nodes of the cluster
+func (m *connectionManager) closeCluster(namespace, cluster string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	for key, db := range m.clients {
+		if key.namespace == namespace && key.cluster == cluster {
+			if err := db.close(); err != nil {
+				logrus.Errorf("Failed to close connections of node %v: %v", key.node, err)
+			}
+			delete(m.clients, key)
+		}
+	}
+}
+
+// CloseConnections closes database connections of all nodes of the removed cluster
+func CloseConnections(namespace, cluster string) {
+	connections.closeCluster(namespace, cluster)
+}
+
+func (request *PostgreSQLRequest) connectionKey(nodeName string) connectionKey {
+	return connectionKey{namespace: request.cluster.Namespace, cluster: request.cluster.Name, node: nodeName}
+}
+
+// nodeHost returns IP address of the running pod of the node, so the operator
+// can reach fenced nodes and notices recreated pods. Name of the node service
+// is used until the pod is running.
+func (request *PostgreSQLRequest) nodeHost(nodeName string) string {
+	pod, err := request.runningPod(nodeName)
+	if err != nil || pod.Status.PodIP == "" {
+		return nodeName
+	}
+	return pod.Status.PodIP
+}
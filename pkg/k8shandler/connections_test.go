@@ -0,0 +1,56 @@
+package k8shandler
+
+import (
+	"context"
+	"testing"
+)
+
+func TestConnectionManagerConnect(t *testing.T) {
+	manager := newConnectionManager()
+	key := connectionKey{namespace: "default", cluster: "example", node: "node-one"}
+	first, _ := manager.connect(context.Background(), key, "127.0.0.1", "secret")
+	second, _ := manager.connect(context.Background(), key, "localhost", "changed")
+	if first != second {
+		t.Errorf("Test failed, client of the node was not reused")
+	}
+	info := second.(*database).info
+	if info.host != "localhost" || info.password != "changed" {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", "localhost changed", info.host+" "+info.password)
+	}
+}
+
+func TestConnectionManagerFailedCheck(t *testing.T) {
+	manager := newConnectionManager()
+	key := connectionKey{namespace: "default", cluster: "example", node: "node-one"}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	db, err := manager.connect(ctx, key, "127.0.0.1", "secret")
+	if err == nil {
+		t.Fatalf("Test failed, health check of the cancelled connect succeeded")
+	}
+	if db.(*database).engine != nil {
+		t.Errorf("Test failed, connections of the client were not closed after failed health check")
+	}
+}
+
+func TestConnectionManagerCloseCluster(t *testing.T) {
+	manager := newConnectionManager()
+	keys := []connectionKey{
+		{namespace: "default", cluster: "example", node: "node-one"},
+		{namespace: "default", cluster: "example", node: "node-two"},
+		{namespace: "default", cluster: "other", node: "node-one"},
+		{namespace: "test", cluster: "example", node: "node-one"},
+	}
+	for _, key := range keys {
+		manager.clients[key] = newRepmgrDatabase(key.node, "secret")
+	}
+	manager.closeCluster("default", "example")
+	if len(manager.clients) != 2 {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", 2, len(manager.clients))
+	}
+	for _, key := range keys[2:] {
+		if _, ok := manager.clients[key]; !ok {
+			t.Errorf("Test failed, client of %v was closed", key)
+		}
+	}
+}
@@ -6,8 +6,57 @@ import (
 	corev1 "k8s.io/api/core/v1"
 )
 
+// setContainerEnv sets value of the environment variable, the variable is
+// appended if the container doesn't define it yet
+func setContainerEnv(container *corev1.Container, name, value string) {
+	setContainerEnvVar(container, corev1.EnvVar{Name: name, Value: value})
+}
+
+func setContainerEnvVar(container *corev1.Container, envVar corev1.EnvVar) {
+	for i, env := range container.Env {
+		if env.Name == envVar.Name {
+			container.Env[i] = envVar
+			return
+		}
+	}
+	container.Env = append(container.Env, envVar)
+}
+
+// containerEnvValue returns value of the environment variable of the container
+func containerEnvValue(container *corev1.Container, name string) string {
+	for _, env := range container.Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// removeContainerEnv removes the environment variable from the container
+func removeContainerEnv(container *corev1.Container, name string) {
+	for i, env := range container.Env {
+		if env.Name == name {
+			container.Env = append(container.Env[:i], container.Env[i+1:]...)
+			return
+		}
+	}
+}
+
+// newSecretEnvVar returns environment variable populated from the secret key
+func newSecretEnvVar(name, secretName, key string) corev1.EnvVar {
+	return corev1.EnvVar{
+		Name: name,
+		ValueFrom: &corev1.EnvVarSource{
+			SecretKeyRef: &corev1.SecretKeySelector{
+				LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+				Key:                  key,
+			},
+		},
+	}
+}
+
 // newContainer returns a container object for postgresql pod
-func newContainer(name, secretName, image string, resourceRequirements corev1.ResourceRequirements, nodeID int, operation string) corev1.Container {
+func newContainer(name string, secret credentialsSecret, image string, resourceRequirements corev1.ResourceRequirements, nodeID int, operation string) corev1.Container {
 	env := newPgEnvironment()
 	return corev1.Container{
 		Image:   image,
@@ -42,15 +91,7 @@ func newContainer(name, secretName, image string, resourceRequirements corev1.Re
 				Name:  "POSTGRESQL_USER",
 				Value: env.user,
 			},
-			corev1.EnvVar{
-				Name: "POSTGRESQL_PASSWORD",
-				ValueFrom: &corev1.EnvVarSource{
-					SecretKeyRef: &corev1.SecretKeySelector{
-						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
-						Key:                  "database-password",
-					},
-				},
-			},
+			newSecretEnvVar("POSTGRESQL_PASSWORD", secret.name, secret.databaseKey),
 			corev1.EnvVar{
 				Name:  "POSTGRESQL_DATABASE",
 				Value: env.database,
@@ -67,15 +108,7 @@ func newContainer(name, secretName, image string, resourceRequirements corev1.Re
 				Name:  "ENABLE_REPMGR",
 				Value: "true",
 			},
-			corev1.EnvVar{
-				Name: "REPMGR_PASSWORD",
-				ValueFrom: &corev1.EnvVarSource{
-					SecretKeyRef: &corev1.SecretKeySelector{
-						LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
-						Key:                  "repmgr-password",
-					},
-				},
-			},
+			newSecretEnvVar("REPMGR_PASSWORD", secret.name, secret.repmgrKey),
 			corev1.EnvVar{
 				Name:  "NODE_NAME",
 				Value: name,
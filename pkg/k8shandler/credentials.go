@@ -0,0 +1,170 @@
+package k8shandler
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// RotateCredentialsAnnotation requests immediate rotation of generated passwords
+	RotateCredentialsAnnotation = "postgresql.openshift.io/rotate-credentials"
+
+	// CredentialsRotated is emitted when new passwords were generated
+	CredentialsRotated = "CredentialsRotated"
+	// CredentialsApplied is emitted when passwords from the secret were applied to the database roles
+	CredentialsApplied = "CredentialsApplied"
+
+	// InvalidCredentialsSecret is emitted when the secret referenced in the spec lacks required keys
+	InvalidCredentialsSecret = "InvalidCredentialsSecret"
+	// RotationRejected is emitted when rotation of passwords of the secret provided by the user is requested
+	RotationRejected = "RotationRejected"
+
+	defaultDatabasePasswordKey = "database-password"
+	defaultRepmgrPasswordKey   = "repmgr-password"
+)
+
+// CreateOrUpdateCredentials rotates passwords when requested and keeps passwords
+// of database roles in sync with the secret
+func (request *PostgreSQLRequest) CreateOrUpdateCredentials() error {
+	if primaryNode == nil || !primaryNode.isReady() {
+		return nil
+	}
+	clusterStatus := request.cluster.Status.DeepCopy()
+	if clusterStatus.Credentials == nil {
+		clusterStatus.Credentials = &postgresqlv1.PostgreSQLCredentialsStatus{}
+	}
+	credentials := clusterStatus.Credentials
+	ref := request.credentialsSecret()
+
+	if ref.external || request.isReplica() {
+		if _, ok := request.cluster.Annotations[RotateCredentialsAnnotation]; ok {
+			if ref.external {
+				request.recordWarning(RotationRejected, "Passwords in secret %v are managed by the user", ref.name)
+			} else {
+				request.recordWarning(RotationRejected, "Passwords of the replica cluster are copied from the source cluster")
+			}
+			if err := request.updateAnnotation(RotateCredentialsAnnotation, ""); err != nil {
+				logrus.Errorf("Failed to remove %v annotation: %v", RotateCredentialsAnnotation, err)
+			}
+		}
+	} else if credentials.SecretVersion != "" && request.isRotationDue(credentials) {
+		if err := request.rotateCredentials(); err != nil {
+			return err
+		}
+		now := metav1.Now()
+		credentials.LastRotation = &now
+		// the rotation is recorded before the passwords are applied, the
+		// secret version left in the status makes failed apply retried with
+		// the stored passwords instead of rotating them again
+		if err := UpdateClusterStatus(request, clusterStatus); err != nil {
+			return err
+		}
+		request.recordNormal(CredentialsRotated, "New passwords were generated")
+		if err := request.updateAnnotation(RotateCredentialsAnnotation, ""); err != nil {
+			logrus.Errorf("Failed to remove %v annotation: %v", RotateCredentialsAnnotation, err)
+		}
+	}
+
+	secret, err := getSecret(request.ctx, ref.name, request.cluster.Namespace, request.client)
+	if err != nil {
+		return err
+	}
+	if err := ref.validateSecretData(secret.Data); err != nil {
+		return err
+	}
+	version := secretVersion(secret)
+	if credentials.SecretVersion != "" && credentials.SecretVersion != version {
+		logrus.Infof("Secret %v changed, applying its passwords to database roles", ref.name)
+		if err := request.applyCredentials(ref, secret.Data); err != nil {
+			return err
+		}
+		request.recordNormal(CredentialsApplied, "Passwords from secret %v applied to database roles", ref.name)
+	}
+	credentials.SecretVersion = version
+	if credentials.LastRotation == nil {
+		now := metav1.Now()
+		credentials.LastRotation = &now
+	}
+	return UpdateClusterStatus(request, clusterStatus)
+}
+
+// isRotationDue checks whether the rotation was requested by the annotation
+// or the rotation period elapsed
+func (request *PostgreSQLRequest) isRotationDue(credentials *postgresqlv1.PostgreSQLCredentialsStatus) bool {
+	if _, ok := request.cluster.Annotations[RotateCredentialsAnnotation]; ok {
+		return true
+	}
+	spec := request.cluster.Spec.Credentials
+	if spec == nil || spec.RotationPeriod == nil || credentials.LastRotation == nil {
+		return false
+	}
+	return time.Since(credentials.LastRotation.Time) >= spec.RotationPeriod.Duration
+}
+
+// rotateCredentials generates new passwords and stores them using the provider
+func (request *PostgreSQLRequest) rotateCredentials() error {
+	provider, err := request.newCredentialProvider()
+	if err != nil {
+		return err
+	}
+	passwords, err := generatePgPasswords()
+	if err != nil {
+		return err
+	}
+	logrus.Infof("Rotating passwords of cluster %v", request.cluster.Name)
+	return request.storePasswords(provider, passwords)
+}
+
+// applyCredentials sets passwords of database roles on the primary, updates
+// .pgpass files of running nodes, reconnects repmgr connections and restarts
+// the witness node
+func (request *PostgreSQLRequest) applyCredentials(ref credentialsSecret, secretData map[string][]byte) error {
+	repmgrPassword := string(secretData[ref.repmgrKey])
+	// roles of the replica cluster are replicated from the source cluster
+	if !request.isReplica() {
+		db := primaryNode.dbClient()
+		if err := db.setPassword(request.ctx, repmgrUser, repmgrPassword); err != nil {
+			return fmt.Errorf("Failed to change passwords on primary node %v: %v", primaryNode.name(), err)
+		}
+		if err := db.setPassword(request.ctx, newPgEnvironment().user, string(secretData[ref.databaseKey])); err != nil {
+			return fmt.Errorf("Failed to change passwords on primary node %v: %v", primaryNode.name(), err)
+		}
+	}
+	for name, node := range nodes {
+		if node.isReady() {
+			if _, err := request.execInNode(name, pgpassCommand(repmgrUser, repmgrPassword)); err != nil {
+				logrus.Errorf("Failed to update .pgpass of node %v: %v", name, err)
+			}
+		}
+		if err := node.updatePassword(request, repmgrPassword); err != nil {
+			logrus.Errorf("Failed to reconnect to node %v: %v", name, err)
+		}
+	}
+	if err := request.restartWitness(); err != nil {
+		logrus.Errorf("Failed to restart witness node: %v", err)
+	}
+	return nil
+}
+
+// pgpassCommand returns command rewriting .pgpass file of the node
+func pgpassCommand(user, password string) []string {
+	return []string{"sh", "-c", `umask 077 && printf '*:*:*:%s:%s\n' "$1" "$2" > "$PGPASSFILE"`, "sh", escapePgpass(user), escapePgpass(password)}
+}
+
+// escapePgpass escapes backslashes and colons in a field of .pgpass file
+func escapePgpass(value string) string {
+	return strings.NewReplacer(`\`, `\\`, ":", `\:`).Replace(value)
+}
+
+// secretVersion identifies content of the secret, it changes whenever the
+// secret is updated or recreated
+func secretVersion(secret *corev1.Secret) string {
+	return fmt.Sprintf("%s/%s", secret.UID, secret.ResourceVersion)
+}
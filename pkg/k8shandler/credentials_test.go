@@ -0,0 +1,137 @@
+package k8shandler
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestIsRotationDue(t *testing.T) {
+	lastRotation := metav1.NewTime(time.Now().Add(-2 * time.Hour))
+	table := []struct {
+		annotations map[string]string
+		spec        *postgresqlv1.PostgreSQLCredentialsSpec
+		expected    bool
+	}{
+		{nil, nil, false},
+		{map[string]string{RotateCredentialsAnnotation: ""}, nil, true},
+		{nil, &postgresqlv1.PostgreSQLCredentialsSpec{RotationPeriod: &metav1.Duration{Duration: time.Hour}}, true},
+		{nil, &postgresqlv1.PostgreSQLCredentialsSpec{RotationPeriod: &metav1.Duration{Duration: 24 * time.Hour}}, false},
+	}
+	for _, tt := range table {
+		request := PostgreSQLRequest{cluster: &postgresqlv1.PostgreSQL{}}
+		request.cluster.Annotations = tt.annotations
+		request.cluster.Spec.Credentials = tt.spec
+		actual := request.isRotationDue(&postgresqlv1.PostgreSQLCredentialsStatus{LastRotation: &lastRotation})
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestSecretVersion(t *testing.T) {
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{UID: "f0c1", ResourceVersion: "41"}}
+	first := secretVersion(secret)
+	secret.ResourceVersion = "42"
+	if second := secretVersion(secret); first == second {
+		t.Errorf("Test failed, version doesn't reflect update of the secret")
+	}
+	if strings.Contains(first, "secret") {
+		t.Errorf("Test failed, version reveals content of the secret: '%v'", first)
+	}
+}
+
+func TestPgpassCommand(t *testing.T) {
+	table := []struct {
+		password string
+		expected string
+	}{
+		{"plain", "plain"},
+		{"with:colon", `with\:colon`},
+		{`back\slash`, `back\\slash`},
+	}
+	for _, tt := range table {
+		command := pgpassCommand(repmgrUser, tt.password)
+		if actual := command[len(command)-1]; actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestApplyCredentials(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	request, repmgr, _ := newSplitBrainTest(t)
+	request.cluster.Spec.Witness = &postgresqlv1.PostgreSQLWitnessSpec{}
+	if err := request.client.Create(request.ctx, newWitnessDeployment(request, 3)); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	ref := request.credentialsSecret()
+	secretData := map[string][]byte{ref.databaseKey: []byte("new-database"), ref.repmgrKey: []byte("new-repmgr")}
+	if err := request.applyCredentials(ref, secretData); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if password := repmgr.Password("node-one", repmgrUser); password != "new-repmgr" {
+		t.Errorf("Test failed, expected: 'new-repmgr', got: '%v'", password)
+	}
+	witness := &appsv1.Deployment{}
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: "example-witness", Namespace: "default"}, witness); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if _, ok := witness.Spec.Template.Annotations[restartedAtAnnotation]; !ok {
+		t.Errorf("Test failed, witness node was not restarted")
+	}
+}
+
+func TestRotateCredentials(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	defer os.Setenv("POSTGRESQL_PASSWORD", os.Getenv("POSTGRESQL_PASSWORD"))
+	os.Setenv("POSTGRESQL_PASSWORD", "bootstrap-password")
+	request, repmgr, recorder := newSplitBrainTest(t)
+	if err := request.CreateOrUpdateCredentials(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	request.cluster.Annotations = map[string]string{RotateCredentialsAnnotation: ""}
+	if err := request.client.Update(request.ctx, request.cluster); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	// passwords can't be applied to the stopped primary
+	repmgr.Stop("node-one")
+	request.CreateOrUpdateCredentials()
+
+	ref := request.credentialsSecret()
+	rotated, err := extractSecret(request.ctx, ref.name, "default", request.client)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if password := string(rotated[ref.databaseKey]); password == "bootstrap-password" || password == "" {
+		t.Errorf("Test failed, password of the database user was not rotated: '%v'", password)
+	}
+	if request.cluster.Status.Credentials == nil || request.cluster.Status.Credentials.LastRotation == nil {
+		t.Errorf("Test failed, rotation was not recorded in status")
+	}
+	if _, ok := request.cluster.Annotations[RotateCredentialsAnnotation]; ok {
+		t.Errorf("Test failed, %v annotation was not removed", RotateCredentialsAnnotation)
+	}
+	if reasons := recordedReasons(recorder); !containsString(reasons, CredentialsRotated) {
+		t.Errorf("Test failed, expected event: '%v', got: '%v'", CredentialsRotated, reasons)
+	}
+
+	request.CreateOrUpdateCredentials()
+	current, err := extractSecret(request.ctx, ref.name, "default", request.client)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	for _, key := range []string{ref.databaseKey, ref.repmgrKey} {
+		if string(current[key]) != string(rotated[key]) {
+			t.Errorf("Test failed, passwords were rotated again instead of applying stored ones")
+		}
+	}
+}
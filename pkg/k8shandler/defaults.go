@@ -1,19 +1,74 @@
 package k8shandler
 
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
 const (
 	postgresqlPort = 5432
 
-	defaultPgImage            = "mcyprian/postgresql-10-fedora29:1.0"
 	defaultPgUser             = "user"
 	defaultPgDatabase         = "db"
 	defaultCntCommand         = "run-repmgr-replica"
 	defaultHealthCheckCommand = "/usr/libexec/check-container"
 
-	defaultCPULimit      = "400m"
-	defaultCPURequest    = "100m"
-	defaultMemoryLimit   = "1Gi"
-	defaultMemoryRequest = "500Mi"
-
 	pgDataPath     = "/var/lib/pgsql/data/"
 	pgpassFilePath = "/var/lib/pgsql/.pgpass"
 )
+
+// Operator-wide defaults of nodes, used when neither the node nor the template
+// of its cluster sets the value
+var (
+	defaultPgImage = flag.String("default-image", "mcyprian/postgresql-10-fedora29:1.1",
+		"Image of PostgreSQL nodes")
+	defaultCPULimit = flag.String("default-cpu-limit", "400m",
+		"CPU limit of PostgreSQL nodes")
+	defaultCPURequest = flag.String("default-cpu-request", "100m",
+		"CPU request of PostgreSQL nodes")
+	defaultMemoryLimit = flag.String("default-memory-limit", "1Gi",
+		"Memory limit of PostgreSQL nodes")
+	defaultMemoryRequest = flag.String("default-memory-request", "500Mi",
+		"Memory request of PostgreSQL nodes")
+	defaultsDir = flag.String("defaults-dir", "/etc/postgresql-operator/defaults",
+		"Directory with defaults of PostgreSQL nodes mounted from a ConfigMap, its keys override the default flags")
+)
+
+// LoadDefaults reads defaults of nodes from the ConfigMap mounted to defaultsDir
+// and checks all the defaults are valid
+func LoadDefaults() error {
+	values := map[string]*string{
+		"image":         defaultPgImage,
+		"cpuLimit":      defaultCPULimit,
+		"cpuRequest":    defaultCPURequest,
+		"memoryLimit":   defaultMemoryLimit,
+		"memoryRequest": defaultMemoryRequest,
+	}
+	for key, value := range values {
+		data, err := ioutil.ReadFile(filepath.Join(*defaultsDir, key))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("Failed to read default %v: %v", key, err)
+		}
+		*value = strings.TrimSpace(string(data))
+		logrus.Infof("Using default %v %v from %v", key, *value, *defaultsDir)
+	}
+	if *defaultPgImage == "" {
+		return fmt.Errorf("Default image must not be empty")
+	}
+	for _, quantity := range []*string{defaultCPULimit, defaultCPURequest, defaultMemoryLimit, defaultMemoryRequest} {
+		if _, err := resource.ParseQuantity(*quantity); err != nil {
+			return fmt.Errorf("Invalid default quantity %v: %v", *quantity, err)
+		}
+	}
+	return nil
+}
@@ -6,14 +6,17 @@ import (
 	corev1 "k8s.io/api/core/v1"
 
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-//newDeployment returns a postgresql node Deployment object
+// newDeployment returns a postgresql node Deployment object
 func newDeployment(request *PostgreSQLRequest, name string, node *postgresqlv1.PostgreSQLNode, nodeID int, operation string) *appsv1.Deployment {
 	var single int32 = 1
 	labels := newLabels(request.cluster.Name, name)
-	resourceRequirements := newResourceRequirements(node.Resources)
+	template := request.template()
+	resourceRequirements := newResourceRequirements(template, node.Resources)
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Deployment",
@@ -34,17 +37,34 @@ func newDeployment(request *PostgreSQLRequest, name string, node *postgresqlv1.P
 			},
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
-					Labels: labels,
+					Labels: newPodLabels(request.cluster.Name, name, false),
 				},
 				Spec: corev1.PodSpec{
 					Hostname:   name,
-					Containers: []corev1.Container{newContainer(name, request.cluster.Name, newImage(node.Image), resourceRequirements, nodeID, operation)},
+					Containers: []corev1.Container{newContainer(name, request.credentialsSecret(), newImage(template, node.Image), resourceRequirements, nodeID, operation)},
 					Volumes:    []corev1.Volume{newVolume(request, name, &node.Storage)},
 				},
 			},
 		},
 	}
+	request.setSlotsEnv(&deployment.Spec.Template.Spec.Containers[0])
+	request.setUpstreamEnv(&deployment.Spec.Template.Spec.Containers[0], name)
 	// Set PostgreSQL instance as the owner and controller
 	controllerutil.SetControllerReference(request.cluster, deployment, request.scheme)
 	return deployment
 }
+
+// scaleDeployment sets number of replicas of the node deployment
+func scaleDeployment(request *PostgreSQLRequest, name string, replicas int32) error {
+	current := &appsv1.Deployment{}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, current); err != nil {
+			return err
+		}
+		if current.Spec.Replicas != nil && *current.Spec.Replicas == replicas {
+			return nil
+		}
+		current.Spec.Replicas = &replicas
+		return request.client.Update(request.ctx, current)
+	})
+}
@@ -3,39 +3,84 @@ package k8shandler
 import (
 	"context"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	"github.com/sirupsen/logrus"
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
 )
 
+const restartedAtAnnotation = "postgresql.openshift.io/restarted-at"
+
 type deploymentNode struct {
-	self *appsv1.Deployment
-	svc  *corev1.Service
-	db   *database
+	self   *appsv1.Deployment
+	svc    *corev1.Service
+	db     sqlBackend
+	fenced bool
+	// repmgrPassword is the password the node connects with, it changes only
+	// once new password was applied to the database role
+	repmgrPassword string
+	// applied is the container last written by the operator, changes made
+	// outside of the operator are reported as drift
+	applied *corev1.Container
+
+	// state of the rejoin in progress
+	rejoinStrategy string
+	rejoinStarted  time.Time
+	rejoining      bool
+	wiping         bool
+	cloneID        int
 }
 
 func newDeploymentNode(request *PostgreSQLRequest, name string, specNode *postgresqlv1.PostgreSQLNode, nodeID int, repmgrPassword string, operation string) *deploymentNode {
-	return &deploymentNode{
+	node := &deploymentNode{
 		self: newDeployment(request, name, specNode, nodeID, operation),
 		svc:  newService(request, name, name),
-		db:   newRepmgrDatabase(name, repmgrPassword),
+		db:   connections.client(request.connectionKey(name), name, repmgrPassword),
+
+		repmgrPassword: repmgrPassword,
 	}
+	if operation == NodeRejoin {
+		node.startRejoin(RejoinStrategyRejoin)
+	}
+	return node
 }
 
 func attachDeploymentNode(request *PostgreSQLRequest, name string, deployment *appsv1.Deployment, repmgrPassword string) *deploymentNode {
 	node := &deploymentNode{
 		self: deployment,
 		svc:  newService(request, name, name),
-		db:   newRepmgrDatabase(name, repmgrPassword),
+		db:   connections.client(request.connectionKey(name), name, repmgrPassword),
+
+		repmgrPassword: repmgrPassword,
+	}
+	if status, ok := request.cluster.Status.Nodes[name]; ok {
+		node.fenced = status.Fenced
+		node.restoreRejoin(status.RejoinStrategy, status.Rejoin)
 	}
-	node.db.initialize()
-	if err := node.db.err(); err != nil {
-		logrus.Errorf("Failed to initialize repmgr database connection %v", err)
+	return node
+}
+
+// resumeWipedNode returns node whose deployment was already deleted by the
+// wipe in progress, the deployment is created once the data volume is gone
+func resumeWipedNode(request *PostgreSQLRequest, name string, rejoin *postgresqlv1.PostgreSQLRejoinStatus, repmgrPassword string) *deploymentNode {
+	node := &deploymentNode{
+		self: &appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: request.cluster.Namespace}},
+		svc:  newService(request, name, name),
+		db:   connections.client(request.connectionKey(name), name, repmgrPassword),
+
+		repmgrPassword: repmgrPassword,
 	}
+	node.restoreRejoin(RejoinStrategyClone, rejoin)
 	return node
 }
 
@@ -44,7 +89,7 @@ func (node *deploymentNode) name() string {
 }
 
 func (node *deploymentNode) create(request *PostgreSQLRequest) error {
-	if err := request.client.Create(context.TODO(), node.self); err != nil {
+	if err := request.client.Create(request.ctx, node.self); err != nil {
 		if !errors.IsAlreadyExists(err) {
 			return fmt.Errorf("Failed to create node resource %v", err)
 		}
@@ -52,84 +97,134 @@ func (node *deploymentNode) create(request *PostgreSQLRequest) error {
 	if err := request.CreateOrUpdateService(node.svc.ObjectMeta.Name, node.svc.ObjectMeta.Name); err != nil {
 		return fmt.Errorf("Failed to create service resource %v", err)
 	}
-	node.db.initialize()
-	if err := node.db.err(); err != nil {
-		return fmt.Errorf("Failed to initialize repmgr database connection %v", err)
-	}
+	// the node is connected once its pod is ready
+	return nil
+}
 
+// connect refreshes connection of the node to its current pod
+func (node *deploymentNode) connect(request *PostgreSQLRequest) error {
+	db, err := connections.connect(request.ctx, request.connectionKey(node.name()), request.nodeHost(node.name()), node.repmgrPassword)
+	node.db = db
+	if err != nil {
+		repmgrConnectionFailures.WithLabelValues(request.seriesLabels(repmgrConnectionFailures, node.name())...).Inc()
+		return fmt.Errorf("Failed to connect to repmgr database of node %v: %v", node.name(), err)
+	}
 	return nil
 }
 
-func (node *deploymentNode) update(request *PostgreSQLRequest, specNode *postgresqlv1.PostgreSQLNode, writableDB *database) (bool, error) {
-	if err := request.CreateOrUpdateService(node.svc.ObjectMeta.Name, node.svc.ObjectMeta.Name); err != nil {
+// updatePassword reconnects the node using the new password
+func (node *deploymentNode) updatePassword(request *PostgreSQLRequest, password string) error {
+	node.repmgrPassword = password
+	return node.connect(request)
+}
+
+func (node *deploymentNode) update(request *PostgreSQLRequest, specNode *postgresqlv1.PostgreSQLNode, writableDB sqlBackend) (bool, error) {
+	if err := request.CreateOrUpdateService(node.svc.ObjectMeta.Name, node.serviceSelector()); err != nil {
 		return false, fmt.Errorf("Failed to create service resource %v", err)
 	}
+	if node.wiping {
+		return true, node.checkRejoin(request, specNode, writableDB)
+	}
 	current := node.self.DeepCopy()
-	if err := request.client.Get(context.TODO(), types.NamespacedName{Name: node.name(), Namespace: request.cluster.Namespace}, current); err != nil {
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: node.name(), Namespace: request.cluster.Namespace}, current); err != nil {
 		if errors.IsNotFound(err) {
-			nodeInfo := writableDB.getNodeInfo(node.name())
-			if err := node.db.err(); err != nil || nodeInfo.id < 0 {
+			nodeInfo, err := writableDB.getNodeInfo(request.ctx, node.name())
+			if err != nil || nodeInfo.id < 0 {
 				return true, fmt.Errorf("Failed to retrieve node id %v", err)
 			}
-			logrus.Infof("Creating lost deployment %v", node.self.ObjectMeta.Name)
+			request.recordDrift("Deployment", node.self.ObjectMeta.Name, "was deleted")
 			node.self = newDeployment(request, node.name(), specNode, nodeInfo.id, NodeRejoin)
-			if err := request.client.Create(context.TODO(), node.self); err != nil {
+			node.applied = nil
+			if err := request.client.Create(request.ctx, node.self); err != nil {
 				return true, fmt.Errorf("Failed to create node resource %v", err)
 			}
+			node.startRejoin(RejoinStrategyRejoin)
+			request.recordNormal(NodeRejoined, "Lost deployment of node %v recreated, rejoining with id %v", node.name(), nodeInfo.id)
 			return false, nil
 		}
 	}
-	current.Spec.Template.Spec.Containers[0].Resources = newResourceRequirements(specNode.Resources)
+	if err := node.checkClaim(request, current); err != nil {
+		logrus.Errorf("Failed to check volume claim of node %v: %v", node.name(), err)
+	}
+	observed := current.DeepCopy()
+	if _, ok := current.Spec.Template.ObjectMeta.Labels[fencedLabel]; !ok {
+		current.Spec.Template.ObjectMeta.Labels = newPodLabels(request.cluster.Name, node.name(), node.fenced)
+	}
+	if err := node.labelPod(request); err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	current.Spec.Template.Spec.Containers[0].Resources = newResourceRequirements(request.template(), specNode.Resources)
 	current.Spec.Template.Spec.Volumes[0] = newVolume(request, node.name(), &specNode.Storage)
-	current.Spec.Template.Spec.Containers[0].Image = newImage(specNode.Image)
+	current.Spec.Template.Spec.Containers[0].Image = newImage(request.template(), specNode.Image)
+	secret := request.credentialsSecret()
+	setContainerEnvVar(&current.Spec.Template.Spec.Containers[0], newSecretEnvVar("POSTGRESQL_PASSWORD", secret.name, secret.databaseKey))
+	setContainerEnvVar(&current.Spec.Template.Spec.Containers[0], newSecretEnvVar("REPMGR_PASSWORD", secret.name, secret.repmgrKey))
+	request.setSlotsEnv(&current.Spec.Template.Spec.Containers[0])
+	request.setUpstreamEnv(&current.Spec.Template.Spec.Containers[0], node.name())
 
 	if node.isReady() {
-		info := node.db.getNodeInfo(node.name())
-		if err := node.db.err(); err != nil {
+		info, err := node.db.getNodeInfo(request.ctx, node.name())
+		if err != nil {
 			logrus.Errorf("Failed to query role of node %v: %v", node.name(), err)
 		} else {
-			if info.priority != specNode.Priority {
-				writableDB.updateNodePriority(node.name(), specNode.Priority)
-				if err := node.db.err(); err != nil {
+			// nodes of the replica cluster are read-only and not registered in repmgr
+			if info.priority != specNode.Priority && !request.isReplica() {
+				if err := writableDB.updateNodePriority(request.ctx, node.name(), specNode.Priority); err != nil {
 					logrus.Errorf("Failed to update priority of node %v: %v", node.name(), err)
 				}
 			}
 		}
-		if err := request.client.Update(context.TODO(), current); err != nil {
+		if node.applied != nil {
+			if drift := deploymentDrift(node.applied, &observed.Spec.Template.Spec.Containers[0]); drift != "" {
+				request.recordDrift("Deployment", node.name(), drift)
+			}
+		}
+		if err := request.client.Update(request.ctx, current); err != nil {
 			return true, fmt.Errorf("Failed to update deployment %v: %v", node.name(), err)
 		}
+		node.applied = current.Spec.Template.Spec.Containers[0].DeepCopy()
 	}
 	node.self = current
+	if err := node.checkRejoin(request, specNode, writableDB); err != nil {
+		return true, err
+	}
 	return false, nil
 }
 
 func (node *deploymentNode) delete(request *PostgreSQLRequest) error {
-	if err := request.client.Delete(context.TODO(), node.self); err != nil {
+	if err := request.client.Delete(request.ctx, node.self); err != nil && !errors.IsNotFound(err) {
 		return fmt.Errorf("Failed to delete node resource %v", err)
 	}
-	if err := request.client.Delete(context.TODO(), node.svc); err != nil {
+	if err := request.client.Delete(request.ctx, node.svc); err != nil && !errors.IsNotFound(err) {
 		return fmt.Errorf("Failed to delete service resource %v", err)
 	}
-	node.db.engine.Close()
+	connections.close(request.connectionKey(node.name()))
 	return nil
 }
 
-func (node *deploymentNode) status() postgresqlv1.PostgreSQLNodeStatus {
-	info := node.db.getNodeInfo(node.name())
+func (node *deploymentNode) status(ctx context.Context) postgresqlv1.PostgreSQLNodeStatus {
+	info, infoErr := node.db.getNodeInfo(ctx, node.name())
+	version, versionErr := node.db.version(ctx)
 	status := postgresqlv1.PostgreSQLNodeStatus{
 		DeploymentName: node.self.ObjectMeta.Name,
 		ServiceName:    node.svc.ObjectMeta.Name,
-		PgVersion:      node.db.version(),
+		PgVersion:      version,
 		Role:           info.role,
 		Priority:       info.priority,
+		Fenced:         node.fenced,
 	}
-	if err := node.db.err(); err != nil {
-		logrus.Errorf("Failed to get node info: %v", err)
+	status.RejoinStrategy, status.Rejoin = node.rejoinState()
+	for _, err := range []error{infoErr, versionErr} {
+		if err != nil {
+			logrus.Errorf("Failed to get node info: %v", err)
+			repmgrConnectionFailures.WithLabelValues(clusterSeries.add(repmgrConnectionFailures, node.self.Namespace, node.self.ObjectMeta.Labels["cluster-name"], node.name())...).Inc()
+			break
+		}
 	}
 	return status
 }
 
-func (node *deploymentNode) dbClient() *database {
+func (node *deploymentNode) dbClient() sqlBackend {
 	return node.db
 }
 
@@ -138,9 +233,311 @@ func (node *deploymentNode) isReady() bool {
 }
 
 func (node *deploymentNode) isRegistered(request *PostgreSQLRequest) (bool, error) {
-	result := node.db.isRegistered(node.name())
-	if err := node.db.err(); err != nil {
+	result, err := node.db.isRegistered(request.ctx, node.name())
+	if err != nil {
 		return false, fmt.Errorf("Failed to check node %v register status: %v", node.name(), err)
 	}
 	return result, nil
 }
+
+// serviceSelector returns the node name, fenced nodes get a selector which
+// doesn't match any pod
+func (node *deploymentNode) serviceSelector() string {
+	if node.fenced {
+		return fmt.Sprintf("%v-fenced", node.name())
+	}
+	return node.name()
+}
+
+// fence removes the node from its service and restarts it, so it rejoins
+// the cluster as a standby of the current primary
+func (node *deploymentNode) fence(request *PostgreSQLRequest, writableDB sqlBackend) error {
+	if node.fenced {
+		return nil
+	}
+	node.fenced = true
+	if err := request.CreateOrUpdateService(node.svc.ObjectMeta.Name, node.serviceSelector()); err != nil {
+		return fmt.Errorf("Failed to remove node %v from its service: %v", node.name(), err)
+	}
+	if err := node.labelPod(request); err != nil {
+		return fmt.Errorf("Failed to remove node %v from read-only service: %v", node.name(), err)
+	}
+	return node.rejoin(request, writableDB, chooseRejoinStrategy(request.ctx, node.db, writableDB))
+}
+
+// unfence adds previously fenced node back to its service
+func (node *deploymentNode) unfence(request *PostgreSQLRequest) error {
+	if !node.fenced {
+		return nil
+	}
+	node.fenced = false
+	if err := request.CreateOrUpdateService(node.svc.ObjectMeta.Name, node.serviceSelector()); err != nil {
+		node.fenced = true
+		return fmt.Errorf("Failed to add node %v back to its service: %v", node.name(), err)
+	}
+	if err := node.labelPod(request); err != nil {
+		return fmt.Errorf("Failed to add node %v back to read-only service: %v", node.name(), err)
+	}
+	return nil
+}
+
+// labelPod sets fenced label of the running pod of the node, so the fenced
+// node is removed from the read-only service without waiting for its restart
+func (node *deploymentNode) labelPod(request *PostgreSQLRequest) error {
+	value := strconv.FormatBool(node.fenced)
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		pod, err := request.runningPod(node.name())
+		if err != nil {
+			// no pod is running, new pods take the label from the template
+			return nil
+		}
+		if pod.Labels[fencedLabel] == value {
+			return nil
+		}
+		if pod.Labels == nil {
+			pod.Labels = make(map[string]string)
+		}
+		pod.Labels[fencedLabel] = value
+		return request.client.Update(request.ctx, pod)
+	})
+}
+
+func (node *deploymentNode) isFenced() bool {
+	return node.fenced
+}
+
+// startRejoin marks beginning of the rejoin using the given strategy
+func (node *deploymentNode) startRejoin(strategy string) {
+	node.rejoinStrategy = strategy
+	node.rejoinStarted = time.Now()
+	node.rejoining = true
+}
+
+// restoreRejoin continues the rejoin recorded in status of the node
+func (node *deploymentNode) restoreRejoin(strategy string, rejoin *postgresqlv1.PostgreSQLRejoinStatus) {
+	node.rejoinStrategy = strategy
+	if rejoin == nil {
+		return
+	}
+	node.rejoining = true
+	node.rejoinStarted = rejoin.StartTime.Time
+	node.wiping = rejoin.Wiping
+	node.cloneID = rejoin.NodeID
+}
+
+// rejoinState returns the last rejoin strategy of the node and state of the
+// rejoin in progress, nil if the node isn't rejoining
+func (node *deploymentNode) rejoinState() (string, *postgresqlv1.PostgreSQLRejoinStatus) {
+	if !node.rejoining {
+		return node.rejoinStrategy, nil
+	}
+	return node.rejoinStrategy, &postgresqlv1.PostgreSQLRejoinStatus{
+		StartTime: metav1.NewTime(node.rejoinStarted),
+		Wiping:    node.wiping,
+		NodeID:    node.cloneID,
+	}
+}
+
+// rejoin restarts the node to rejoin the cluster using the given strategy
+func (node *deploymentNode) rejoin(request *PostgreSQLRequest, writableDB sqlBackend, strategy string) error {
+	logrus.Infof("Rejoining node %v using %v strategy", node.name(), strategy)
+	node.startRejoin(strategy)
+	switch strategy {
+	case RejoinStrategyClone:
+		return node.wipe(request, writableDB)
+	case RejoinStrategyRewind:
+		return node.restart(request, writableDB, NodeRewind)
+	default:
+		return node.restart(request, writableDB, NodeRejoin)
+	}
+}
+
+// restart changes startup operation of the node and forces restart of its pod
+func (node *deploymentNode) restart(request *PostgreSQLRequest, writableDB sqlBackend, operation string) error {
+	info, err := writableDB.getNodeInfo(request.ctx, node.name())
+	if err != nil || info.id < 0 {
+		return fmt.Errorf("Failed to retrieve id of node %v: %v", node.name(), err)
+	}
+	return node.restartWithID(request, operation, info.id)
+}
+
+// switchOperation restarts the node with another startup operation keeping
+// its id, used for nodes which are not registered in repmgr
+func (node *deploymentNode) switchOperation(request *PostgreSQLRequest, operation string) error {
+	id, err := strconv.Atoi(containerEnvValue(&node.self.Spec.Template.Spec.Containers[0], "NODE_ID"))
+	if err != nil {
+		return fmt.Errorf("Failed to retrieve id of node %v: %v", node.name(), err)
+	}
+	return node.restartWithID(request, operation, id)
+}
+
+// startupOperation returns the startup operation the node was last started with
+func (node *deploymentNode) startupOperation() string {
+	return containerEnvValue(&node.self.Spec.Template.Spec.Containers[0], "STARTUP_OPERATION")
+}
+
+// restartWithID changes startup operation and id of the node and forces
+// restart of its pod
+func (node *deploymentNode) restartWithID(request *PostgreSQLRequest, operation string, id int) error {
+	current := node.self.DeepCopy()
+	retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: node.name(), Namespace: request.cluster.Namespace}, current); err != nil {
+			return err
+		}
+		if current.Spec.Template.ObjectMeta.Annotations == nil {
+			current.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+		}
+		// annotation forces restart even if the startup operation is unchanged
+		current.Spec.Template.ObjectMeta.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339)
+		current.Spec.Template.ObjectMeta.Labels = newPodLabels(request.cluster.Name, node.name(), node.fenced)
+		container := &current.Spec.Template.Spec.Containers[0]
+		setContainerEnv(container, "STARTUP_OPERATION", operation)
+		setContainerEnv(container, "NODE_ID", fmt.Sprintf("%v", id))
+		request.setUpstreamEnv(container, node.name())
+		return request.client.Update(request.ctx, current)
+	})
+	if retryErr != nil {
+		return fmt.Errorf("Failed to restart node %v: %v", node.name(), retryErr)
+	}
+	node.self = current
+	node.applied = current.Spec.Template.Spec.Containers[0].DeepCopy()
+	return nil
+}
+
+// deploymentDrift describes changes of the node container made outside of
+// the operator since it was last applied
+func deploymentDrift(applied, current *corev1.Container) string {
+	var changed []string
+	if current.Image != applied.Image {
+		changed = append(changed, "image")
+	}
+	if !equality.Semantic.DeepEqual(current.Resources, applied.Resources) {
+		changed = append(changed, "resources")
+	}
+	if !equality.Semantic.DeepEqual(current.Env, applied.Env) {
+		changed = append(changed, "environment")
+	}
+	if len(changed) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("container %v modified", strings.Join(changed, ", "))
+}
+
+// wipe deletes deployment and data volume of the node, fresh copy is cloned
+// once both are gone
+func (node *deploymentNode) wipe(request *PostgreSQLRequest, writableDB sqlBackend) error {
+	info, err := writableDB.getNodeInfo(request.ctx, node.name())
+	if err != nil || info.id < 0 {
+		return fmt.Errorf("Failed to retrieve id of node %v: %v", node.name(), err)
+	}
+	node.cloneID = info.id
+	node.wiping = true
+	if err := request.client.Delete(request.ctx, node.self); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete deployment of node %v: %v", node.name(), err)
+	}
+	claim := &corev1.PersistentVolumeClaim{}
+	claimName := types.NamespacedName{Name: fmt.Sprintf("%s-%s", request.cluster.Name, node.name()), Namespace: request.cluster.Namespace}
+	if err := request.client.Get(request.ctx, claimName, claim); err != nil {
+		if errors.IsNotFound(err) {
+			return nil
+		}
+		return fmt.Errorf("Failed to get volume claim of node %v: %v", node.name(), err)
+	}
+	if err := request.client.Delete(request.ctx, claim); err != nil && !errors.IsNotFound(err) {
+		return fmt.Errorf("Failed to delete volume claim of node %v: %v", node.name(), err)
+	}
+	return nil
+}
+
+// checkClaim reports deleted volume claim of the deployment, the claim is
+// recreated by newVolume
+func (node *deploymentNode) checkClaim(request *PostgreSQLRequest, deployment *appsv1.Deployment) error {
+	for _, volume := range deployment.Spec.Template.Spec.Volumes {
+		if volume.PersistentVolumeClaim == nil {
+			continue
+		}
+		claim := &corev1.PersistentVolumeClaim{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: volume.PersistentVolumeClaim.ClaimName, Namespace: request.cluster.Namespace}, claim)
+		if errors.IsNotFound(err) {
+			request.recordDrift("PersistentVolumeClaim", volume.PersistentVolumeClaim.ClaimName, "was deleted")
+		} else if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// isWiped checks whether deployment and data volume of the node were deleted
+func (node *deploymentNode) isWiped(request *PostgreSQLRequest) (bool, error) {
+	for _, obj := range []struct {
+		name   string
+		object runtime.Object
+	}{
+		{node.name(), &appsv1.Deployment{}},
+		{fmt.Sprintf("%s-%s", request.cluster.Name, node.name()), &corev1.PersistentVolumeClaim{}},
+	} {
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: obj.name, Namespace: request.cluster.Namespace}, obj.object)
+		if err == nil {
+			return false, nil
+		}
+		if !errors.IsNotFound(err) {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// checkRejoin follows the rejoin in progress, escalates to the next strategy
+// if the node didn't become a ready standby in time
+func (node *deploymentNode) checkRejoin(request *PostgreSQLRequest, specNode *postgresqlv1.PostgreSQLNode, writableDB sqlBackend) error {
+	if !node.rejoining {
+		return nil
+	}
+	if node.wiping {
+		wiped, err := node.isWiped(request)
+		if err != nil || !wiped {
+			return err
+		}
+		logrus.Infof("Cloning fresh copy of node %v", node.name())
+		node.self = newDeployment(request, node.name(), specNode, node.cloneID, StandbyRegister)
+		node.applied = nil
+		if err := request.client.Create(request.ctx, node.self); err != nil && !errors.IsAlreadyExists(err) {
+			return fmt.Errorf("Failed to create node resource %v", err)
+		}
+		node.wiping = false
+		node.rejoinStarted = time.Now()
+		return nil
+	}
+	if node.isReady() {
+		inRecovery, err := node.db.isInRecovery(request.ctx)
+		if err == nil && inRecovery {
+			logrus.Infof("Node %v rejoined using %v strategy", node.name(), node.rejoinStrategy)
+			request.recordNormal(NodeRejoined, "Node %v rejoined the cluster using %v strategy", node.name(), node.rejoinStrategy)
+			node.rejoining = false
+			return nil
+		}
+	}
+	if time.Since(node.rejoinStarted) < rejoinTimeout {
+		return nil
+	}
+	next := nextRejoinStrategy(node.rejoinStrategy)
+	if next == "" {
+		return fmt.Errorf("Node %v failed to rejoin using %v strategy", node.name(), node.rejoinStrategy)
+	}
+	request.recordWarning(RejoinEscalated, "Node %v failed to rejoin using %v strategy, trying %v", node.name(), node.rejoinStrategy, next)
+	return node.rejoin(request, writableDB, next)
+}
+
+// reinitialize wipes data volume of the node, removes it from repmgr
+// cluster and clones it again from the primary
+func (node *deploymentNode) reinitialize(request *PostgreSQLRequest, writableDB sqlBackend) error {
+	logrus.Infof("Reinitializing node %v", node.name())
+	node.startRejoin(RejoinStrategyClone)
+	if err := node.wipe(request, writableDB); err != nil {
+		return err
+	}
+	if err := writableDB.unregisterNode(request.ctx, node.name()); err != nil {
+		return fmt.Errorf("Failed to unregister node %v: %v", node.name(), err)
+	}
+	return nil
+}
@@ -0,0 +1,388 @@
+package k8shandler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// dumpMountPath is where the volume of the dump is mounted in the job
+	dumpMountPath = "/var/lib/pgsql/dumps"
+
+	// dumpScript dumps the databases passed as arguments from the first node
+	// accepting connections and removes dumps exceeding the retention
+	dumpScript = `set -eo pipefail
+host=""
+for candidate in $DUMP_HOSTS; do
+  if pg_isready -q -h "$candidate"; then
+    host="$candidate"
+    break
+  fi
+done
+if [ -z "$host" ]; then
+  echo "No node of the cluster accepts connections" >&2
+  exit 1
+fi
+s3() {
+  aws ${DUMP_ENDPOINT:+--endpoint-url "$DUMP_ENDPOINT"} s3 "$@"
+}
+stamp="$(date -u +%Y%m%dT%H%M%SZ)"
+for db in "$@"; do
+  file="$db-$stamp.$DUMP_EXTENSION"
+  echo "Dumping database $db from $host to $file"
+  if [ -n "$DUMP_BUCKET" ]; then
+    pg_dump -F "$DUMP_FORMAT" -h "$host" "$db" | s3 cp - "s3://$DUMP_BUCKET/$DUMP_PREFIX$file"
+    existing="$(s3 ls "s3://$DUMP_BUCKET/$DUMP_PREFIX" | awk '{print $4}')"
+  else
+    pg_dump -F "$DUMP_FORMAT" -h "$host" -f "$DUMP_DIR/$file" "$db"
+    existing="$(ls -1 "$DUMP_DIR")"
+  fi
+  if [ "$DUMP_RETENTION" -gt 0 ]; then
+    for old in $(echo "$existing" | grep -E "^$db-[0-9]{8}T[0-9]{6}Z\.$DUMP_EXTENSION$" | sort -r | tail -n +$((DUMP_RETENTION + 1))); do
+      echo "Removing dump $old"
+      if [ -n "$DUMP_BUCKET" ]; then
+        s3 rm "s3://$DUMP_BUCKET/$DUMP_PREFIX$old"
+      else
+        rm -f "$DUMP_DIR/$old"
+      fi
+    done
+  fi
+done`
+)
+
+// dumpName returns name of the CronJob and the volume of the dump
+func dumpName(clusterName, name string) string {
+	return fmt.Sprintf("%s-dump-%s", clusterName, name)
+}
+
+// newDumpLabels returns labels of the dump, jobs of the dump are not nodes of
+// the cluster, so they don't have the cluster-name label
+func newDumpLabels(clusterName, name string) map[string]string {
+	return map[string]string{
+		"dump-of":   clusterName,
+		"dump-name": name,
+	}
+}
+
+// dumpHosts returns nodes the dump connects to, standby nodes ordered by
+// priority first, the primary node is the last resort
+func dumpHosts(clusterStatus *postgresqlv1.PostgreSQLStatus) []string {
+	standbys := []string{}
+	for name, status := range clusterStatus.Nodes {
+		if status.Role == postgresqlv1.PostgreSQLNodeRoleStandby && !status.Fenced && name != primaryNode.name() {
+			standbys = append(standbys, name)
+		}
+	}
+	sort.Slice(standbys, func(i, j int) bool {
+		a, b := clusterStatus.Nodes[standbys[i]], clusterStatus.Nodes[standbys[j]]
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		return standbys[i] < standbys[j]
+	})
+	return append(standbys, primaryNode.name())
+}
+
+// newDumpCronJob returns CronJob running the dump on its schedule
+func newDumpCronJob(request *PostgreSQLRequest, dump *postgresqlv1.PostgreSQLDump, hosts []string) (*batchv1beta1.CronJob, error) {
+	var backoffLimit int32 = 1
+	var historyLimit int32 = 3
+	suspend := false
+	name := dumpName(request.cluster.Name, dump.Name)
+	labels := newDumpLabels(request.cluster.Name, dump.Name)
+
+	format, extension := postgresqlv1.DumpFormatCustom, "dump"
+	if dump.Format == postgresqlv1.DumpFormatPlain {
+		format, extension = postgresqlv1.DumpFormatPlain, "sql"
+	}
+	databases := dump.Databases
+	if len(databases) == 0 {
+		databases = []string{newPgEnvironment().database}
+	}
+	secret := request.credentialsSecret()
+	container := corev1.Container{
+		Name:    name,
+		Image:   newImage(request.template(), dump.Image),
+		Command: append([]string{"bash", "-c", dumpScript, name}, databases...),
+		Env: []corev1.EnvVar{
+			{Name: "DUMP_HOSTS", Value: strings.Join(hosts, " ")},
+			{Name: "DUMP_FORMAT", Value: string(format)},
+			{Name: "DUMP_EXTENSION", Value: extension},
+			{Name: "DUMP_RETENTION", Value: fmt.Sprintf("%v", dump.Retention)},
+			// the application user owns the dumped databases, superuser is not needed
+			{Name: "PGUSER", Value: newPgEnvironment().user},
+			newSecretEnvVar("PGPASSWORD", secret.name, secret.databaseKey),
+		},
+	}
+	podSpec := corev1.PodSpec{RestartPolicy: corev1.RestartPolicyNever}
+
+	destination := dump.Destination
+	switch {
+	case destination.Volume != nil && destination.ObjectStore != nil:
+		return nil, fmt.Errorf("Both volume and objectStore destinations are set")
+	case destination.Volume != nil:
+		if destination.Volume.Size == nil {
+			return nil, fmt.Errorf("Size of the volume is not set")
+		}
+		setContainerEnv(&container, "DUMP_DIR", dumpMountPath)
+		container.VolumeMounts = []corev1.VolumeMount{{Name: name, MountPath: dumpMountPath}}
+		podSpec.Volumes = []corev1.Volume{{
+			Name: name,
+			VolumeSource: corev1.VolumeSource{
+				PersistentVolumeClaim: &corev1.PersistentVolumeClaimVolumeSource{ClaimName: name},
+			},
+		}}
+	case destination.ObjectStore != nil:
+		store := destination.ObjectStore
+		setContainerEnv(&container, "DUMP_BUCKET", store.Bucket)
+		setContainerEnv(&container, "DUMP_PREFIX", store.Prefix)
+		setContainerEnv(&container, "DUMP_ENDPOINT", store.Endpoint)
+		setContainerEnvVar(&container, newSecretEnvVar("AWS_ACCESS_KEY_ID", store.CredentialsSecret, "AWS_ACCESS_KEY_ID"))
+		setContainerEnvVar(&container, newSecretEnvVar("AWS_SECRET_ACCESS_KEY", store.CredentialsSecret, "AWS_SECRET_ACCESS_KEY"))
+	default:
+		return nil, fmt.Errorf("Neither volume nor objectStore destination is set")
+	}
+	podSpec.Containers = []corev1.Container{container}
+
+	cronJob := &batchv1beta1.CronJob{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "CronJob",
+			APIVersion: batchv1beta1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: request.cluster.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1beta1.CronJobSpec{
+			Schedule:                   dump.Schedule,
+			ConcurrencyPolicy:          batchv1beta1.ForbidConcurrent,
+			Suspend:                    &suspend,
+			SuccessfulJobsHistoryLimit: &historyLimit,
+			FailedJobsHistoryLimit:     &historyLimit,
+			JobTemplate: batchv1beta1.JobTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: batchv1.JobSpec{
+					BackoffLimit: &backoffLimit,
+					Template: corev1.PodTemplateSpec{
+						ObjectMeta: metav1.ObjectMeta{
+							Labels: labels,
+						},
+						Spec: podSpec,
+					},
+				},
+			},
+		},
+	}
+	controllerutil.SetControllerReference(request.cluster, cronJob, request.scheme)
+	return cronJob, nil
+}
+
+// reconcileDumps keeps CronJobs of the dumps in sync with the spec and
+// reports outcome of their last jobs, CronJobs of dumps removed from the spec
+// are deleted, their volumes are kept
+func (request *PostgreSQLRequest) reconcileDumps(clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	dumps := request.cluster.Spec.Dumps
+	if len(dumps) == 0 && len(clusterStatus.Dumps) == 0 {
+		return nil
+	}
+	hosts := dumpHosts(clusterStatus)
+
+	var failed error
+	desired := make(map[string]bool)
+	statuses := make(map[string]postgresqlv1.PostgreSQLDumpStatus)
+	for i := range dumps {
+		dump := &dumps[i]
+		desired[dumpName(request.cluster.Name, dump.Name)] = true
+		previous, found := clusterStatus.Dumps[dump.Name]
+		cronJob, err := request.createOrUpdateDump(dump, hosts)
+		if err != nil {
+			failed = fmt.Errorf("Failed to reconcile dump %v: %v", dump.Name, err)
+			request.recordWarning(DumpFailed, "Failed to reconcile dump %v: %v", dump.Name, err)
+			if found {
+				statuses[dump.Name] = previous
+			}
+			continue
+		}
+		status, err := request.dumpStatus(dump.Name, cronJob, previous)
+		if err != nil {
+			failed = fmt.Errorf("Failed to get status of dump %v: %v", dump.Name, err)
+		}
+		statuses[dump.Name] = status
+	}
+
+	cronJobs := &batchv1beta1.CronJobList{}
+	listOpts := client.InNamespace(request.cluster.Namespace).MatchingLabels(map[string]string{"dump-of": request.cluster.Name})
+	if err := request.client.List(request.ctx, listOpts, cronJobs); err != nil {
+		failed = fmt.Errorf("Failed to list dumps of cluster %v: %v", request.cluster.Name, err)
+	}
+	for i := range cronJobs.Items {
+		cronJob := &cronJobs.Items[i]
+		if desired[cronJob.Name] {
+			continue
+		}
+		logrus.Infof("Deleting dump %v", cronJob.Name)
+		propagation := metav1.DeletePropagationBackground
+		err := request.client.Delete(request.ctx, cronJob, client.PropagationPolicy(propagation))
+		if err != nil && !errors.IsNotFound(err) {
+			failed = fmt.Errorf("Failed to delete dump %v: %v", cronJob.Name, err)
+			name := cronJob.Labels["dump-name"]
+			if previous, ok := clusterStatus.Dumps[name]; ok {
+				statuses[name] = previous
+			}
+			continue
+		}
+		request.recordNormal(DumpDeleted, "Dump %v deleted", cronJob.Name)
+	}
+	clusterStatus.Dumps = nil
+	if len(statuses) > 0 {
+		clusterStatus.Dumps = statuses
+	}
+	return failed
+}
+
+// createOrUpdateDump creates the CronJob and the volume of the dump or updates
+// the existing CronJob, nodes the dump connects to change with failovers
+func (request *PostgreSQLRequest) createOrUpdateDump(dump *postgresqlv1.PostgreSQLDump, hosts []string) (*batchv1beta1.CronJob, error) {
+	cronJob, err := newDumpCronJob(request, dump, hosts)
+	if err != nil {
+		return nil, err
+	}
+	if volume := dump.Destination.Volume; volume != nil {
+		volSpec := corev1.PersistentVolumeClaimSpec{
+			AccessModes: []corev1.PersistentVolumeAccessMode{
+				corev1.ReadWriteOnce,
+			},
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{
+					corev1.ResourceStorage: *volume.Size,
+				},
+			},
+			StorageClassName: volume.StorageClassName,
+		}
+		if err := createPersistentVolumeClaim(request, volSpec, cronJob.Name); err != nil {
+			request.recordWarning(PVCCreateFailed, "Failed to create PersistentVolumeClaim %v: %v", cronJob.Name, err)
+			return nil, err
+		}
+	}
+
+	current := &batchv1beta1.CronJob{}
+	err = request.client.Get(request.ctx, types.NamespacedName{Name: cronJob.Name, Namespace: cronJob.Namespace}, current)
+	switch {
+	case errors.IsNotFound(err):
+		if err := request.client.Create(request.ctx, cronJob); err != nil {
+			return nil, fmt.Errorf("Failed to create CronJob %v: %v", cronJob.Name, err)
+		}
+		request.recordNormal(DumpScheduled, "Dump %v scheduled at %v", cronJob.Name, dump.Schedule)
+		return cronJob, nil
+	case err != nil:
+		return nil, fmt.Errorf("Failed to get CronJob %v: %v", cronJob.Name, err)
+	}
+	current.Labels = cronJob.Labels
+	current.Spec.Schedule = cronJob.Spec.Schedule
+	current.Spec.ConcurrencyPolicy = cronJob.Spec.ConcurrencyPolicy
+	current.Spec.Suspend = cronJob.Spec.Suspend
+	current.Spec.SuccessfulJobsHistoryLimit = cronJob.Spec.SuccessfulJobsHistoryLimit
+	current.Spec.FailedJobsHistoryLimit = cronJob.Spec.FailedJobsHistoryLimit
+	current.Spec.JobTemplate = cronJob.Spec.JobTemplate
+	if err := request.client.Update(request.ctx, current); err != nil {
+		return nil, fmt.Errorf("Failed to update CronJob %v: %v", cronJob.Name, err)
+	}
+	return current, nil
+}
+
+// dumpStatus returns outcome of the most recent job of the dump, the time of
+// the last success is kept after its job is removed from the history
+func (request *PostgreSQLRequest) dumpStatus(name string, cronJob *batchv1beta1.CronJob, previous postgresqlv1.PostgreSQLDumpStatus) (postgresqlv1.PostgreSQLDumpStatus, error) {
+	status := postgresqlv1.PostgreSQLDumpStatus{
+		CronJobName:        cronJob.Name,
+		LastScheduleTime:   cronJob.Status.LastScheduleTime,
+		LastJob:            previous.LastJob,
+		LastResult:         previous.LastResult,
+		LastSuccessfulTime: previous.LastSuccessfulTime,
+		Message:            previous.Message,
+	}
+	jobs := &batchv1.JobList{}
+	listOpts := client.InNamespace(request.cluster.Namespace).MatchingLabels(newDumpLabels(request.cluster.Name, name))
+	if err := request.client.List(request.ctx, listOpts, jobs); err != nil {
+		return status, err
+	}
+	var last *batchv1.Job
+	for i := range jobs.Items {
+		job := &jobs.Items[i]
+		if job.Status.Succeeded > 0 && job.Status.CompletionTime != nil &&
+			(status.LastSuccessfulTime == nil || status.LastSuccessfulTime.Before(job.Status.CompletionTime)) {
+			status.LastSuccessfulTime = job.Status.CompletionTime
+		}
+		if last == nil || last.CreationTimestamp.Before(&job.CreationTimestamp) ||
+			(last.CreationTimestamp.Equal(&job.CreationTimestamp) && last.Name < job.Name) {
+			last = job
+		}
+	}
+	if last == nil {
+		return status, nil
+	}
+	status.LastJob = last.Name
+	status.Message = ""
+	switch condition := jobFailure(last); {
+	case last.Status.Succeeded > 0:
+		status.LastResult = postgresqlv1.DumpResultSucceeded
+	case condition != nil:
+		status.LastResult = postgresqlv1.DumpResultFailed
+		status.Message = condition.Message
+		if previous.LastJob != last.Name || previous.LastResult != postgresqlv1.DumpResultFailed {
+			request.recordWarning(DumpFailed, "Job %v of dump %v failed: %v", last.Name, name, condition.Message)
+		}
+	default:
+		status.LastResult = postgresqlv1.DumpResultRunning
+	}
+	return status, nil
+}
+
+// jobFailure returns the condition of the failed job, nil if the job didn't fail
+func jobFailure(job *batchv1.Job) *batchv1.JobCondition {
+	for i := range job.Status.Conditions {
+		condition := &job.Status.Conditions[i]
+		if condition.Type == batchv1.JobFailed && condition.Status == corev1.ConditionTrue {
+			return condition
+		}
+	}
+	return nil
+}
+
+// suspendDumps suspends CronJobs of the hibernated cluster, the dumps are
+// resumed with the cluster
+func (request *PostgreSQLRequest) suspendDumps() error {
+	suspend := true
+	cronJobs := &batchv1beta1.CronJobList{}
+	listOpts := client.InNamespace(request.cluster.Namespace).MatchingLabels(map[string]string{"dump-of": request.cluster.Name})
+	if err := request.client.List(request.ctx, listOpts, cronJobs); err != nil {
+		return fmt.Errorf("Failed to list dumps of cluster %v: %v", request.cluster.Name, err)
+	}
+	for i := range cronJobs.Items {
+		cronJob := &cronJobs.Items[i]
+		if cronJob.Spec.Suspend != nil && *cronJob.Spec.Suspend {
+			continue
+		}
+		cronJob.Spec.Suspend = &suspend
+		if err := request.client.Update(request.ctx, cronJob); err != nil {
+			return fmt.Errorf("Failed to suspend dump %v: %v", cronJob.Name, err)
+		}
+	}
+	return nil
+}
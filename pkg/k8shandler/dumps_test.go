@@ -0,0 +1,261 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	batchv1 "k8s.io/api/batch/v1"
+	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileDumps(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	size := resource.MustParse("1Gi")
+	nightly := postgresqlv1.PostgreSQLDump{
+		Name:        "nightly",
+		Schedule:    "0 3 * * *",
+		Databases:   []string{"orders"},
+		Destination: postgresqlv1.PostgreSQLDumpDestination{Volume: &postgresqlv1.PostgreSQLStorageSpec{Size: &size}},
+		Retention:   7,
+	}
+	// times are stored with a precision of seconds
+	first := metav1.Date(2019, 10, 1, 3, 0, 0, 0, time.UTC)
+	second := metav1.Date(2019, 10, 2, 3, 0, 0, 0, time.UTC)
+	newJob := func(name string, created metav1.Time, status batchv1.JobStatus) *batchv1.Job {
+		return &batchv1.Job{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         "default",
+				Labels:            newDumpLabels("example", "nightly"),
+				CreationTimestamp: created,
+			},
+			Status: status,
+		}
+	}
+	getCronJob := func(request *PostgreSQLRequest, name string) (*batchv1beta1.CronJob, error) {
+		cronJob := &batchv1beta1.CronJob{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: "default"}, cronJob)
+		return cronJob, err
+	}
+
+	table := []struct {
+		name    string
+		dumps   []postgresqlv1.PostgreSQLDump
+		status  map[string]postgresqlv1.PostgreSQLDumpStatus
+		objects []runtime.Object
+		// scheduled lists dumps whose CronJobs exist before the reconcile
+		scheduled []postgresqlv1.PostgreSQLDump
+		verify    func(request *PostgreSQLRequest, reasons []string) error
+	}{
+		{
+			name:  "dump to volume scheduled on standby",
+			dumps: []postgresqlv1.PostgreSQLDump{nightly},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				cronJob, err := getCronJob(request, "example-dump-nightly")
+				if err != nil {
+					return err
+				}
+				if cronJob.Spec.Schedule != "0 3 * * *" {
+					return fmt.Errorf("expected schedule: '0 3 * * *', got: '%v'", cronJob.Spec.Schedule)
+				}
+				container := &cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+				expected := map[string]string{
+					"DUMP_HOSTS":     "node-two node-one",
+					"DUMP_FORMAT":    "custom",
+					"DUMP_RETENTION": "7",
+					"DUMP_DIR":       dumpMountPath,
+				}
+				for name, value := range expected {
+					if actual := containerEnvValue(container, name); actual != value {
+						return fmt.Errorf("expected %v: '%v', got: '%v'", name, value, actual)
+					}
+				}
+				if database := container.Command[len(container.Command)-1]; database != "orders" {
+					return fmt.Errorf("expected database: 'orders', got: '%v'", database)
+				}
+				pvc := &corev1.PersistentVolumeClaim{}
+				if err := request.client.Get(request.ctx, types.NamespacedName{Name: "example-dump-nightly", Namespace: "default"}, pvc); err != nil {
+					return err
+				}
+				if status := request.cluster.Status.Dumps["nightly"]; status.CronJobName != "example-dump-nightly" {
+					return fmt.Errorf("expected CronJob in status, got: '%v'", status)
+				}
+				if !containsString(reasons, DumpScheduled) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", DumpScheduled, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name: "plain dump to object store",
+			dumps: []postgresqlv1.PostgreSQLDump{{
+				Name:     "nightly",
+				Schedule: "0 3 * * *",
+				Format:   postgresqlv1.DumpFormatPlain,
+				Destination: postgresqlv1.PostgreSQLDumpDestination{ObjectStore: &postgresqlv1.PostgreSQLObjectStore{
+					Bucket: "dumps", Prefix: "example/", CredentialsSecret: "s3-credentials",
+				}},
+			}},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				cronJob, err := getCronJob(request, "example-dump-nightly")
+				if err != nil {
+					return err
+				}
+				container := &cronJob.Spec.JobTemplate.Spec.Template.Spec.Containers[0]
+				expected := map[string]string{
+					"DUMP_FORMAT":    "plain",
+					"DUMP_EXTENSION": "sql",
+					"DUMP_BUCKET":    "dumps",
+					"DUMP_PREFIX":    "example/",
+					"PGUSER":         newPgEnvironment().user,
+				}
+				for name, value := range expected {
+					if actual := containerEnvValue(container, name); actual != value {
+						return fmt.Errorf("expected %v: '%v', got: '%v'", name, value, actual)
+					}
+				}
+				for _, env := range container.Env {
+					if env.Name == "PGPASSWORD" && (env.ValueFrom == nil || env.ValueFrom.SecretKeyRef.Key != defaultDatabasePasswordKey) {
+						return fmt.Errorf("expected password of the application user, got: '%v'", env.ValueFrom)
+					}
+				}
+				if database := container.Command[len(container.Command)-1]; database != newPgEnvironment().database {
+					return fmt.Errorf("expected database: '%v', got: '%v'", newPgEnvironment().database, database)
+				}
+				if volumes := cronJob.Spec.JobTemplate.Spec.Template.Spec.Volumes; len(volumes) != 0 {
+					return fmt.Errorf("expected no volumes, got: '%v'", volumes)
+				}
+				return nil
+			},
+		},
+		{
+			name:  "dump without destination rejected",
+			dumps: []postgresqlv1.PostgreSQLDump{{Name: "nightly", Schedule: "0 3 * * *"}},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				if _, err := getCronJob(request, "example-dump-nightly"); err == nil {
+					return fmt.Errorf("CronJob of invalid dump was created")
+				}
+				if !containsString(reasons, DumpFailed) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", DumpFailed, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "outcome of the last job reported",
+			dumps:     []postgresqlv1.PostgreSQLDump{nightly},
+			scheduled: []postgresqlv1.PostgreSQLDump{nightly},
+			objects: []runtime.Object{
+				newJob("nightly-1", first, batchv1.JobStatus{Succeeded: 1, CompletionTime: &first}),
+				newJob("nightly-2", second, batchv1.JobStatus{Failed: 2, Conditions: []batchv1.JobCondition{
+					{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, Message: "Job has reached the specified backoff limit"},
+				}}),
+			},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				status := request.cluster.Status.Dumps["nightly"]
+				if status.LastJob != "nightly-2" || status.LastResult != postgresqlv1.DumpResultFailed {
+					return fmt.Errorf("expected failed job 'nightly-2', got: '%v'", status)
+				}
+				if status.Message != "Job has reached the specified backoff limit" {
+					return fmt.Errorf("expected message of the failed job, got: '%v'", status.Message)
+				}
+				if status.LastSuccessfulTime == nil || !status.LastSuccessfulTime.Equal(&first) {
+					return fmt.Errorf("expected last successful time: '%v', got: '%v'", first, status.LastSuccessfulTime)
+				}
+				if !containsString(reasons, DumpFailed) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", DumpFailed, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:      "dump removed from the spec deleted",
+			status:    map[string]postgresqlv1.PostgreSQLDumpStatus{"nightly": {CronJobName: "example-dump-nightly"}},
+			scheduled: []postgresqlv1.PostgreSQLDump{nightly},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				if _, err := getCronJob(request, "example-dump-nightly"); err == nil {
+					return fmt.Errorf("CronJob of removed dump was not deleted")
+				}
+				if dumps := request.cluster.Status.Dumps; dumps != nil {
+					return fmt.Errorf("expected no dumps in status, got: '%v'", dumps)
+				}
+				if !containsString(reasons, DumpDeleted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", DumpDeleted, reasons)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, map[string]int{"node-one": 1, "node-two": 2})
+		for _, obj := range tt.objects {
+			if err := request.client.Create(request.ctx, obj.DeepCopyObject()); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		for i := range tt.scheduled {
+			cronJob, err := newDumpCronJob(request, &tt.scheduled[i], []string{"node-one"})
+			if err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+			if err := request.client.Create(request.ctx, cronJob); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		request.cluster.Spec.Dumps = tt.dumps
+		request.cluster.Status.Dumps = tt.status
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.verify(request, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
+
+func TestSuspendDumps(t *testing.T) {
+	request, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	size := resource.MustParse("1Gi")
+	dump := &postgresqlv1.PostgreSQLDump{
+		Name:        "nightly",
+		Schedule:    "0 3 * * *",
+		Destination: postgresqlv1.PostgreSQLDumpDestination{Volume: &postgresqlv1.PostgreSQLStorageSpec{Size: &size}},
+	}
+	cronJob, err := newDumpCronJob(request, dump, []string{"node-one"})
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if err := request.client.Create(request.ctx, cronJob); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if err := request.suspendDumps(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	current := &batchv1beta1.CronJob{}
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: cronJob.Name, Namespace: "default"}, current); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if current.Spec.Suspend == nil || !*current.Spec.Suspend {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", true, current.Spec.Suspend)
+	}
+}
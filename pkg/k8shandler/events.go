@@ -0,0 +1,91 @@
+package k8shandler
+
+import (
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+)
+
+const (
+	// NodeCreated is emitted when a new node is added to the cluster
+	NodeCreated = "NodeCreated"
+	// NodeRejoined is emitted when a previously deleted node rejoins the cluster
+	NodeRejoined = "NodeRejoined"
+	// NodeDeleted is emitted when a node not listed in the spec is removed
+	NodeDeleted = "NodeDeleted"
+	// NodeUnregistered is emitted when repmgr record and replication slot of a removed node are dropped
+	NodeUnregistered = "NodeUnregistered"
+	// NodeCleanupFailed is emitted when repmgr record or replication slot of a removed node cannot be dropped
+	NodeCleanupFailed = "NodeCleanupFailed"
+	// ReplicationSlotDropped is emitted when an orphaned replication slot is dropped
+	ReplicationSlotDropped = "ReplicationSlotDropped"
+	// ReplicationSlotLimitExceeded is emitted when a replication slot retains more WAL than the limit
+	ReplicationSlotLimitExceeded = "ReplicationSlotLimitExceeded"
+	// ReplicationSlotInvalidated is emitted when a replication slot exceeding the limit is dropped
+	ReplicationSlotInvalidated = "ReplicationSlotInvalidated"
+	// WitnessCreated is emitted when the witness node is added to the cluster
+	WitnessCreated = "WitnessCreated"
+	// WitnessRegistered is emitted when the witness node registers to the primary
+	WitnessRegistered = "WitnessRegistered"
+	// WitnessDeleted is emitted when the witness node removed from the spec is deleted and unregistered
+	WitnessDeleted = "WitnessDeleted"
+	// PublicationCreated is emitted when a publication of the spec is created
+	PublicationCreated = "PublicationCreated"
+	// PublicationDropped is emitted when a publication removed from the spec is dropped
+	PublicationDropped = "PublicationDropped"
+	// SubscriptionCreated is emitted when a subscription of the spec is created
+	SubscriptionCreated = "SubscriptionCreated"
+	// SubscriptionDropped is emitted when a subscription removed from the spec is dropped
+	SubscriptionDropped = "SubscriptionDropped"
+	// LogicalReplicationFailed is emitted when a publication or a subscription cannot be reconciled
+	LogicalReplicationFailed = "LogicalReplicationFailed"
+	// PromotionStarted is emitted when the lead node of the replica cluster is being promoted
+	PromotionStarted = "PromotionStarted"
+	// ClusterPromoted is emitted when the replica cluster became independent of its source cluster
+	ClusterPromoted = "ClusterPromoted"
+	// PromotionRejected is emitted when promotion of the cluster is not possible
+	PromotionRejected = "PromotionRejected"
+	// ImportStarted is emitted when import of an external database into the new cluster starts
+	ImportStarted = "ImportStarted"
+	// ImportSucceeded is emitted when import of an external database finished
+	ImportSucceeded = "ImportSucceeded"
+	// ImportFailed is emitted when import of an external database failed
+	ImportFailed = "ImportFailed"
+	// DumpScheduled is emitted when CronJob of a dump of the spec is created
+	DumpScheduled = "DumpScheduled"
+	// DumpDeleted is emitted when CronJob of a dump removed from the spec is deleted
+	DumpDeleted = "DumpDeleted"
+	// DumpFailed is emitted when a dump cannot be reconciled or its job failed
+	DumpFailed = "DumpFailed"
+	// FailoverDetected is emitted when a standby node was promoted to primary
+	FailoverDetected = "FailoverDetected"
+	// PrimaryServiceRepointed is emitted when primary service selector changes
+	PrimaryServiceRepointed = "PrimaryServiceRepointed"
+	// PVCCreateFailed is emitted when a PersistentVolumeClaim of a node cannot be created
+	PVCCreateFailed = "PVCCreateFailed"
+	// Drift is emitted when a resource deleted or modified outside of the operator is restored
+	Drift = "Drift"
+)
+
+// recordEvent emits an event of the given type on the PostgreSQL resource
+func (request *PostgreSQLRequest) recordEvent(eventType, reason, messageFmt string, args ...interface{}) {
+	if request.recorder == nil {
+		return
+	}
+	request.recorder.Eventf(request.cluster, eventType, reason, messageFmt, args...)
+}
+
+// recordNormal emits an informative event on the PostgreSQL resource
+func (request *PostgreSQLRequest) recordNormal(reason, messageFmt string, args ...interface{}) {
+	request.recordEvent(corev1.EventTypeNormal, reason, messageFmt, args...)
+}
+
+// recordWarning emits a warning event on the PostgreSQL resource
+func (request *PostgreSQLRequest) recordWarning(reason, messageFmt string, args ...interface{}) {
+	request.recordEvent(corev1.EventTypeWarning, reason, messageFmt, args...)
+}
+
+// recordDrift reports a managed resource restored to its desired state
+func (request *PostgreSQLRequest) recordDrift(kind, name, detail string) {
+	logrus.Warnf("%v %v %v, restoring it", kind, name, detail)
+	request.recordWarning(Drift, "%v %v %v, restored", kind, name, detail)
+}
@@ -0,0 +1,120 @@
+package k8shandler
+
+import (
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/record"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// recordedEvents returns types of all events emitted so far by their reasons
+func recordedEvents(recorder *record.FakeRecorder) map[string]string {
+	events := make(map[string]string)
+	for {
+		select {
+		case event := <-recorder.Events:
+			fields := strings.Fields(event)
+			events[fields[1]] = fields[0]
+		default:
+			return events
+		}
+	}
+}
+
+func TestClusterEvents(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	table := []struct {
+		name      string
+		specNodes map[string]int
+		deployed  map[string]int
+		setup     func(repmgr *fakeRepmgr)
+		// change is applied to the topology between the first and the second reconcile
+		change   func(repmgr *fakeRepmgr)
+		expected map[string]string
+	}{
+		{
+			name:      "node created",
+			specNodes: map[string]int{"node-one": 100, "node-two": 80},
+			deployed:  map[string]int{"node-one": 1},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+			},
+			expected: map[string]string{NodeCreated: corev1.EventTypeNormal},
+		},
+		{
+			name:      "failover",
+			specNodes: map[string]int{"node-one": 100, "node-two": 80},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+			},
+			change: func(repmgr *fakeRepmgr) {
+				repmgr.Stop("node-one")
+				repmgr.Promote("node-two")
+			},
+			expected: map[string]string{
+				FailoverDetected:        corev1.EventTypeWarning,
+				PrimaryServiceRepointed: corev1.EventTypeNormal,
+			},
+		},
+		{
+			name:      "failed cleanup of removed node",
+			specNodes: map[string]int{"node-one": 100},
+			deployed:  map[string]int{"node-one": 1, "node-two": 2},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+				repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+			},
+			expected: map[string]string{
+				NodeDeleted:       corev1.EventTypeNormal,
+				NodeCleanupFailed: corev1.EventTypeWarning,
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		tt.setup(repmgr)
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, tt.specNodes, tt.deployed)
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if tt.change != nil {
+			recordedEvents(recorder)
+			tt.change(repmgr)
+			if _, err := request.CreateOrUpdateCluster(); err != nil {
+				t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+				continue
+			}
+		}
+		events := recordedEvents(recorder)
+		for reason, eventType := range tt.expected {
+			if actual, ok := events[reason]; !ok || actual != eventType {
+				t.Errorf("Test %v failed, expected %v event: '%v', got: '%v'", tt.name, eventType, reason, events)
+			}
+		}
+	}
+}
+
+func TestRecordDrift(t *testing.T) {
+	recorder := record.NewFakeRecorder(1)
+	request := &PostgreSQLRequest{cluster: &postgresqlv1.PostgreSQL{}, recorder: recorder}
+	request.recordDrift("Service", "postgresql-ro", "was deleted")
+	expected := "Warning Drift Service postgresql-ro was deleted, restored"
+	if actual := <-recorder.Events; actual != expected {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", expected, actual)
+	}
+	// requests without recorder don't emit events
+	request.recorder = nil
+	request.recordWarning(Drift, "ignored")
+}
@@ -0,0 +1,95 @@
+package k8shandler
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/remotecommand"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// PodExecutor runs commands inside of containers of running pods
+type PodExecutor interface {
+	Exec(ctx context.Context, namespace, pod, container string, command []string) (string, error)
+}
+
+type remotePodExecutor struct {
+	config    *rest.Config
+	clientset kubernetes.Interface
+}
+
+// NewPodExecutor constructs a PodExecutor using pods/exec subresource
+func NewPodExecutor(config *rest.Config) (PodExecutor, error) {
+	clientset, err := kubernetes.NewForConfig(config)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to create clientset: %v", err)
+	}
+	return &remotePodExecutor{config: config, clientset: clientset}, nil
+}
+
+// Exec runs the command and waits for its output, the stream is abandoned once
+// the context is done
+func (e *remotePodExecutor) Exec(ctx context.Context, namespace, pod, container string, command []string) (string, error) {
+	req := e.clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(pod).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: container,
+			Command:   command,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+	executor, err := remotecommand.NewSPDYExecutor(e.config, "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("Failed to initialize executor: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	done := make(chan error, 1)
+	go func() {
+		done <- executor.Stream(remotecommand.StreamOptions{Stdout: &stdout, Stderr: &stderr})
+	}()
+	select {
+	case <-ctx.Done():
+		return "", fmt.Errorf("Command in pod %v interrupted: %v", pod, ctx.Err())
+	case err := <-done:
+		if err != nil {
+			return stdout.String(), fmt.Errorf("Command failed in pod %v: %v, %v", pod, err, stderr.String())
+		}
+	}
+	return stdout.String(), nil
+}
+
+// execInNode runs a command in the running pod of the node
+func (request *PostgreSQLRequest) execInNode(nodeName string, command []string) (string, error) {
+	if request.executor == nil {
+		return "", fmt.Errorf("Pod executor is not configured")
+	}
+	pod, err := request.runningPod(nodeName)
+	if err != nil {
+		return "", err
+	}
+	return request.executor.Exec(request.ctx, pod.Namespace, pod.Name, nodeName, command)
+}
+
+// runningPod returns the running pod of the node
+func (request *PostgreSQLRequest) runningPod(nodeName string) (*corev1.Pod, error) {
+	podList := &corev1.PodList{}
+	listOpts := client.InNamespace(request.cluster.Namespace).MatchingLabels(newLabels(request.cluster.Name, nodeName))
+	if err := request.client.List(request.ctx, listOpts, podList); err != nil {
+		return nil, fmt.Errorf("Failed to list pods of node %v: %v", nodeName, err)
+	}
+	for i := range podList.Items {
+		pod := &podList.Items[i]
+		if pod.Status.Phase == corev1.PodRunning && pod.DeletionTimestamp == nil {
+			return pod, nil
+		}
+	}
+	return nil, fmt.Errorf("No running pod found for node %v", nodeName)
+}
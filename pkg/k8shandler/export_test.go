@@ -0,0 +1,16 @@
+package k8shandler
+
+// FakeRepmgr is the fake repmgr cluster simulating databases of the nodes in
+// integration tests
+type FakeRepmgr = fakeRepmgr
+
+// NewFakeRepmgr returns fake repmgr cluster without any node
+var NewFakeRepmgr = newFakeRepmgr
+
+// UseFakeRepmgr makes the operator query servers of the fake repmgr cluster
+// instead of PostgreSQL, the returned function restores the previous backend
+func UseFakeRepmgr(repmgr *FakeRepmgr) func() {
+	previous := connections
+	connections = &fakeConnector{repmgr: repmgr}
+	return func() { connections = previous }
+}
@@ -0,0 +1,559 @@
+package k8shandler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// errFakeUnreachable is returned by clients of stopped fake servers
+var errFakeUnreachable = errors.New("Connection refused")
+
+// fakeRepmgr simulates repmgr cluster in memory, all servers share a single
+// repmgr.nodes table as it is replicated from the primary
+type fakeRepmgr struct {
+	mutex   sync.Mutex
+	records map[string]nodeInfo
+	servers map[string]*fakeServer
+	// slots maps names of replication slots on the primary to names of their nodes
+	slots map[string]string
+	// publications and subscriptions of the application database are
+	// replicated to all servers, as the catalog is
+	publications  map[string]publication
+	subscriptions map[string]subscription
+}
+
+// fakeServer is the state of a single PostgreSQL server
+type fakeServer struct {
+	running    bool
+	inRecovery bool
+	timeline   int
+	lsn        string
+	lag        int64
+	version    string
+	passwords  map[string]string
+}
+
+// newFakeRepmgr returns fake repmgr cluster without any node
+func newFakeRepmgr() *fakeRepmgr {
+	return &fakeRepmgr{
+		records:       make(map[string]nodeInfo),
+		servers:       make(map[string]*fakeServer),
+		slots:         make(map[string]string),
+		publications:  make(map[string]publication),
+		subscriptions: make(map[string]subscription),
+	}
+}
+
+// AddNode starts a server of the node and registers it with the given role
+func (r *fakeRepmgr) AddNode(name string, id int, role postgresqlv1.PostgreSQLNodeRole, priority int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.records[name] = nodeInfo{id: id, role: role, priority: priority}
+	r.servers[name] = &fakeServer{
+		running:    true,
+		inRecovery: role != postgresqlv1.PostgreSQLNodeRolePrimary,
+		timeline:   1,
+		lsn:        "0/3000060",
+		version:    "PostgreSQL 10.6",
+		passwords:  make(map[string]string),
+	}
+}
+
+// Register adds record of the node without a running server, as left by a deleted node
+func (r *fakeRepmgr) Register(name string, id int, role postgresqlv1.PostgreSQLNodeRole, priority int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.records[name] = nodeInfo{id: id, role: role, priority: priority}
+}
+
+// Stop makes the server of the node unreachable
+func (r *fakeRepmgr) Stop(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if server, ok := r.servers[name]; ok {
+		server.running = false
+	}
+}
+
+// AddSlot creates replication slot of the node on the primary
+func (r *fakeRepmgr) AddSlot(name, slot string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.slots[slot] = name
+}
+
+// HasSlot checks whether the replication slot exists
+func (r *fakeRepmgr) HasSlot(slot string) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	_, ok := r.slots[slot]
+	return ok
+}
+
+// SetLag sets replay lag of the standby reported by the primary
+func (r *fakeRepmgr) SetLag(name string, bytes int64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if server, ok := r.servers[name]; ok {
+		server.lag = bytes
+	}
+}
+
+// Promote turns the node into the primary running on a new timeline, like
+// repmgr standby promote does
+func (r *fakeRepmgr) Promote(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	record := r.records[name]
+	record.role = postgresqlv1.PostgreSQLNodeRolePrimary
+	r.records[name] = record
+	if server, ok := r.servers[name]; ok {
+		server.inRecovery = false
+		server.timeline++
+	}
+}
+
+// Demote turns the node into a standby following the current primary, like
+// repmgr node rejoin does
+func (r *fakeRepmgr) Demote(name string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	record := r.records[name]
+	record.role = postgresqlv1.PostgreSQLNodeRoleStandby
+	r.records[name] = record
+	server, ok := r.servers[name]
+	if !ok {
+		return
+	}
+	server.inRecovery = true
+	for other, primary := range r.servers {
+		if other != name && !primary.inRecovery && primary.timeline > server.timeline {
+			server.timeline = primary.timeline
+		}
+	}
+}
+
+// Password returns password of the database role set on the server of the node
+func (r *fakeRepmgr) Password(name, role string) string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if server, ok := r.servers[name]; ok {
+		return server.passwords[role]
+	}
+	return ""
+}
+
+// SetSubscriptionState sets state of the apply worker of the subscription
+func (r *fakeRepmgr) SetSubscriptionState(name string, streaming bool, lag time.Duration) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if sub, ok := r.subscriptions[name]; ok {
+		sub.streaming = streaming
+		sub.lag = lag
+		r.subscriptions[name] = sub
+	}
+}
+
+// server returns running server of the node
+func (r *fakeRepmgr) server(name string) (*fakeServer, error) {
+	server, ok := r.servers[name]
+	if !ok || !server.running {
+		return nil, errFakeUnreachable
+	}
+	return server, nil
+}
+
+// slotActive checks whether a running standby streams from the slot
+func (r *fakeRepmgr) slotActive(slot string) bool {
+	standby, ok := r.servers[r.slots[slot]]
+	return ok && standby.running && standby.inRecovery
+}
+
+// fakeBackend is a client of a single fake server
+type fakeBackend struct {
+	repmgr *fakeRepmgr
+	node   string
+}
+
+func (b *fakeBackend) version(ctx context.Context) (string, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return "unknown", err
+	}
+	return server.version, nil
+}
+
+func (b *fakeBackend) isRegistered(ctx context.Context, nodeName string) (bool, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return false, err
+	}
+	_, ok := b.repmgr.records[nodeName]
+	return ok, nil
+}
+
+func (b *fakeBackend) getNodeInfo(ctx context.Context, nodeName string) (*nodeInfo, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	info := &nodeInfo{-1, postgresqlv1.PostgreSQLNodeRoleUnknown, -1}
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return info, err
+	}
+	record, ok := b.repmgr.records[nodeName]
+	if !ok {
+		return info, sql.ErrNoRows
+	}
+	return &record, nil
+}
+
+func (b *fakeBackend) updateNodePriority(ctx context.Context, nodeName string, priority int) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return err
+	}
+	if record, ok := b.repmgr.records[nodeName]; ok {
+		record.priority = priority
+		b.repmgr.records[nodeName] = record
+	}
+	return nil
+}
+
+func (b *fakeBackend) unregisterNode(ctx context.Context, nodeName string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return err
+	}
+	delete(b.repmgr.records, nodeName)
+	return nil
+}
+
+func (b *fakeBackend) registeredNodes(ctx context.Context) ([]string, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(b.repmgr.records))
+	for name := range b.repmgr.records {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// dropNodeSlot fails while the server of the node is running, as its slot is in use
+func (b *fakeBackend) dropNodeSlot(ctx context.Context, nodeName string) (string, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return "", err
+	}
+	for slot, node := range b.repmgr.slots {
+		if node != nodeName {
+			continue
+		}
+		if b.repmgr.slotActive(slot) {
+			return "", fmt.Errorf("Replication slot %v is still in use", slot)
+		}
+		delete(b.repmgr.slots, slot)
+		return slot, nil
+	}
+	return "", nil
+}
+
+func (b *fakeBackend) replicationStats(ctx context.Context) ([]replicationStat, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return nil, err
+	}
+	stats := []replicationStat{}
+	if server.inRecovery {
+		return stats, nil
+	}
+	for name, standby := range b.repmgr.servers {
+		if name != b.node && standby.running && standby.inRecovery {
+			stats = append(stats, replicationStat{applicationName: name, state: "streaming", lagBytes: standby.lag})
+		}
+	}
+	return stats, nil
+}
+
+// replicationSlots reports WAL retained by the slot as replay lag of its standby
+func (b *fakeBackend) replicationSlots(ctx context.Context) ([]replicationSlot, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return nil, err
+	}
+	slots := []replicationSlot{}
+	for name, node := range b.repmgr.slots {
+		slot := replicationSlot{name: name, active: b.repmgr.slotActive(name)}
+		if standby, ok := b.repmgr.servers[node]; ok {
+			slot.retainedBytes = standby.lag
+		}
+		slots = append(slots, slot)
+	}
+	sort.Slice(slots, func(i, j int) bool { return slots[i].name < slots[j].name })
+	return slots, nil
+}
+
+// createReplicationSlot assigns the slot to the node with id from the repmgr slot name
+func (b *fakeBackend) createReplicationSlot(ctx context.Context, slot string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return err
+	}
+	if server.inRecovery {
+		return fmt.Errorf("replication slots cannot be created during recovery")
+	}
+	if _, ok := b.repmgr.slots[slot]; ok {
+		return fmt.Errorf("replication slot \"%v\" already exists", slot)
+	}
+	b.repmgr.slots[slot] = ""
+	for name, record := range b.repmgr.records {
+		if slotName(record.id) == slot {
+			b.repmgr.slots[slot] = name
+		}
+	}
+	return nil
+}
+
+func (b *fakeBackend) dropReplicationSlot(ctx context.Context, slot string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return err
+	}
+	if _, ok := b.repmgr.slots[slot]; !ok {
+		return fmt.Errorf("replication slot \"%v\" does not exist", slot)
+	}
+	if b.repmgr.slotActive(slot) {
+		return fmt.Errorf("replication slot \"%v\" is active", slot)
+	}
+	delete(b.repmgr.slots, slot)
+	return nil
+}
+
+func (b *fakeBackend) isInRecovery(ctx context.Context) (bool, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return false, err
+	}
+	return server.inRecovery, nil
+}
+
+func (b *fakeBackend) timeline(ctx context.Context) (int, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return -1, err
+	}
+	return server.timeline, nil
+}
+
+func (b *fakeBackend) currentLSN(ctx context.Context) (string, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return "", err
+	}
+	return server.lsn, nil
+}
+
+func (b *fakeBackend) timelineHistory(ctx context.Context, timeline int) (string, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return "", err
+	}
+	if timeline < 2 || timeline > server.timeline {
+		return "", fmt.Errorf("History file of timeline %v not found", timeline)
+	}
+	return fmt.Sprintf("%v\t%v\tno recovery target specified\n", timeline-1, server.lsn), nil
+}
+
+func (b *fakeBackend) checkpoint(ctx context.Context) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	_, err := b.repmgr.server(b.node)
+	return err
+}
+
+func (b *fakeBackend) setPassword(ctx context.Context, role, password string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return err
+	}
+	if server.inRecovery {
+		return fmt.Errorf("cannot execute ALTER ROLE in a read-only transaction")
+	}
+	server.passwords[role] = password
+	return nil
+}
+
+func (b *fakeBackend) publications(ctx context.Context, dbname string) ([]publication, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return nil, err
+	}
+	pubs := []publication{}
+	for _, pub := range b.repmgr.publications {
+		pubs = append(pubs, pub)
+	}
+	sort.Slice(pubs, func(i, j int) bool { return pubs[i].name < pubs[j].name })
+	return pubs, nil
+}
+
+func (b *fakeBackend) createPublication(ctx context.Context, dbname string, pub publication) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("CREATE PUBLICATION"); err != nil {
+		return err
+	}
+	if _, ok := b.repmgr.publications[pub.name]; ok {
+		return fmt.Errorf("publication \"%v\" already exists", pub.name)
+	}
+	b.repmgr.publications[pub.name] = pub
+	return nil
+}
+
+func (b *fakeBackend) alterPublication(ctx context.Context, dbname string, pub publication) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("ALTER PUBLICATION"); err != nil {
+		return err
+	}
+	current, ok := b.repmgr.publications[pub.name]
+	if !ok {
+		return fmt.Errorf("publication \"%v\" does not exist", pub.name)
+	}
+	if current.allTables {
+		return fmt.Errorf("publication \"%v\" is defined as FOR ALL TABLES", pub.name)
+	}
+	b.repmgr.publications[pub.name] = pub
+	return nil
+}
+
+func (b *fakeBackend) dropPublication(ctx context.Context, dbname, name string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("DROP PUBLICATION"); err != nil {
+		return err
+	}
+	delete(b.repmgr.publications, name)
+	return nil
+}
+
+func (b *fakeBackend) subscriptions(ctx context.Context, dbname string) ([]subscription, error) {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if _, err := b.repmgr.server(b.node); err != nil {
+		return nil, err
+	}
+	subs := []subscription{}
+	for _, sub := range b.repmgr.subscriptions {
+		subs = append(subs, sub)
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].name < subs[j].name })
+	return subs, nil
+}
+
+// createSubscription creates enabled subscription streaming from the publisher
+func (b *fakeBackend) createSubscription(ctx context.Context, dbname string, sub subscription) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("CREATE SUBSCRIPTION"); err != nil {
+		return err
+	}
+	if _, ok := b.repmgr.subscriptions[sub.name]; ok {
+		return fmt.Errorf("subscription \"%v\" already exists", sub.name)
+	}
+	sub.enabled = true
+	sub.streaming = true
+	sub.lag = 0
+	b.repmgr.subscriptions[sub.name] = sub
+	return nil
+}
+
+func (b *fakeBackend) alterSubscription(ctx context.Context, dbname string, sub subscription) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("ALTER SUBSCRIPTION"); err != nil {
+		return err
+	}
+	current, ok := b.repmgr.subscriptions[sub.name]
+	if !ok {
+		return fmt.Errorf("subscription \"%v\" does not exist", sub.name)
+	}
+	current.conninfo = sub.conninfo
+	current.publications = sub.publications
+	b.repmgr.subscriptions[sub.name] = current
+	return nil
+}
+
+func (b *fakeBackend) dropSubscription(ctx context.Context, dbname, name string) error {
+	b.repmgr.mutex.Lock()
+	defer b.repmgr.mutex.Unlock()
+	if err := b.writable("DROP SUBSCRIPTION"); err != nil {
+		return err
+	}
+	delete(b.repmgr.subscriptions, name)
+	return nil
+}
+
+// writable fails if the server of the node is unreachable or in recovery
+func (b *fakeBackend) writable(statement string) error {
+	server, err := b.repmgr.server(b.node)
+	if err != nil {
+		return err
+	}
+	if server.inRecovery {
+		return fmt.Errorf("cannot execute %v in a read-only transaction", statement)
+	}
+	return nil
+}
+
+// fakeConnector returns clients of servers of the fake repmgr cluster
+type fakeConnector struct {
+	repmgr *fakeRepmgr
+}
+
+func (c *fakeConnector) client(key connectionKey, host, password string) sqlBackend {
+	return &fakeBackend{repmgr: c.repmgr, node: key.node}
+}
+
+func (c *fakeConnector) connect(ctx context.Context, key connectionKey, host, password string) (sqlBackend, error) {
+	backend := &fakeBackend{repmgr: c.repmgr, node: key.node}
+	c.repmgr.mutex.Lock()
+	defer c.repmgr.mutex.Unlock()
+	_, err := c.repmgr.server(key.node)
+	return backend, err
+}
+
+func (c *fakeConnector) close(key connectionKey) {}
+
+func (c *fakeConnector) closeCluster(namespace, cluster string) {}
@@ -0,0 +1,110 @@
+package k8shandler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// SplitBrainDetected is emitted when two nodes report primary role at the same time
+	SplitBrainDetected = "SplitBrainDetected"
+	// NodeFenced is emitted when a stale primary is isolated from clients
+	NodeFenced = "NodeFenced"
+	// NodeUnfenced is emitted when a fenced node rejoined as a standby
+	NodeUnfenced = "NodeUnfenced"
+)
+
+// resolvePrimary decides which of two nodes reporting primary role is the
+// authoritative one. If the current primary is not writable anymore, the
+// candidate was promoted by a regular failover and there is no stale node.
+// Otherwise the node promoted most recently runs on a higher timeline and
+// the other one is returned as stale.
+func resolvePrimary(ctx context.Context, current, candidate Node) (Node, Node) {
+	if !current.isReady() {
+		return candidate, nil
+	}
+	currentDB := current.dbClient()
+	inRecovery, err := currentDB.isInRecovery(ctx)
+	if err != nil || inRecovery {
+		return candidate, nil
+	}
+	candidateDB := candidate.dbClient()
+	inRecovery, err = candidateDB.isInRecovery(ctx)
+	if err != nil || inRecovery {
+		// repmgr metadata of the candidate is outdated, it isn't writable
+		return current, nil
+	}
+	currentTimeline, err := currentDB.timeline(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to retrieve timeline of node %v: %v", current.name(), err)
+	}
+	candidateTimeline, err := candidateDB.timeline(ctx)
+	if err != nil {
+		logrus.Errorf("Failed to retrieve timeline of node %v: %v", candidate.name(), err)
+	}
+	if candidateTimeline > currentTimeline {
+		return candidate, current
+	}
+	return current, candidate
+}
+
+// fenceStaleNode isolates stale primary and marks the cluster status with
+// SplitBrain condition
+func fenceStaleNode(request *PostgreSQLRequest, stale, primary Node, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	logrus.Warnf("Split-brain detected: nodes %v and %v both report primary role", stale.name(), primary.name())
+	if !stale.isFenced() {
+		request.recordWarning(SplitBrainDetected, "Nodes %v and %v both report primary role, %v is stale", stale.name(), primary.name(), stale.name())
+	}
+	setCondition(clusterStatus, postgresqlv1.SplitBrain, corev1.ConditionTrue, "MultiplePrimaries",
+		fmt.Sprintf("Node %v is stale primary, %v is the current primary", stale.name(), primary.name()))
+	if err := stale.fence(request, primary.dbClient()); err != nil {
+		return err
+	}
+	if status, ok := clusterStatus.Nodes[stale.name()]; ok {
+		status.Fenced = true
+		clusterStatus.Nodes[stale.name()] = status
+	}
+	request.recordWarning(NodeFenced, "Node %v removed from its services and restarted to rejoin %v", stale.name(), primary.name())
+	return nil
+}
+
+// unfenceRejoinedNode adds fenced node back to its service once it runs as a standby
+func unfenceRejoinedNode(request *PostgreSQLRequest, node Node) error {
+	if !node.isFenced() || !node.isReady() {
+		return nil
+	}
+	db := node.dbClient()
+	inRecovery, err := db.isInRecovery(request.ctx)
+	if err != nil || !inRecovery {
+		return err
+	}
+	if err := node.unfence(request); err != nil {
+		return err
+	}
+	request.recordNormal(NodeUnfenced, "Node %v rejoined as a standby", node.name())
+	return nil
+}
+
+// updateSplitBrainCondition clears SplitBrain condition when no fenced node remains
+func updateSplitBrainCondition(clusterStatus *postgresqlv1.PostgreSQLStatus) {
+	var fenced []string
+	for name, node := range nodes {
+		if node.isFenced() {
+			fenced = append(fenced, name)
+		}
+	}
+	if len(fenced) > 0 {
+		setCondition(clusterStatus, postgresqlv1.SplitBrain, corev1.ConditionTrue, "NodesFenced",
+			fmt.Sprintf("Fenced nodes waiting to rejoin: %v", strings.Join(fenced, ", ")))
+		return
+	}
+	if condition := getCondition(clusterStatus, postgresqlv1.SplitBrain); condition != nil && condition.Status == corev1.ConditionTrue {
+		setCondition(clusterStatus, postgresqlv1.SplitBrain, corev1.ConditionFalse, "Resolved", "Single primary node in the cluster")
+	}
+}
@@ -0,0 +1,187 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// newSplitBrainTest reconciles cluster of a primary node-one and a standby
+// node-two backed by the fake repmgr
+func newSplitBrainTest(t *testing.T) (*PostgreSQLRequest, *fakeRepmgr, *record.FakeRecorder) {
+	nodes = nil
+	primaryNode = nil
+	idSequence = 0
+	repmgr := newFakeRepmgr()
+	repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+	repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+	connections = &fakeConnector{repmgr: repmgr}
+	request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, map[string]int{"node-one": 1, "node-two": 2})
+	if _, err := request.CreateOrUpdateCluster(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	recordedReasons(recorder)
+	return request, repmgr, recorder
+}
+
+func TestResolvePrimary(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	table := []struct {
+		name      string
+		change    func(repmgr *fakeRepmgr)
+		current   string
+		candidate string
+		primary   string
+		stale     string
+	}{
+		{
+			name: "failover of unreachable primary",
+			change: func(repmgr *fakeRepmgr) {
+				repmgr.Stop("node-one")
+				repmgr.Promote("node-two")
+			},
+			current:   "node-one",
+			candidate: "node-two",
+			primary:   "node-two",
+		},
+		{
+			name:      "outdated metadata of a standby",
+			change:    func(repmgr *fakeRepmgr) {},
+			current:   "node-one",
+			candidate: "node-two",
+			primary:   "node-one",
+		},
+		{
+			name:      "candidate promoted behind the operator",
+			change:    func(repmgr *fakeRepmgr) { repmgr.Promote("node-two") },
+			current:   "node-one",
+			candidate: "node-two",
+			primary:   "node-two",
+			stale:     "node-one",
+		},
+		{
+			name:      "current primary on a higher timeline",
+			change:    func(repmgr *fakeRepmgr) { repmgr.Promote("node-two") },
+			current:   "node-two",
+			candidate: "node-one",
+			primary:   "node-two",
+			stale:     "node-one",
+		},
+	}
+	for _, tt := range table {
+		request, repmgr, _ := newSplitBrainTest(t)
+		tt.change(repmgr)
+		primary, stale := resolvePrimary(request.ctx, nodes[tt.current], nodes[tt.candidate])
+		staleName := ""
+		if stale != nil {
+			staleName = stale.name()
+		}
+		if primary.name() != tt.primary || staleName != tt.stale {
+			t.Errorf("Test %v failed, expected: '%v', '%v', got: '%v', '%v'", tt.name, tt.primary, tt.stale, primary.name(), staleName)
+		}
+	}
+}
+
+func TestFenceAndUnfence(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	request, repmgr, recorder := newSplitBrainTest(t)
+	selector := func(name string) (string, error) {
+		service := &corev1.Service{}
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: "default"}, service); err != nil {
+			return "", err
+		}
+		return service.Spec.Selector["node-name"], nil
+	}
+	for _, name := range []string{"node-one", "node-two"} {
+		pod := &corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: name + "-pod", Namespace: "default", Labels: newPodLabels(request.cluster.Name, name, false)},
+			Status:     corev1.PodStatus{Phase: corev1.PodRunning},
+		}
+		if err := request.client.Create(request.ctx, pod); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+	}
+
+	repmgr.Promote("node-two")
+	if _, err := request.CreateOrUpdateCluster(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if err := verifyFenced(request, selector, recordedReasons(recorder)); err != nil {
+		t.Errorf("Test fence failed, %v", err)
+	}
+	if selecting, err := servicesSelecting(request, "node-one-pod"); err != nil || len(selecting) != 0 {
+		t.Errorf("Test fence failed, expected no service selecting fenced pod, got: '%v', '%v'", selecting, err)
+	}
+
+	repmgr.Demote("node-one")
+	if _, err := request.CreateOrUpdateCluster(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if nodes["node-one"].isFenced() || request.cluster.Status.Nodes["node-one"].Fenced {
+		t.Errorf("Test unfence failed, node-one is still fenced")
+	}
+	if actual, err := selector("node-one"); err != nil || actual != "node-one" {
+		t.Errorf("Test unfence failed, expected: 'node-one', got: '%v', '%v'", actual, err)
+	}
+	if selecting, err := servicesSelecting(request, "node-one-pod"); err != nil || !containsString(selecting, "postgresql-ro") {
+		t.Errorf("Test unfence failed, expected read-only service selecting node-one, got: '%v', '%v'", selecting, err)
+	}
+	if condition := getCondition(&request.cluster.Status, postgresqlv1.SplitBrain); condition == nil || condition.Status != corev1.ConditionFalse {
+		t.Errorf("Test unfence failed, expected resolved SplitBrain condition, got: '%v'", condition)
+	}
+	if reasons := recordedReasons(recorder); !containsString(reasons, NodeUnfenced) {
+		t.Errorf("Test unfence failed, expected event: '%v', got: '%v'", NodeUnfenced, reasons)
+	}
+}
+
+// verifyFenced checks that stale node-one was isolated in favour of node-two
+func verifyFenced(request *PostgreSQLRequest, selector func(string) (string, error), reasons []string) error {
+	if primaryNode.name() != "node-two" {
+		return fmt.Errorf("expected primary: 'node-two', got: '%v'", primaryNode.name())
+	}
+	if !nodes["node-one"].isFenced() || !request.cluster.Status.Nodes["node-one"].Fenced {
+		return fmt.Errorf("node-one is not fenced")
+	}
+	if actual, err := selector("node-one"); err != nil || actual != "node-one-fenced" {
+		return fmt.Errorf("expected selector: 'node-one-fenced', got: '%v', '%v'", actual, err)
+	}
+	if actual, err := selector("postgresql-primary"); err != nil || actual != "node-two" {
+		return fmt.Errorf("expected primary service selector: 'node-two', got: '%v', '%v'", actual, err)
+	}
+	if condition := getCondition(&request.cluster.Status, postgresqlv1.SplitBrain); condition == nil || condition.Status != corev1.ConditionTrue {
+		return fmt.Errorf("expected SplitBrain condition, got: '%v'", condition)
+	}
+	for _, reason := range []string{SplitBrainDetected, NodeFenced} {
+		if !containsString(reasons, reason) {
+			return fmt.Errorf("expected event: '%v', got: '%v'", reason, reasons)
+		}
+	}
+	return nil
+}
+
+// servicesSelecting returns names of services whose selector matches the pod
+func servicesSelecting(request *PostgreSQLRequest, podName string) ([]string, error) {
+	pod := &corev1.Pod{}
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: podName, Namespace: "default"}, pod); err != nil {
+		return nil, err
+	}
+	services := &corev1.ServiceList{}
+	if err := request.client.List(request.ctx, &client.ListOptions{Namespace: "default"}, services); err != nil {
+		return nil, err
+	}
+	var selecting []string
+	for _, service := range services.Items {
+		if len(service.Spec.Selector) > 0 && labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.Labels)) {
+			selecting = append(selecting, service.Name)
+		}
+	}
+	return selecting, nil
+}
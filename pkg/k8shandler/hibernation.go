@@ -0,0 +1,187 @@
+package k8shandler
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// ClusterHibernated is emitted when all nodes of the cluster were shut down
+	ClusterHibernated = "ClusterHibernated"
+	// ClusterResumed is emitted when the hibernated cluster was started again
+	ClusterResumed = "ClusterResumed"
+)
+
+// Hibernate shuts down all nodes of the cluster, standby nodes first, the primary
+// is stopped once no standby is running. Deployments, volumes and the secret are kept.
+func (request *PostgreSQLRequest) Hibernate() (bool, error) {
+	if nodes == nil {
+		nodes = make(map[string]Node)
+	}
+	getNodes(request)
+
+	clusterStatus := request.cluster.Status.DeepCopy()
+	if clusterStatus.Hibernation == nil {
+		primary, err := currentPrimaryName(request)
+		if err != nil {
+			return true, err
+		}
+		logrus.Infof("Hibernating cluster %v, primary node is %v", request.cluster.Name, primary)
+		clusterStatus.Hibernation = &postgresqlv1.PostgreSQLHibernationStatus{
+			Phase:   postgresqlv1.HibernationPhaseHibernating,
+			Primary: primary,
+			Since:   metav1.Now(),
+		}
+	} else if clusterStatus.Hibernation.Phase == postgresqlv1.HibernationPhaseResuming {
+		clusterStatus.Hibernation.Phase = postgresqlv1.HibernationPhaseHibernating
+	}
+	hibernation := clusterStatus.Hibernation
+
+	if err := request.suspendDumps(); err != nil {
+		logrus.Errorf("Non-critical issue: %v", err)
+	}
+	requeue, err := stopNodes(request, hibernation.Primary)
+	if err == nil && !requeue && hibernation.Phase == postgresqlv1.HibernationPhaseHibernating {
+		hibernation.Phase = postgresqlv1.HibernationPhaseHibernated
+		hibernation.Since = metav1.Now()
+		request.recordNormal(ClusterHibernated, "All nodes were shut down, primary node is %v", hibernation.Primary)
+	}
+	if statusErr := UpdateClusterStatus(request, clusterStatus); statusErr != nil {
+		logrus.Errorf("Non-critical issue: %v", statusErr)
+		requeue = true
+	}
+	return requeue, err
+}
+
+// stopNodes checkpoints and scales down standby nodes, the primary node is
+// scaled down only after all standby nodes were stopped
+func stopNodes(request *PostgreSQLRequest, primary string) (bool, error) {
+	// the witness holds no data, it's stopped right away
+	if err := scaleDeployment(request, witnessName(request.cluster.Name), 0); err != nil && !errors.IsNotFound(err) {
+		return true, fmt.Errorf("Failed to scale down witness node: %v", err)
+	}
+	stopped := true
+	for name, node := range nodes {
+		if name == primary {
+			continue
+		}
+		if err := stopNode(request, node); err != nil {
+			return true, err
+		}
+		down, err := isDeploymentStopped(request, name)
+		if err != nil {
+			return true, err
+		}
+		stopped = stopped && down
+	}
+	if !stopped {
+		return true, nil
+	}
+	node, ok := nodes[primary]
+	if !ok {
+		return false, nil
+	}
+	if err := stopNode(request, node); err != nil {
+		return true, err
+	}
+	down, err := isDeploymentStopped(request, primary)
+	return !down, err
+}
+
+// stopNode performs checkpoint on the running node and scales its deployment to zero
+func stopNode(request *PostgreSQLRequest, node Node) error {
+	if node.isReady() {
+		if err := node.dbClient().checkpoint(request.ctx); err != nil {
+			logrus.Errorf("Failed to perform checkpoint on node %v: %v", node.name(), err)
+		}
+	}
+	if err := scaleDeployment(request, node.name(), 0); err != nil {
+		return fmt.Errorf("Failed to scale down node %v: %v", node.name(), err)
+	}
+	return nil
+}
+
+// resume starts the primary node recorded during hibernation, standby nodes
+// are started once the primary is ready
+func (request *PostgreSQLRequest) resume() (bool, error) {
+	if nodes == nil {
+		nodes = make(map[string]Node)
+	}
+	getNodes(request)
+
+	clusterStatus := request.cluster.Status.DeepCopy()
+	hibernation := clusterStatus.Hibernation
+	if hibernation.Phase != postgresqlv1.HibernationPhaseResuming {
+		logrus.Infof("Resuming cluster %v, starting primary node %v", request.cluster.Name, hibernation.Primary)
+		hibernation.Phase = postgresqlv1.HibernationPhaseResuming
+	}
+	if err := scaleDeployment(request, hibernation.Primary, 1); err != nil {
+		return true, fmt.Errorf("Failed to scale up primary node %v: %v", hibernation.Primary, err)
+	}
+	ready, err := isDeploymentReady(request, hibernation.Primary)
+	if err != nil || !ready {
+		if statusErr := UpdateClusterStatus(request, clusterStatus); statusErr != nil {
+			logrus.Errorf("Non-critical issue: %v", statusErr)
+		}
+		return true, err
+	}
+	for name := range nodes {
+		if err := scaleDeployment(request, name, 1); err != nil {
+			return true, fmt.Errorf("Failed to scale up node %v: %v", name, err)
+		}
+	}
+	clusterStatus.Hibernation = nil
+	if err := UpdateClusterStatus(request, clusterStatus); err != nil {
+		return true, err
+	}
+	request.recordNormal(ClusterResumed, "Primary node %v is running, standby nodes are starting", hibernation.Primary)
+	return false, nil
+}
+
+// currentPrimaryName returns name of the primary node known to the operator
+func currentPrimaryName(request *PostgreSQLRequest) (string, error) {
+	if primaryNode != nil {
+		return primaryNode.name(), nil
+	}
+	for name, status := range request.cluster.Status.Nodes {
+		if status.Role == postgresqlv1.PostgreSQLNodeRolePrimary {
+			return name, nil
+		}
+	}
+	node, err := getPrimaryNode(request.ctx)
+	if err != nil {
+		return "", err
+	}
+	return node.name(), nil
+}
+
+func getDeployment(request *PostgreSQLRequest, name string) (*appsv1.Deployment, error) {
+	deployment := &appsv1.Deployment{}
+	err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, deployment)
+	return deployment, err
+}
+
+// isDeploymentStopped checks whether all pods of the deployment are gone
+func isDeploymentStopped(request *PostgreSQLRequest, name string) (bool, error) {
+	deployment, err := getDeployment(request, name)
+	if err != nil {
+		return false, err
+	}
+	return deployment.Status.Replicas == 0, nil
+}
+
+// isDeploymentReady checks whether the pod of the deployment is ready
+func isDeploymentReady(request *PostgreSQLRequest, name string) (bool, error) {
+	deployment, err := getDeployment(request, name)
+	if err != nil {
+		return false, err
+	}
+	return deployment.Status.ReadyReplicas == 1, nil
+}
@@ -0,0 +1,171 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+
+	"k8s.io/client-go/tools/record"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// newHibernationTest returns request of the cluster with primary node-one and
+// standby nodes node-two and node-three, all running
+func newHibernationTest(t *testing.T) (*PostgreSQLRequest, *record.FakeRecorder) {
+	nodes = nil
+	primaryNode = nil
+	idSequence = 0
+	repmgr := newFakeRepmgr()
+	repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+	repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+	repmgr.AddNode("node-three", 3, postgresqlv1.PostgreSQLNodeRoleStandby, 60)
+	connections = &fakeConnector{repmgr: repmgr}
+	specNodes := map[string]int{"node-one": 100, "node-two": 80, "node-three": 60}
+	request, recorder := newTestRequest(t, specNodes, map[string]int{"node-one": 1, "node-two": 2, "node-three": 3})
+	for name := range specNodes {
+		setDeploymentState(t, request, name, 1, 1)
+	}
+	return request, recorder
+}
+
+// setDeploymentState sets number of replicas of the deployment, as reported by its controller
+func setDeploymentState(t *testing.T, request *PostgreSQLRequest, name string, replicas, ready int32) {
+	deployment, err := getDeployment(request, name)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	deployment.Status.Replicas = replicas
+	deployment.Status.ReadyReplicas = ready
+	if err := request.client.Update(request.ctx, deployment); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+}
+
+// verifyReplicas checks desired number of replicas of the deployments
+func verifyReplicas(request *PostgreSQLRequest, expected map[string]int32) error {
+	for name, replicas := range expected {
+		deployment, err := getDeployment(request, name)
+		if err != nil {
+			return err
+		}
+		if deployment.Spec.Replicas == nil || *deployment.Spec.Replicas != replicas {
+			return fmt.Errorf("expected %v replicas of %v, got: '%v'", replicas, name, deployment.Spec.Replicas)
+		}
+	}
+	return nil
+}
+
+func TestHibernate(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	request, recorder := newHibernationTest(t)
+	phase := func() postgresqlv1.HibernationPhase {
+		if request.cluster.Status.Hibernation == nil {
+			return ""
+		}
+		return request.cluster.Status.Hibernation.Phase
+	}
+
+	steps := []struct {
+		name string
+		// apply simulates the deployment controller before the reconcile
+		apply    func()
+		requeue  bool
+		replicas map[string]int32
+		phase    postgresqlv1.HibernationPhase
+	}{
+		{
+			name:     "standby nodes stopped first",
+			apply:    func() {},
+			requeue:  true,
+			replicas: map[string]int32{"node-one": 1, "node-two": 0, "node-three": 0},
+			phase:    postgresqlv1.HibernationPhaseHibernating,
+		},
+		{
+			name:     "primary kept running until standby pods are gone",
+			apply:    func() { setDeploymentState(t, request, "node-two", 0, 0) },
+			requeue:  true,
+			replicas: map[string]int32{"node-one": 1, "node-two": 0, "node-three": 0},
+			phase:    postgresqlv1.HibernationPhaseHibernating,
+		},
+		{
+			name:     "primary stopped last",
+			apply:    func() { setDeploymentState(t, request, "node-three", 0, 0) },
+			requeue:  true,
+			replicas: map[string]int32{"node-one": 0, "node-two": 0, "node-three": 0},
+			phase:    postgresqlv1.HibernationPhaseHibernating,
+		},
+		{
+			name:     "cluster hibernated once the primary pod is gone",
+			apply:    func() { setDeploymentState(t, request, "node-one", 0, 0) },
+			replicas: map[string]int32{"node-one": 0, "node-two": 0, "node-three": 0},
+			phase:    postgresqlv1.HibernationPhaseHibernated,
+		},
+	}
+	for _, step := range steps {
+		step.apply()
+		requeue, err := request.Hibernate()
+		if err != nil {
+			t.Fatalf("Test %v failed, unexpected error: %v", step.name, err)
+		}
+		if requeue != step.requeue {
+			t.Errorf("Test %v failed, expected requeue: '%v', got: '%v'", step.name, step.requeue, requeue)
+		}
+		if err := verifyReplicas(request, step.replicas); err != nil {
+			t.Errorf("Test %v failed, %v", step.name, err)
+		}
+		if actual := phase(); actual != step.phase {
+			t.Errorf("Test %v failed, expected phase: '%v', got: '%v'", step.name, step.phase, actual)
+		}
+	}
+	if primary := request.cluster.Status.Hibernation.Primary; primary != "node-one" {
+		t.Errorf("Test failed, expected primary: 'node-one', got: '%v'", primary)
+	}
+	if reasons := recordedReasons(recorder); !containsString(reasons, ClusterHibernated) {
+		t.Errorf("Test failed, expected event: '%v', got: '%v'", ClusterHibernated, reasons)
+	}
+}
+
+func TestResume(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	request, recorder := newHibernationTest(t)
+	for _, name := range []string{"node-one", "node-two", "node-three"} {
+		if err := scaleDeployment(request, name, 0); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		setDeploymentState(t, request, name, 0, 0)
+	}
+	// the recorded primary is started, even though it isn't the node with the highest priority
+	request.cluster.Status.Hibernation = &postgresqlv1.PostgreSQLHibernationStatus{
+		Phase:   postgresqlv1.HibernationPhaseHibernated,
+		Primary: "node-two",
+	}
+	if err := request.client.Update(request.ctx, request.cluster); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+
+	requeue, err := request.resume()
+	if err != nil || !requeue {
+		t.Fatalf("Test failed, expected requeue without error, got: '%v', '%v'", requeue, err)
+	}
+	if err := verifyReplicas(request, map[string]int32{"node-one": 0, "node-two": 1, "node-three": 0}); err != nil {
+		t.Errorf("Test primary started first failed, %v", err)
+	}
+	if phase := request.cluster.Status.Hibernation.Phase; phase != postgresqlv1.HibernationPhaseResuming {
+		t.Errorf("Test failed, expected phase: '%v', got: '%v'", postgresqlv1.HibernationPhaseResuming, phase)
+	}
+
+	setDeploymentState(t, request, "node-two", 1, 1)
+	requeue, err = request.resume()
+	if err != nil || requeue {
+		t.Fatalf("Test failed, expected no requeue without error, got: '%v', '%v'", requeue, err)
+	}
+	if err := verifyReplicas(request, map[string]int32{"node-one": 1, "node-two": 1, "node-three": 1}); err != nil {
+		t.Errorf("Test standby nodes started failed, %v", err)
+	}
+	if hibernation := request.cluster.Status.Hibernation; hibernation != nil {
+		t.Errorf("Test failed, expected no hibernation, got: '%v'", hibernation)
+	}
+	if reasons := recordedReasons(recorder); !containsString(reasons, ClusterResumed) {
+		t.Errorf("Test failed, expected event: '%v', got: '%v'", ClusterResumed, reasons)
+	}
+}
@@ -0,0 +1,207 @@
+package k8shandler
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// importUserEnv and importPasswordEnv hold credentials of the import source
+	importUserEnv     = "IMPORT_USER"
+	importPasswordEnv = "IMPORT_PASSWORD"
+
+	// importScript creates missing databases owned by the application user and
+	// restores dumps of the source databases passed as arguments, names are
+	// passed to psql as a variable, which is interpolated only in stdin
+	importScript = `set -eo pipefail
+for db in "$@"; do
+  export PGPASSWORD="$TARGET_PASSWORD"
+  exists=$(echo "SELECT 1 FROM pg_database WHERE datname = :'db'" | psql -h "$TARGET_HOST" -U repmgr -d postgres -At -v db="$db")
+  if [ -z "$exists" ]; then
+    createdb -h "$TARGET_HOST" -U repmgr -O "$POSTGRESQL_USER" "$db"
+  fi
+  PGPASSWORD="$IMPORT_PASSWORD" pg_dump -Fc -h "$IMPORT_HOST" -p "$IMPORT_PORT" -U "$IMPORT_USER" "$db" |
+    pg_restore --no-owner --role="$POSTGRESQL_USER" -h "$TARGET_HOST" -U repmgr -d "$db"
+done`
+)
+
+// importSpec returns the import of the cluster, nil if none is configured
+func (request *PostgreSQLRequest) importSpec() *postgresqlv1.PostgreSQLImportSpec {
+	if request.cluster.Spec.Bootstrap == nil {
+		return nil
+	}
+	return request.cluster.Spec.Bootstrap.Import
+}
+
+// importType returns type of the import, dump is the default
+func importType(spec *postgresqlv1.PostgreSQLImportSpec) postgresqlv1.ImportType {
+	if spec.Type == "" {
+		return postgresqlv1.ImportTypeDump
+	}
+	return spec.Type
+}
+
+// isNewCluster checks whether none of the nodes of the cluster was ever ready
+// and no import was started, data can be imported only into such cluster
+func isNewCluster(status *postgresqlv1.PostgreSQLStatus) bool {
+	return status.Import == nil && len(status.Nodes) == 0
+}
+
+// cloneImport checks whether the primary node is cloned from the import source
+func (request *PostgreSQLRequest) cloneImport() bool {
+	spec := request.importSpec()
+	if spec == nil || request.isReplica() || importType(spec) != postgresqlv1.ImportTypeClone {
+		return false
+	}
+	status := &request.cluster.Status
+	return isNewCluster(status) || status.Import.Phase == postgresqlv1.ImportPhaseRunning
+}
+
+// importJobName returns name of the job restoring dumps of the source databases
+func importJobName(clusterName string) string {
+	return fmt.Sprintf("%s-import", clusterName)
+}
+
+// newImportJob returns Job which restores dumps of the source databases into the target node
+func newImportJob(request *PostgreSQLRequest, spec *postgresqlv1.PostgreSQLImportSpec, target string) *batchv1.Job {
+	var backoffLimit int32 = 2
+	name := importJobName(request.cluster.Name)
+	// the job doesn't have the cluster-name label, its pod is not a node of the cluster
+	labels := map[string]string{"import-of": request.cluster.Name}
+	port := spec.Source.Port
+	if port == 0 {
+		port = postgresqlPort
+	}
+	databases := spec.Databases
+	if len(databases) == 0 {
+		databases = []string{newPgEnvironment().database}
+	}
+	secret := request.credentialsSecret()
+	job := &batchv1.Job{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Job",
+			APIVersion: batchv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: request.cluster.Namespace,
+			Labels:    labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit: &backoffLimit,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers: []corev1.Container{{
+						Name:    name,
+						Image:   newImage(request.template(), ""),
+						Command: append([]string{"bash", "-c", importScript, name}, databases...),
+						Env: []corev1.EnvVar{
+							{Name: "IMPORT_HOST", Value: spec.Source.Host},
+							{Name: "IMPORT_PORT", Value: fmt.Sprintf("%v", port)},
+							newSecretEnvVar(importUserEnv, spec.Source.CredentialsSecret, corev1.BasicAuthUsernameKey),
+							newSecretEnvVar(importPasswordEnv, spec.Source.CredentialsSecret, corev1.BasicAuthPasswordKey),
+							{Name: "TARGET_HOST", Value: target},
+							newSecretEnvVar("TARGET_PASSWORD", secret.name, secret.repmgrKey),
+							{Name: "POSTGRESQL_USER", Value: newPgEnvironment().user},
+						},
+					}},
+				},
+			},
+		},
+	}
+	controllerutil.SetControllerReference(request.cluster, job, request.scheme)
+	return job
+}
+
+// reconcileImport imports data of the external database into a new cluster,
+// the clone import is done by the primary node itself, the dump import runs
+// in a job once the primary node is ready, the result is kept in the status
+func (request *PostgreSQLRequest) reconcileImport(clusterStatus *postgresqlv1.PostgreSQLStatus) (bool, error) {
+	spec := request.importSpec()
+	if spec == nil || request.isReplica() {
+		return false, nil
+	}
+	status := clusterStatus.Import
+	if status == nil {
+		if !isNewCluster(clusterStatus) {
+			logrus.Infof("Cluster %v is already initialized, import skipped", request.cluster.Name)
+			return false, nil
+		}
+		now := metav1.Now()
+		status = &postgresqlv1.PostgreSQLImportStatus{Type: importType(spec), Phase: postgresqlv1.ImportPhaseRunning, StartTime: &now}
+		clusterStatus.Import = status
+		request.recordNormal(ImportStarted, "Import (%v) from %v started", status.Type, spec.Source.Host)
+	}
+	if status.Phase != postgresqlv1.ImportPhaseRunning {
+		return false, nil
+	}
+	if primaryNode == nil || !primaryNode.isReady() {
+		return true, nil
+	}
+
+	switch status.Type {
+	case postgresqlv1.ImportTypeClone:
+		inRecovery, err := primaryNode.dbClient().isInRecovery(request.ctx)
+		if err != nil || inRecovery {
+			return true, err
+		}
+		now := metav1.Now()
+		request.finishImport(status, postgresqlv1.ImportPhaseSucceeded, &now, "")
+		return false, nil
+	case postgresqlv1.ImportTypeDump:
+		name := importJobName(request.cluster.Name)
+		job := &batchv1.Job{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, job)
+		switch {
+		case errors.IsNotFound(err):
+			logrus.Infof("Creating import job %v", name)
+			if err := request.client.Create(request.ctx, newImportJob(request, spec, primaryNode.name())); err != nil && !errors.IsAlreadyExists(err) {
+				return true, fmt.Errorf("Failed to create import job %v: %v", name, err)
+			}
+			return true, nil
+		case err != nil:
+			return true, fmt.Errorf("Failed to get import job %v: %v", name, err)
+		}
+		if job.Status.Succeeded > 0 {
+			completion := job.Status.CompletionTime
+			if completion == nil {
+				now := metav1.Now()
+				completion = &now
+			}
+			request.finishImport(status, postgresqlv1.ImportPhaseSucceeded, completion, "")
+			return false, nil
+		}
+		if condition := jobFailure(job); condition != nil {
+			request.finishImport(status, postgresqlv1.ImportPhaseFailed, &condition.LastTransitionTime, condition.Message)
+			return false, nil
+		}
+		return true, nil
+	}
+	return false, fmt.Errorf("Unknown import type %v", status.Type)
+}
+
+// finishImport records result of the import
+func (request *PostgreSQLRequest) finishImport(status *postgresqlv1.PostgreSQLImportStatus, phase postgresqlv1.ImportPhase, completion *metav1.Time, message string) {
+	status.Phase = phase
+	status.CompletionTime = completion
+	status.Message = message
+	duration := completion.Sub(status.StartTime.Time)
+	if phase == postgresqlv1.ImportPhaseFailed {
+		request.recordWarning(ImportFailed, "Import (%v) failed after %v: %v", status.Type, duration, message)
+		return
+	}
+	request.recordNormal(ImportSucceeded, "Import (%v) finished in %v", status.Type, duration)
+}
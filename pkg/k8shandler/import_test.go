@@ -0,0 +1,198 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileImport(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	source := postgresqlv1.PostgreSQLImportSource{Host: "legacy.example.com", Port: 5433, CredentialsSecret: "legacy-credentials"}
+	// times are stored with a precision of seconds
+	started := metav1.Date(2019, 10, 1, 12, 0, 0, 0, time.UTC)
+	completed := metav1.Date(2019, 10, 1, 12, 10, 0, 0, time.UTC)
+	running := func(importType postgresqlv1.ImportType) *postgresqlv1.PostgreSQLImportStatus {
+		return &postgresqlv1.PostgreSQLImportStatus{Type: importType, Phase: postgresqlv1.ImportPhaseRunning, StartTime: &started}
+	}
+	getJob := func(request *PostgreSQLRequest) (*batchv1.Job, error) {
+		job := &batchv1.Job{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: "example-import", Namespace: "default"}, job)
+		return job, err
+	}
+
+	table := []struct {
+		name     string
+		spec     postgresqlv1.PostgreSQLImportSpec
+		deployed map[string]int
+		status   *postgresqlv1.PostgreSQLImportStatus
+		job      *batchv1.JobStatus
+		verify   func(request *PostgreSQLRequest, reasons []string) error
+	}{
+		{
+			name: "primary node of a new cluster cloned from the source",
+			spec: postgresqlv1.PostgreSQLImportSpec{Type: postgresqlv1.ImportTypeClone, Source: source},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				deployment := &appsv1.Deployment{}
+				if err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-one", Namespace: "default"}, deployment); err != nil {
+					return err
+				}
+				if value := containerEnv(deployment, "STARTUP_OPERATION"); value != ImportClone {
+					return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", ImportClone, value)
+				}
+				host, port := containerEnv(deployment, upstreamHostEnv), containerEnv(deployment, upstreamPortEnv)
+				if host != "legacy.example.com" || port != "5433" {
+					return fmt.Errorf("expected upstream: 'legacy.example.com:5433', got: '%v:%v'", host, port)
+				}
+				found := false
+				for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+					if env.Name == importPasswordEnv && env.ValueFrom != nil && env.ValueFrom.SecretKeyRef.Name == "legacy-credentials" {
+						found = true
+					}
+				}
+				if !found {
+					return fmt.Errorf("expected %v from secret 'legacy-credentials'", importPasswordEnv)
+				}
+				status := request.cluster.Status.Import
+				if status == nil || status.Phase != postgresqlv1.ImportPhaseRunning || status.StartTime == nil {
+					return fmt.Errorf("expected running import, got: '%v'", status)
+				}
+				if !containsString(reasons, ImportStarted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ImportStarted, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "clone import finished once the primary accepts writes",
+			spec:     postgresqlv1.PostgreSQLImportSpec{Type: postgresqlv1.ImportTypeClone, Source: source},
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   running(postgresqlv1.ImportTypeClone),
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				status := request.cluster.Status.Import
+				if status.Phase != postgresqlv1.ImportPhaseSucceeded || status.CompletionTime == nil {
+					return fmt.Errorf("expected succeeded import, got: '%v'", status)
+				}
+				if !containsString(reasons, ImportSucceeded) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ImportSucceeded, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "dump job created once the primary is ready",
+			spec:     postgresqlv1.PostgreSQLImportSpec{Source: source, Databases: []string{"orders", "customers"}},
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   running(postgresqlv1.ImportTypeDump),
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				job, err := getJob(request)
+				if err != nil {
+					return err
+				}
+				container := job.Spec.Template.Spec.Containers[0]
+				if args := container.Command[len(container.Command)-2:]; args[0] != "orders" || args[1] != "customers" {
+					return fmt.Errorf("expected databases: '[orders customers]', got: '%v'", args)
+				}
+				if host := containerEnvValue(&container, "TARGET_HOST"); host != "node-one" {
+					return fmt.Errorf("expected TARGET_HOST: 'node-one', got: '%v'", host)
+				}
+				if phase := request.cluster.Status.Import.Phase; phase != postgresqlv1.ImportPhaseRunning {
+					return fmt.Errorf("expected phase: '%v', got: '%v'", postgresqlv1.ImportPhaseRunning, phase)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "dump import succeeded",
+			spec:     postgresqlv1.PostgreSQLImportSpec{Source: source},
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   running(postgresqlv1.ImportTypeDump),
+			job:      &batchv1.JobStatus{Succeeded: 1, CompletionTime: &completed},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				status := request.cluster.Status.Import
+				if status.Phase != postgresqlv1.ImportPhaseSucceeded {
+					return fmt.Errorf("expected phase: '%v', got: '%v'", postgresqlv1.ImportPhaseSucceeded, status.Phase)
+				}
+				if status.CompletionTime == nil || !status.CompletionTime.Equal(&completed) {
+					return fmt.Errorf("expected completion time: '%v', got: '%v'", completed, status.CompletionTime)
+				}
+				if !containsString(reasons, ImportSucceeded) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ImportSucceeded, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "dump import failed",
+			spec:     postgresqlv1.PostgreSQLImportSpec{Source: source},
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   running(postgresqlv1.ImportTypeDump),
+			job: &batchv1.JobStatus{Failed: 3, Conditions: []batchv1.JobCondition{
+				{Type: batchv1.JobFailed, Status: corev1.ConditionTrue, LastTransitionTime: completed, Message: "Job has reached the specified backoff limit"},
+			}},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				status := request.cluster.Status.Import
+				if status.Phase != postgresqlv1.ImportPhaseFailed || status.Message != "Job has reached the specified backoff limit" {
+					return fmt.Errorf("expected failed import, got: '%v'", status)
+				}
+				if !containsString(reasons, ImportFailed) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ImportFailed, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "initialized cluster not imported",
+			spec:     postgresqlv1.PostgreSQLImportSpec{Source: source},
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			verify: func(request *PostgreSQLRequest, reasons []string) error {
+				if status := request.cluster.Status.Import; status != nil {
+					return fmt.Errorf("expected no import, got: '%v'", status)
+				}
+				if _, err := getJob(request); err == nil {
+					return fmt.Errorf("import job was created")
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, tt.deployed)
+		spec := tt.spec
+		if tt.job != nil {
+			job := newImportJob(request, &spec, "node-one")
+			job.Status = *tt.job
+			if err := request.client.Create(request.ctx, job); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		request.cluster.Spec.Bootstrap = &postgresqlv1.PostgreSQLBootstrapSpec{Import: &spec}
+		request.cluster.Status.Import = tt.status
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.verify(request, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
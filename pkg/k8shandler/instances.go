@@ -0,0 +1,128 @@
+package k8shandler
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	"k8s.io/apimachinery/pkg/labels"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// instancesMaxPriority is the priority of the first generated node, priorities
+// of following nodes decrease down to 1
+const instancesMaxPriority = 100
+
+// specNodes returns nodes of the cluster, listed in the spec or generated to
+// match number of instances
+func (request *PostgreSQLRequest) specNodes() map[string]postgresqlv1.PostgreSQLNode {
+	if request.cluster.Spec.Instances == nil {
+		return request.cluster.Spec.Nodes
+	}
+	return request.generateNodes(int(*request.cluster.Spec.Instances))
+}
+
+// generateNodes keeps deployed nodes of the cluster and adds or removes
+// generated nodes to get the number of instances, values of nodes listed in
+// the spec are kept
+func (request *PostgreSQLRequest) generateNodes(instances int) map[string]postgresqlv1.PostgreSQLNode {
+	if instances < 1 {
+		instances = 1
+	}
+	members := make(map[string]bool, len(nodes))
+	for name := range nodes {
+		members[name] = true
+	}
+	for _, name := range request.scaleDownCandidates() {
+		if len(members) <= instances {
+			break
+		}
+		logrus.Infof("Scaling down cluster %v, removing node %v", request.cluster.Name, name)
+		delete(members, name)
+	}
+	for index := 1; len(members) < instances; index++ {
+		members[instanceName(request.cluster.Name, index)] = true
+	}
+	generated := make(map[string]postgresqlv1.PostgreSQLNode, len(members))
+	for name := range members {
+		if specNode, ok := request.cluster.Spec.Nodes[name]; ok {
+			generated[name] = specNode
+		} else {
+			generated[name] = postgresqlv1.PostgreSQLNode{Priority: instancePriority(instanceIndex(request.cluster.Name, name))}
+		}
+	}
+	return generated
+}
+
+// scaleDownCandidates returns deployed standby nodes ordered by replication
+// lag, nodes which don't stream from the primary come first, the primary is
+// never returned
+func (request *PostgreSQLRequest) scaleDownCandidates() []string {
+	if len(nodes) <= int(*request.cluster.Spec.Instances) {
+		return []string{}
+	}
+	if primaryNode == nil {
+		logrus.Infof("Primary node of cluster %v is unknown, scale down postponed", request.cluster.Name)
+		return []string{}
+	}
+	lag := make(map[string]int64, len(nodes))
+	stats, err := primaryNode.dbClient().replicationStats(request.ctx)
+	if err != nil {
+		logrus.Errorf("Failed to query replication lag of standbys, removing nodes in reverse order: %v", err)
+	}
+	for _, stat := range stats {
+		if stat.state == "streaming" {
+			lag[stat.applicationName] = stat.lagBytes
+		}
+	}
+	candidates := make([]string, 0, len(nodes))
+	for name := range nodes {
+		if name == primaryNode.name() {
+			continue
+		}
+		if _, ok := lag[name]; !ok {
+			lag[name] = math.MaxInt64
+		}
+		candidates = append(candidates, name)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		if lag[candidates[i]] != lag[candidates[j]] {
+			return lag[candidates[i]] > lag[candidates[j]]
+		}
+		return candidates[i] > candidates[j]
+	})
+	return candidates
+}
+
+// instanceName returns name of the generated node with the index
+func instanceName(cluster string, index int) string {
+	return fmt.Sprintf("%s-%d", cluster, index)
+}
+
+// instanceIndex returns index of the generated node, zero if the name wasn't
+// generated for the cluster
+func instanceIndex(cluster, name string) int {
+	index, err := strconv.Atoi(strings.TrimPrefix(name, cluster+"-"))
+	if !strings.HasPrefix(name, cluster+"-") || err != nil || index < 1 {
+		return 0
+	}
+	return index
+}
+
+// instancePriority returns priority of the generated node with the index
+func instancePriority(index int) int {
+	if index < 1 || index >= instancesMaxPriority {
+		return 1
+	}
+	return instancesMaxPriority - index + 1
+}
+
+// instancesStatus sets number of deployed nodes and selector of their pods
+func (request *PostgreSQLRequest) instancesStatus(clusterStatus *postgresqlv1.PostgreSQLStatus) {
+	clusterStatus.Instances = int32(len(nodes))
+	clusterStatus.Selector = labels.SelectorFromSet(newLabels(request.cluster.Name, "")).String()
+}
@@ -0,0 +1,45 @@
+package k8shandler
+
+import (
+	"testing"
+)
+
+func TestInstanceIndex(t *testing.T) {
+	table := []struct {
+		name     string
+		expected int
+	}{
+		{"example-1", 1},
+		{"example-12", 12},
+		{"example-0", 0},
+		{"example-one", 0},
+		{"other-1", 0},
+		{"example", 0},
+	}
+	for _, tt := range table {
+		actual := instanceIndex("example", tt.name)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestInstancePriority(t *testing.T) {
+	table := []struct {
+		index    int
+		expected int
+	}{
+		{1, 100},
+		{2, 99},
+		{99, 2},
+		{100, 1},
+		{250, 1},
+		{0, 1},
+	}
+	for _, tt := range table {
+		actual := instancePriority(tt.index)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+// +build integration
+
+package k8shandler_test
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"k8s.io/client-go/rest"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/envtest"
+	"sigs.k8s.io/controller-runtime/pkg/manager"
+
+	"github.com/mcyprian/postgresql-operator/pkg/apis"
+	"github.com/mcyprian/postgresql-operator/pkg/controller/postgresql"
+	"github.com/mcyprian/postgresql-operator/pkg/k8shandler"
+)
+
+var (
+	cfg        *rest.Config
+	testClient client.Client
+	repmgr     *k8shandler.FakeRepmgr
+)
+
+// TestMain starts local API server and etcd, binaries are located using
+// KUBEBUILDER_ASSETS variable, and runs the operator against them. Databases
+// of the nodes are simulated by the fake repmgr cluster. The operator shares
+// package state with unit tests, so only integration tests are run with the
+// integration tag, see test-integration target of the Makefile.
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+func run(m *testing.M) int {
+	// reconcile frequently, there are no pod events in the test environment
+	for name, value := range map[string]string{
+		"health-check-interval": "1s",
+		"requeue-base-delay":    "100ms",
+		"requeue-max-delay":     "1s",
+	} {
+		if err := flag.Set(name, value); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to set flag %v: %v\n", name, err)
+			return 1
+		}
+	}
+
+	testEnv := &envtest.Environment{
+		CRDDirectoryPaths: []string{filepath.Join("..", "..", "deploy", "crds")},
+	}
+	var err error
+	cfg, err = testEnv.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to start test environment: %v\n", err)
+		return 1
+	}
+	defer testEnv.Stop()
+
+	repmgr = k8shandler.NewFakeRepmgr()
+	defer k8shandler.UseFakeRepmgr(repmgr)()
+
+	mgr, err := manager.New(cfg, manager.Options{MetricsBindAddress: "0"})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create manager: %v\n", err)
+		return 1
+	}
+	if err := apis.AddToScheme(mgr.GetScheme()); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to register API types: %v\n", err)
+		return 1
+	}
+	if err := postgresql.Add(mgr); err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to add controller: %v\n", err)
+		return 1
+	}
+	// assertions read directly from the API server, not from the cache of the manager
+	testClient, err = client.New(cfg, client.Options{Scheme: mgr.GetScheme()})
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to create client: %v\n", err)
+		return 1
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		if err := mgr.Start(stop); err != nil {
+			fmt.Fprintf(os.Stderr, "Failed to run manager: %v\n", err)
+		}
+	}()
+	return m.Run()
+}
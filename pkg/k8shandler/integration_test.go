@@ -0,0 +1,270 @@
+// +build integration
+
+package k8shandler_test
+
+import (
+	goctx "context"
+	"fmt"
+	"strconv"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/util/retry"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	retryInterval    = time.Millisecond * 250
+	timeout          = time.Second * 30
+	namespace        = "integration"
+	postgreSQLCRName = "example"
+)
+
+func newTestCluster() *postgresqlv1.PostgreSQL {
+	storageClass := "standard"
+	size := resource.MustParse("1Gi")
+	return &postgresqlv1.PostgreSQL{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "PostgreSQL",
+			APIVersion: postgresqlv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      postgreSQLCRName,
+			Namespace: namespace,
+		},
+		Spec: postgresqlv1.PostgreSQLSpec{
+			ManagementState: postgresqlv1.ManagementStateManaged,
+			Nodes: map[string]postgresqlv1.PostgreSQLNode{
+				"node-one": postgresqlv1.PostgreSQLNode{
+					Priority: 100,
+					Storage:  postgresqlv1.PostgreSQLStorageSpec{StorageClassName: &storageClass, Size: &size},
+				},
+				"node-two": postgresqlv1.PostgreSQLNode{
+					Priority: 50,
+					Storage:  postgresqlv1.PostgreSQLStorageSpec{},
+				},
+			},
+		},
+		Status: postgresqlv1.PostgreSQLStatus{
+			Nodes: map[string]postgresqlv1.PostgreSQLNodeStatus{},
+		},
+	}
+}
+
+// waitFor polls the condition until it succeeds, the last error is reported on timeout
+func waitFor(t *testing.T, description string, condition func() error) {
+	var lastErr error
+	err := wait.PollImmediate(retryInterval, timeout, func() (bool, error) {
+		lastErr = condition()
+		return lastErr == nil, nil
+	})
+	if err != nil {
+		t.Fatalf("Timed out waiting for %v: %v", description, lastErr)
+	}
+}
+
+func getObject(name string, obj runtime.Object) error {
+	return testClient.Get(goctx.TODO(), types.NamespacedName{Name: name, Namespace: namespace}, obj)
+}
+
+func containerEnv(deployment *appsv1.Deployment, name string) string {
+	for _, env := range deployment.Spec.Template.Spec.Containers[0].Env {
+		if env.Name == name {
+			return env.Value
+		}
+	}
+	return ""
+}
+
+// serviceSelector returns name of the node selected by the service
+func serviceSelector(name string) (string, error) {
+	service := &corev1.Service{}
+	if err := getObject(name, service); err != nil {
+		return "", err
+	}
+	return service.Spec.Selector["node-name"], nil
+}
+
+// markReady starts database of the node in the fake repmgr cluster and reports
+// its deployment ready, as there is no deployment controller in the test environment
+func markReady(t *testing.T, name string, role postgresqlv1.PostgreSQLNodeRole, priority int) {
+	deployment := &appsv1.Deployment{}
+	if err := getObject(name, deployment); err != nil {
+		t.Fatalf("Failed to get deployment %v: %v", name, err)
+	}
+	id, err := strconv.Atoi(containerEnv(deployment, "NODE_ID"))
+	if err != nil {
+		t.Fatalf("Invalid id of node %v: %v", name, err)
+	}
+	repmgr.AddNode(name, id, role, priority)
+	err = retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := getObject(name, deployment); err != nil {
+			return err
+		}
+		deployment.Status.Replicas = 1
+		deployment.Status.ReadyReplicas = 1
+		return testClient.Status().Update(goctx.TODO(), deployment)
+	})
+	if err != nil {
+		t.Fatalf("Failed to update status of deployment %v: %v", name, err)
+	}
+}
+
+func TestPostgreSQLReconcile(t *testing.T) {
+	ns := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: namespace}}
+	if err := testClient.Create(goctx.TODO(), ns); err != nil {
+		t.Fatalf("Failed to create namespace: %v", err)
+	}
+	if err := testClient.Create(goctx.TODO(), newTestCluster()); err != nil {
+		t.Fatalf("Failed to create PostgreSQL cluster: %v", err)
+	}
+
+	t.Run("Secret", func(t *testing.T) {
+		waitFor(t, "secret with passwords", func() error {
+			secret := &corev1.Secret{}
+			if err := getObject(postgreSQLCRName, secret); err != nil {
+				return err
+			}
+			for _, key := range []string{"database-password", "repmgr-password"} {
+				if len(secret.Data[key]) == 0 {
+					return fmt.Errorf("password %v is missing", key)
+				}
+			}
+			return nil
+		})
+	})
+
+	t.Run("Deployments", func(t *testing.T) {
+		table := []struct {
+			name      string
+			operation string
+			id        string
+			claimName string
+		}{
+			{"node-one", "primary register", "1", "example-node-one"},
+			{"node-two", "standby register", "2", ""},
+		}
+		for _, tt := range table {
+			deployment := &appsv1.Deployment{}
+			waitFor(t, "deployment "+tt.name, func() error { return getObject(tt.name, deployment) })
+			if actual := containerEnv(deployment, "STARTUP_OPERATION"); actual != tt.operation {
+				t.Errorf("Test failed, expected: '%v', got: '%v'", tt.operation, actual)
+			}
+			if actual := containerEnv(deployment, "NODE_ID"); actual != tt.id {
+				t.Errorf("Test failed, expected: '%v', got: '%v'", tt.id, actual)
+			}
+			volume := deployment.Spec.Template.Spec.Volumes[0]
+			if tt.claimName == "" && volume.EmptyDir == nil {
+				t.Errorf("Test failed, expected emptyDir volume, got: '%v'", volume.VolumeSource)
+			}
+			if tt.claimName != "" && (volume.PersistentVolumeClaim == nil || volume.PersistentVolumeClaim.ClaimName != tt.claimName) {
+				t.Errorf("Test failed, expected claim: '%v', got: '%v'", tt.claimName, volume.VolumeSource)
+			}
+			if owner := metav1.GetControllerOf(deployment); owner == nil || owner.Name != postgreSQLCRName {
+				t.Errorf("Test failed, expected owner: '%v', got: '%v'", postgreSQLCRName, owner)
+			}
+		}
+	})
+
+	t.Run("PersistentVolumeClaim", func(t *testing.T) {
+		claim := &corev1.PersistentVolumeClaim{}
+		waitFor(t, "volume claim of node-one", func() error { return getObject("example-node-one", claim) })
+		expected := resource.MustParse("1Gi")
+		if actual := claim.Spec.Resources.Requests[corev1.ResourceStorage]; actual.Cmp(expected) != 0 {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", expected.String(), actual.String())
+		}
+	})
+
+	t.Run("Services", func(t *testing.T) {
+		for name, expected := range map[string]string{
+			"postgresql-primary": "node-one",
+			"postgresql-ro":      "",
+			"node-one":           "node-one",
+			"node-two":           "node-two",
+		} {
+			waitFor(t, "service "+name, func() error {
+				actual, err := serviceSelector(name)
+				if err != nil {
+					return err
+				}
+				if actual != expected {
+					return fmt.Errorf("expected selector: '%v', got: '%v'", expected, actual)
+				}
+				return nil
+			})
+		}
+	})
+
+	t.Run("Status", func(t *testing.T) {
+		markReady(t, "node-one", postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		markReady(t, "node-two", postgresqlv1.PostgreSQLNodeRoleStandby, 50)
+		waitFor(t, "roles of nodes in status", func() error {
+			cluster := &postgresqlv1.PostgreSQL{}
+			if err := getObject(postgreSQLCRName, cluster); err != nil {
+				return err
+			}
+			for name, expected := range map[string]postgresqlv1.PostgreSQLNodeRole{
+				"node-one": postgresqlv1.PostgreSQLNodeRolePrimary,
+				"node-two": postgresqlv1.PostgreSQLNodeRoleStandby,
+			} {
+				if actual := cluster.Status.Nodes[name].Role; actual != expected {
+					return fmt.Errorf("expected role of %v: '%v', got: '%v'", name, expected, actual)
+				}
+			}
+			return nil
+		})
+	})
+
+	t.Run("Failover", func(t *testing.T) {
+		repmgr.Stop("node-one")
+		repmgr.Promote("node-two")
+		waitFor(t, "primary service selector update", func() error {
+			actual, err := serviceSelector("postgresql-primary")
+			if err != nil {
+				return err
+			}
+			if actual != "node-two" {
+				return fmt.Errorf("expected selector: '%v', got: '%v'", "node-two", actual)
+			}
+			return nil
+		})
+	})
+
+	t.Run("NodeDeletion", func(t *testing.T) {
+		err := retry.RetryOnConflict(retry.DefaultRetry, func() error {
+			cluster := &postgresqlv1.PostgreSQL{}
+			if err := getObject(postgreSQLCRName, cluster); err != nil {
+				return err
+			}
+			delete(cluster.Spec.Nodes, "node-one")
+			return testClient.Update(goctx.TODO(), cluster)
+		})
+		if err != nil {
+			t.Fatalf("Failed to remove node-one from spec: %v", err)
+		}
+		waitFor(t, "deletion of node-one", func() error {
+			for _, obj := range []runtime.Object{&appsv1.Deployment{}, &corev1.Service{}} {
+				if err := getObject("node-one", obj); !errors.IsNotFound(err) {
+					return fmt.Errorf("%T of node-one was not deleted: %v", obj, err)
+				}
+			}
+			cluster := &postgresqlv1.PostgreSQL{}
+			if err := getObject(postgreSQLCRName, cluster); err != nil {
+				return err
+			}
+			if _, ok := cluster.Status.Nodes["node-one"]; ok {
+				return fmt.Errorf("node-one is still present in status")
+			}
+			return nil
+		})
+	})
+}
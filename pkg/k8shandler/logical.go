@@ -0,0 +1,258 @@
+package k8shandler
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// reconcileLogicalReplication keeps publications and subscriptions of the
+// application database in sync with the spec, they are managed on the current
+// primary, so objects missing after a failover are recreated, only objects
+// recorded in the status are ever dropped
+func (request *PostgreSQLRequest) reconcileLogicalReplication(clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	spec := request.cluster.Spec
+	if len(spec.Publications) == 0 && len(spec.Subscriptions) == 0 &&
+		len(clusterStatus.Publications) == 0 && len(clusterStatus.Subscriptions) == 0 {
+		return nil
+	}
+	if primaryNode == nil || !primaryNode.isReady() || request.isReplica() {
+		return nil
+	}
+	dbname := newPgEnvironment().database
+	pubErr := request.reconcilePublications(dbname, clusterStatus)
+	subErr := request.reconcileSubscriptions(dbname, clusterStatus)
+	if pubErr != nil {
+		return pubErr
+	}
+	return subErr
+}
+
+// reconcilePublications creates, alters and drops publications of the database
+func (request *PostgreSQLRequest) reconcilePublications(dbname string, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	db := primaryNode.dbClient()
+	existing, err := db.publications(request.ctx, dbname)
+	if err != nil {
+		return fmt.Errorf("Failed to list publications: %v", err)
+	}
+	current := make(map[string]publication)
+	for _, pub := range existing {
+		current[pub.name] = pub
+	}
+
+	var failed error
+	owned := []string{}
+	desired := make(map[string]bool)
+	for _, spec := range request.cluster.Spec.Publications {
+		desired[spec.Name] = true
+		pub := newPublication(spec)
+		found, ok := current[pub.name]
+		switch {
+		case !ok:
+			err = db.createPublication(request.ctx, dbname, pub)
+			if err == nil {
+				request.recordNormal(PublicationCreated, "Publication %v created on %v", pub.name, primaryNode.name())
+			}
+		case found.allTables != pub.allTables:
+			// publication of all tables can't be altered to publish a list of tables and vice versa
+			if err = db.dropPublication(request.ctx, dbname, pub.name); err == nil {
+				err = db.createPublication(request.ctx, dbname, pub)
+			}
+		case !pub.allTables && !reflect.DeepEqual(found.tables, pub.tables):
+			logrus.Infof("Updating tables of publication %v: %v", pub.name, pub.tables)
+			err = db.alterPublication(request.ctx, dbname, pub)
+		}
+		if err != nil {
+			failed = fmt.Errorf("Failed to reconcile publication %v: %v", pub.name, err)
+			request.recordWarning(LogicalReplicationFailed, "Failed to reconcile publication %v: %v", pub.name, err)
+			err = nil
+			if !ok {
+				continue
+			}
+		}
+		owned = append(owned, pub.name)
+	}
+	for _, name := range clusterStatus.Publications {
+		if desired[name] {
+			continue
+		}
+		if _, ok := current[name]; ok {
+			if err := db.dropPublication(request.ctx, dbname, name); err != nil {
+				failed = fmt.Errorf("Failed to drop publication %v: %v", name, err)
+				owned = append(owned, name)
+				continue
+			}
+			request.recordNormal(PublicationDropped, "Publication %v dropped", name)
+		}
+	}
+	sort.Strings(owned)
+	clusterStatus.Publications = nil
+	if len(owned) > 0 {
+		clusterStatus.Publications = owned
+	}
+	return failed
+}
+
+// newPublication returns publication of the spec with tables qualified by schema
+func newPublication(spec postgresqlv1.PostgreSQLPublication) publication {
+	pub := publication{name: spec.Name, allTables: len(spec.Tables) == 0}
+	for _, table := range spec.Tables {
+		if !strings.Contains(table, ".") {
+			table = "public." + table
+		}
+		pub.tables = append(pub.tables, table)
+	}
+	sort.Strings(pub.tables)
+	return pub
+}
+
+// reconcileSubscriptions creates, alters and drops subscriptions of the
+// database and reports their state
+func (request *PostgreSQLRequest) reconcileSubscriptions(dbname string, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	db := primaryNode.dbClient()
+	existing, err := db.subscriptions(request.ctx, dbname)
+	if err != nil {
+		return fmt.Errorf("Failed to list subscriptions: %v", err)
+	}
+	current := make(map[string]subscription)
+	for _, sub := range existing {
+		current[sub.name] = sub
+	}
+
+	var failed error
+	statuses := make(map[string]postgresqlv1.PostgreSQLSubscriptionStatus)
+	for _, spec := range request.cluster.Spec.Subscriptions {
+		conninfo, err := request.subscriptionConnection(spec)
+		if err != nil {
+			failed = fmt.Errorf("Failed to get connection of subscription %v: %v", spec.Name, err)
+			request.recordWarning(LogicalReplicationFailed, "Failed to get connection of subscription %v: %v", spec.Name, err)
+			if found, ok := current[spec.Name]; ok {
+				statuses[spec.Name] = newSubscriptionStatus(found)
+			}
+			continue
+		}
+		sub := subscription{name: spec.Name, conninfo: conninfo, publications: spec.Publications}
+		found, ok := current[sub.name]
+		switch {
+		case !ok:
+			if err = db.createSubscription(request.ctx, dbname, sub); err == nil {
+				request.recordNormal(SubscriptionCreated, "Subscription %v created on %v", sub.name, primaryNode.name())
+				found = subscription{name: sub.name, enabled: true, lag: -1}
+				ok = true
+			}
+		case found.conninfo != sub.conninfo || !sameStrings(found.publications, sub.publications):
+			// the publisher changes its primary and passwords, the connection follows it
+			logrus.Infof("Updating connection and publications of subscription %v", sub.name)
+			err = db.alterSubscription(request.ctx, dbname, sub)
+		}
+		if err != nil {
+			failed = fmt.Errorf("Failed to reconcile subscription %v: %v", sub.name, err)
+			request.recordWarning(LogicalReplicationFailed, "Failed to reconcile subscription %v: %v", sub.name, err)
+		}
+		if ok {
+			statuses[sub.name] = newSubscriptionStatus(found)
+		}
+	}
+	for name := range clusterStatus.Subscriptions {
+		if _, ok := statuses[name]; ok || request.hasSubscription(name) {
+			continue
+		}
+		found, ok := current[name]
+		if !ok {
+			continue
+		}
+		if err := db.dropSubscription(request.ctx, dbname, name); err != nil {
+			failed = fmt.Errorf("Failed to drop subscription %v: %v", name, err)
+			statuses[name] = newSubscriptionStatus(found)
+			continue
+		}
+		request.recordNormal(SubscriptionDropped, "Subscription %v dropped", name)
+	}
+	clusterStatus.Subscriptions = nil
+	if len(statuses) > 0 {
+		clusterStatus.Subscriptions = statuses
+	}
+	return failed
+}
+
+// hasSubscription checks whether the subscription is in the spec
+func (request *PostgreSQLRequest) hasSubscription(name string) bool {
+	for _, spec := range request.cluster.Spec.Subscriptions {
+		if spec.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newSubscriptionStatus returns status of the subscription
+func newSubscriptionStatus(sub subscription) postgresqlv1.PostgreSQLSubscriptionStatus {
+	status := postgresqlv1.PostgreSQLSubscriptionStatus{State: postgresqlv1.SubscriptionStateDown}
+	switch {
+	case !sub.enabled:
+		status.State = postgresqlv1.SubscriptionStateDisabled
+	case sub.streaming:
+		status.State = postgresqlv1.SubscriptionStateStreaming
+	}
+	if sub.lag >= 0 {
+		status.Lag = &metav1.Duration{Duration: sub.lag}
+	}
+	return status
+}
+
+// subscriptionConnection returns connection string of the publisher read from
+// the secret, host, port and database of the primary of the publishing cluster
+// are prepended when the cluster is set. Credentials of the publishing cluster
+// are never used, the secret names a role allowed to replicate the publications.
+func (request *PostgreSQLRequest) subscriptionConnection(spec postgresqlv1.PostgreSQLSubscription) (string, error) {
+	namespace := request.cluster.Namespace
+	if spec.ConnectionSecret == nil {
+		return "", fmt.Errorf("connectionSecret is not set")
+	}
+	data, err := extractSecret(request.ctx, spec.ConnectionSecret.Name, namespace, request.client)
+	if err != nil {
+		return "", err
+	}
+	conninfo, ok := data[spec.ConnectionSecret.Key]
+	if !ok {
+		return "", fmt.Errorf("Key %v not found in secret %v", spec.ConnectionSecret.Key, spec.ConnectionSecret.Name)
+	}
+	if spec.Cluster == "" {
+		return string(conninfo), nil
+	}
+	source := &postgresqlv1.PostgreSQL{}
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: spec.Cluster, Namespace: namespace}, source); err != nil {
+		return "", fmt.Errorf("Failed to get cluster %v: %v", spec.Cluster, err)
+	}
+	host := ""
+	for _, node := range source.Status.Nodes {
+		if node.Role == postgresqlv1.PostgreSQLNodeRolePrimary {
+			host = node.ServiceName
+		}
+	}
+	if host == "" {
+		return "", fmt.Errorf("Cluster %v has no primary node", spec.Cluster)
+	}
+	// later parameters take precedence, so the secret may override the defaults
+	return fmt.Sprintf("host=%s port=%d dbname=%s %s",
+		host, postgresqlPort, newPgEnvironment().database, strings.TrimSpace(string(conninfo))), nil
+}
+
+// sameStrings compares lists of strings regardless of their order
+func sameStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string{}, a...)
+	b = append([]string{}, b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	return reflect.DeepEqual(a, b)
+}
@@ -0,0 +1,253 @@
+package k8shandler
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileLogicalReplication(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	publisherSecret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "publisher", Namespace: "default"},
+		Data:       map[string][]byte{"conninfo": []byte("host=publisher dbname=db")},
+	}
+	fromSecret := postgresqlv1.PostgreSQLSubscription{
+		Name:             "orders",
+		Publications:     []string{"orders"},
+		ConnectionSecret: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "publisher"}, Key: "conninfo"},
+	}
+
+	table := []struct {
+		name          string
+		publications  []postgresqlv1.PostgreSQLPublication
+		subscriptions []postgresqlv1.PostgreSQLSubscription
+		status        postgresqlv1.PostgreSQLStatus
+		objects       []runtime.Object
+		setup         func(repmgr *fakeRepmgr)
+		verify        func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error
+	}{
+		{
+			name: "publications created",
+			publications: []postgresqlv1.PostgreSQLPublication{
+				{Name: "orders", Tables: []string{"sales.items", "orders"}},
+				{Name: "all"},
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				expected := []string{"public.orders", "sales.items"}
+				if tables := repmgr.publications["orders"].tables; !reflect.DeepEqual(tables, expected) {
+					return fmt.Errorf("expected tables: '%v', got: '%v'", expected, tables)
+				}
+				if !repmgr.publications["all"].allTables {
+					return fmt.Errorf("expected publication of all tables")
+				}
+				if owned := request.cluster.Status.Publications; !reflect.DeepEqual(owned, []string{"all", "orders"}) {
+					return fmt.Errorf("expected publications: '[all orders]', got: '%v'", owned)
+				}
+				if !containsString(reasons, PublicationCreated) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", PublicationCreated, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:         "publication altered",
+			publications: []postgresqlv1.PostgreSQLPublication{{Name: "orders", Tables: []string{"orders", "customers"}}},
+			status:       postgresqlv1.PostgreSQLStatus{Publications: []string{"orders"}},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.publications["orders"] = publication{name: "orders", tables: []string{"public.orders"}}
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				expected := []string{"public.customers", "public.orders"}
+				if tables := repmgr.publications["orders"].tables; !reflect.DeepEqual(tables, expected) {
+					return fmt.Errorf("expected tables: '%v', got: '%v'", expected, tables)
+				}
+				return nil
+			},
+		},
+		{
+			name:   "publication removed from the spec dropped",
+			status: postgresqlv1.PostgreSQLStatus{Publications: []string{"old"}},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.publications["old"] = publication{name: "old", allTables: true}
+				repmgr.publications["manual"] = publication{name: "manual", allTables: true}
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if _, ok := repmgr.publications["old"]; ok {
+					return fmt.Errorf("publication 'old' was not dropped")
+				}
+				if _, ok := repmgr.publications["manual"]; !ok {
+					return fmt.Errorf("publication not created by the operator was dropped")
+				}
+				if owned := request.cluster.Status.Publications; owned != nil {
+					return fmt.Errorf("expected no publications in status, got: '%v'", owned)
+				}
+				if !containsString(reasons, PublicationDropped) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", PublicationDropped, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:          "subscription to connection from secret created",
+			subscriptions: []postgresqlv1.PostgreSQLSubscription{fromSecret},
+			objects:       []runtime.Object{publisherSecret},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				sub, ok := repmgr.subscriptions["orders"]
+				if !ok {
+					return fmt.Errorf("subscription was not created")
+				}
+				if sub.conninfo != "host=publisher dbname=db" {
+					return fmt.Errorf("expected conninfo: 'host=publisher dbname=db', got: '%v'", sub.conninfo)
+				}
+				if _, ok := request.cluster.Status.Subscriptions["orders"]; !ok {
+					return fmt.Errorf("expected subscription in status")
+				}
+				if !containsString(reasons, SubscriptionCreated) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", SubscriptionCreated, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name: "subscription to cluster created",
+			subscriptions: []postgresqlv1.PostgreSQLSubscription{{
+				Name:             "orders",
+				Publications:     []string{"orders"},
+				Cluster:          "source",
+				ConnectionSecret: &corev1.SecretKeySelector{LocalObjectReference: corev1.LocalObjectReference{Name: "replicator"}, Key: "conninfo"},
+			}},
+			objects: []runtime.Object{
+				&postgresqlv1.PostgreSQL{
+					ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"},
+					Status: postgresqlv1.PostgreSQLStatus{Nodes: map[string]postgresqlv1.PostgreSQLNodeStatus{
+						"source-one": {ServiceName: "source-one", Role: postgresqlv1.PostgreSQLNodeRoleStandby},
+						"source-two": {ServiceName: "source-two", Role: postgresqlv1.PostgreSQLNodeRolePrimary},
+					}},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "source", Namespace: "default"},
+					Data:       map[string][]byte{defaultRepmgrPasswordKey: []byte("source-secret")},
+				},
+				&corev1.Secret{
+					ObjectMeta: metav1.ObjectMeta{Name: "replicator", Namespace: "default"},
+					Data:       map[string][]byte{"conninfo": []byte("user=replicator password='replicator-secret' sslmode=require")},
+				},
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				conninfo := repmgr.subscriptions["orders"].conninfo
+				for _, expected := range []string{"host=source-two ", "user=replicator ", "password='replicator-secret' ", "sslmode=require"} {
+					if !strings.Contains(conninfo, expected) {
+						return fmt.Errorf("expected conninfo with '%v', got: '%v'", expected, conninfo)
+					}
+				}
+				for _, unexpected := range []string{"repmgr", "source-secret", "sslmode=disable"} {
+					if strings.Contains(conninfo, unexpected) {
+						return fmt.Errorf("unexpected '%v' in conninfo: '%v'", unexpected, conninfo)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			name:          "subscription to cluster without connection secret rejected",
+			subscriptions: []postgresqlv1.PostgreSQLSubscription{{Name: "orders", Publications: []string{"orders"}, Cluster: "source"}},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if _, ok := repmgr.subscriptions["orders"]; ok {
+					return fmt.Errorf("subscription was created without connection secret")
+				}
+				if !containsString(reasons, LogicalReplicationFailed) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", LogicalReplicationFailed, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:          "subscription altered and its state reported",
+			subscriptions: []postgresqlv1.PostgreSQLSubscription{fromSecret},
+			status: postgresqlv1.PostgreSQLStatus{Subscriptions: map[string]postgresqlv1.PostgreSQLSubscriptionStatus{
+				"orders": {State: postgresqlv1.SubscriptionStateStreaming},
+			}},
+			objects: []runtime.Object{publisherSecret},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.subscriptions["orders"] = subscription{name: "orders", conninfo: "host=old-publisher dbname=db", publications: []string{"orders"}, enabled: true}
+				repmgr.SetSubscriptionState("orders", false, 3*time.Second)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if conninfo := repmgr.subscriptions["orders"].conninfo; conninfo != "host=publisher dbname=db" {
+					return fmt.Errorf("expected conninfo: 'host=publisher dbname=db', got: '%v'", conninfo)
+				}
+				status := request.cluster.Status.Subscriptions["orders"]
+				if status.State != postgresqlv1.SubscriptionStateDown {
+					return fmt.Errorf("expected state: '%v', got: '%v'", postgresqlv1.SubscriptionStateDown, status.State)
+				}
+				if status.Lag == nil || status.Lag.Duration != 3*time.Second {
+					return fmt.Errorf("expected lag: '3s', got: '%v'", status.Lag)
+				}
+				return nil
+			},
+		},
+		{
+			name: "subscription removed from the spec dropped",
+			status: postgresqlv1.PostgreSQLStatus{Subscriptions: map[string]postgresqlv1.PostgreSQLSubscriptionStatus{
+				"old": {State: postgresqlv1.SubscriptionStateStreaming},
+			}},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.subscriptions["old"] = subscription{name: "old", enabled: true, streaming: true}
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if _, ok := repmgr.subscriptions["old"]; ok {
+					return fmt.Errorf("subscription 'old' was not dropped")
+				}
+				if subs := request.cluster.Status.Subscriptions; subs != nil {
+					return fmt.Errorf("expected no subscriptions in status, got: '%v'", subs)
+				}
+				if !containsString(reasons, SubscriptionDropped) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", SubscriptionDropped, reasons)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		if tt.setup != nil {
+			tt.setup(repmgr)
+		}
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, map[string]int{"node-one": 1, "node-two": 2})
+		for _, obj := range tt.objects {
+			if err := request.client.Create(request.ctx, obj.DeepCopyObject()); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		request.cluster.Spec.Publications = tt.publications
+		request.cluster.Spec.Subscriptions = tt.subscriptions
+		request.cluster.Status.Publications = tt.status.Publications
+		request.cluster.Status.Subscriptions = tt.status.Subscriptions
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.verify(request, repmgr, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,246 @@
+package k8shandler
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	metricsNamespace = "postgresql_operator"
+
+	phaseSecret   = "secret"
+	phaseServices = "services"
+	phaseNodes    = "nodes"
+	phaseStatus   = "status"
+)
+
+var (
+	failoversTotal = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "failovers_total",
+			Help:      "Number of failovers detected per cluster.",
+		},
+		[]string{"namespace", "cluster"},
+	)
+	reconcileDuration = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_duration_seconds",
+			Help:      "Duration of individual reconcile phases.",
+			Buckets:   prometheus.DefBuckets,
+		},
+		[]string{"namespace", "cluster", "phase"},
+	)
+	reconcileErrors = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "reconcile_errors_total",
+			Help:      "Number of failed reconcile phases.",
+		},
+		[]string{"namespace", "cluster", "phase"},
+	)
+	clusterNodes = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "nodes",
+			Help:      "Number of cluster nodes by role and readiness.",
+		},
+		[]string{"namespace", "cluster", "role", "ready"},
+	)
+	repmgrConnectionFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "repmgr_connection_failures_total",
+			Help:      "Number of failed connections or queries to repmgr database.",
+		},
+		[]string{"namespace", "cluster", "node"},
+	)
+	replicationSlotRetained = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "replication_slot_retained_bytes",
+			Help:      "Amount of WAL retained on the primary by replication slots of standbys.",
+		},
+		[]string{"namespace", "cluster", "slot"},
+	)
+	statusUpdates = newStatusAgeCollector()
+	clusterSeries = newSeriesTracker()
+)
+
+func init() {
+	metrics.Registry.MustRegister(
+		failoversTotal,
+		reconcileDuration,
+		reconcileErrors,
+		clusterNodes,
+		repmgrConnectionFailures,
+		replicationSlotRetained,
+		statusUpdates,
+	)
+}
+
+// observePhase records duration and outcome of a single reconcile phase
+func (request *PostgreSQLRequest) observePhase(phase string, start time.Time, err error) {
+	reconcileDuration.WithLabelValues(request.seriesLabels(reconcileDuration, phase)...).Observe(time.Since(start).Seconds())
+	if err != nil {
+		reconcileErrors.WithLabelValues(request.seriesLabels(reconcileErrors, phase)...).Inc()
+	}
+}
+
+// observeNodes sets node gauges of the cluster according to the node statuses
+func (request *PostgreSQLRequest) observeNodes(statuses map[string]postgresqlv1.PostgreSQLNodeStatus) {
+	counts := make(map[postgresqlv1.PostgreSQLNodeRole]map[bool]int)
+	for _, role := range []postgresqlv1.PostgreSQLNodeRole{
+		postgresqlv1.PostgreSQLNodeRolePrimary,
+		postgresqlv1.PostgreSQLNodeRoleStandby,
+		postgresqlv1.PostgreSQLNodeRoleUnknown,
+	} {
+		counts[role] = map[bool]int{true: 0, false: 0}
+	}
+	for name, node := range nodes {
+		role := statuses[name].Role
+		if _, ok := counts[role]; !ok {
+			role = postgresqlv1.PostgreSQLNodeRoleUnknown
+		}
+		counts[role][node.isReady()]++
+	}
+	for role, byReadiness := range counts {
+		for ready, count := range byReadiness {
+			clusterNodes.WithLabelValues(request.seriesLabels(clusterNodes, string(role), boolLabel(ready))...).Set(float64(count))
+		}
+	}
+}
+
+// seriesLabels returns label values of the series of the cluster, the series
+// is remembered, so it's deleted together with the cluster
+func (request *PostgreSQLRequest) seriesLabels(vec labelsDeleter, labels ...string) []string {
+	return clusterSeries.add(vec, request.cluster.Namespace, request.cluster.Name, labels...)
+}
+
+// deleteSeries deletes the series of the cluster
+func (request *PostgreSQLRequest) deleteSeries(vec labelsDeleter, labels ...string) {
+	clusterSeries.delete(vec, request.cluster.Namespace, request.cluster.Name, labels...)
+}
+
+// DeleteMetrics deletes all series of the removed cluster
+func DeleteMetrics(namespace, cluster string) {
+	clusterSeries.deleteCluster(namespace, cluster)
+	statusUpdates.forget(namespace, cluster)
+}
+
+func boolLabel(value bool) string {
+	if value {
+		return "true"
+	}
+	return "false"
+}
+
+// labelsDeleter is a metric vector of series with labels
+type labelsDeleter interface {
+	DeleteLabelValues(lvs ...string) bool
+}
+
+// seriesTracker remembers label values of series of every cluster, metrics
+// vectors can't delete series matching only some of their labels
+type seriesTracker struct {
+	mutex  sync.Mutex
+	series map[types.NamespacedName]map[labelsDeleter]map[string][]string
+}
+
+func newSeriesTracker() *seriesTracker {
+	return &seriesTracker{series: make(map[types.NamespacedName]map[labelsDeleter]map[string][]string)}
+}
+
+// add remembers the series and returns its label values
+func (t *seriesTracker) add(vec labelsDeleter, namespace, cluster string, labels ...string) []string {
+	values := append([]string{namespace, cluster}, labels...)
+	key := types.NamespacedName{Namespace: namespace, Name: cluster}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if t.series[key] == nil {
+		t.series[key] = make(map[labelsDeleter]map[string][]string)
+	}
+	if t.series[key][vec] == nil {
+		t.series[key][vec] = make(map[string][]string)
+	}
+	t.series[key][vec][strings.Join(values, "\x00")] = values
+	return values
+}
+
+// delete deletes the series and forgets it
+func (t *seriesTracker) delete(vec labelsDeleter, namespace, cluster string, labels ...string) {
+	values := append([]string{namespace, cluster}, labels...)
+	vec.DeleteLabelValues(values...)
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if byVec, ok := t.series[types.NamespacedName{Namespace: namespace, Name: cluster}]; ok {
+		delete(byVec[vec], strings.Join(values, "\x00"))
+	}
+}
+
+// deleteCluster deletes all series of the cluster
+func (t *seriesTracker) deleteCluster(namespace, cluster string) {
+	key := types.NamespacedName{Namespace: namespace, Name: cluster}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	for vec, series := range t.series[key] {
+		for _, values := range series {
+			vec.DeleteLabelValues(values...)
+		}
+	}
+	delete(t.series, key)
+}
+
+// statusAgeCollector exposes time elapsed since the last successful status
+// update of every cluster, computed at scrape time
+type statusAgeCollector struct {
+	desc    *prometheus.Desc
+	mutex   sync.Mutex
+	updates map[types.NamespacedName]time.Time
+}
+
+func newStatusAgeCollector() *statusAgeCollector {
+	return &statusAgeCollector{
+		desc: prometheus.NewDesc(
+			prometheus.BuildFQName(metricsNamespace, "", "seconds_since_status_update"),
+			"Time elapsed since the last successful status update of the cluster.",
+			[]string{"namespace", "cluster"},
+			nil,
+		),
+		updates: make(map[types.NamespacedName]time.Time),
+	}
+}
+
+// succeeded records successful status update of the cluster
+func (c *statusAgeCollector) succeeded(namespace, cluster string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.updates[types.NamespacedName{Namespace: namespace, Name: cluster}] = time.Now()
+}
+
+// forget stops reporting the removed cluster
+func (c *statusAgeCollector) forget(namespace, cluster string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.updates, types.NamespacedName{Namespace: namespace, Name: cluster})
+}
+
+func (c *statusAgeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *statusAgeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	for key, updated := range c.updates {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, time.Since(updated).Seconds(), key.Namespace, key.Name)
+	}
+}
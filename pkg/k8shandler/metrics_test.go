@@ -0,0 +1,69 @@
+package k8shandler
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// collectedSeries returns number of series exposed by the collector
+func collectedSeries(collector prometheus.Collector) int {
+	ch := make(chan prometheus.Metric, 100)
+	collector.Collect(ch)
+	close(ch)
+	return len(ch)
+}
+
+func TestClusterMetricsByNamespace(t *testing.T) {
+	request, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	other, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	other.cluster.Namespace = "other"
+	defer DeleteMetrics("default", "example")
+	defer DeleteMetrics("other", "example")
+
+	failoversTotal.WithLabelValues(request.seriesLabels(failoversTotal)...).Inc()
+	failoversTotal.WithLabelValues(other.seriesLabels(failoversTotal)...).Inc()
+	before := collectedSeries(failoversTotal)
+	DeleteMetrics("other", "example")
+	if after := collectedSeries(failoversTotal); after != before-1 {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", before-1, after)
+	}
+	if !failoversTotal.DeleteLabelValues("default", "example") {
+		t.Errorf("Test failed, series of cluster in namespace default was deleted")
+	}
+}
+
+func TestDeleteMetrics(t *testing.T) {
+	request, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	request.cluster.Namespace = "removed"
+	request.observePhase(phaseNodes, time.Now(), nil)
+	request.observeNodes(nil)
+	replicationSlotRetained.WithLabelValues(request.seriesLabels(replicationSlotRetained, "node_one")...).Set(1024)
+	repmgrConnectionFailures.WithLabelValues(request.seriesLabels(repmgrConnectionFailures, "node-one")...).Inc()
+	statusUpdates.succeeded("removed", "example")
+
+	table := []struct {
+		vec    labelsDeleter
+		labels []string
+	}{
+		{reconcileDuration, []string{"removed", "example", phaseNodes}},
+		{clusterNodes, []string{"removed", "example", "primary", "true"}},
+		{replicationSlotRetained, []string{"removed", "example", "node_one"}},
+		{repmgrConnectionFailures, []string{"removed", "example", "node-one"}},
+	}
+	statuses := collectedSeries(statusUpdates)
+	DeleteMetrics("removed", "example")
+	for _, tt := range table {
+		if tt.vec.DeleteLabelValues(tt.labels...) {
+			t.Errorf("Test %v failed, series was not deleted", tt.labels)
+		}
+	}
+	if after := collectedSeries(statusUpdates); after != statuses-1 {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", statuses-1, after)
+	}
+	if _, ok := clusterSeries.series[types.NamespacedName{Namespace: "removed", Name: "example"}]; ok {
+		t.Errorf("Test failed, series of removed cluster are still tracked")
+	}
+}
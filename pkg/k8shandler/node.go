@@ -1,6 +1,8 @@
 package k8shandler
 
 import (
+	"context"
+
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 )
 
@@ -8,10 +10,19 @@ import (
 type Node interface {
 	name() string
 	create(request *PostgreSQLRequest) error
-	update(request *PostgreSQLRequest, specNode *postgresqlv1.PostgreSQLNode, writableDB *database) (bool, error)
+	update(request *PostgreSQLRequest, specNode *postgresqlv1.PostgreSQLNode, writableDB sqlBackend) (bool, error)
 	delete(request *PostgreSQLRequest) error
-	status() postgresqlv1.PostgreSQLNodeStatus
-	dbClient() *database
+	status(ctx context.Context) postgresqlv1.PostgreSQLNodeStatus
+	dbClient() sqlBackend
+	connect(request *PostgreSQLRequest) error
+	updatePassword(request *PostgreSQLRequest, password string) error
 	isRegistered(request *PostgreSQLRequest) (bool, error)
 	isReady() bool
+	fence(request *PostgreSQLRequest, writableDB sqlBackend) error
+	unfence(request *PostgreSQLRequest) error
+	isFenced() bool
+	rejoinState() (string, *postgresqlv1.PostgreSQLRejoinStatus)
+	reinitialize(request *PostgreSQLRequest, writableDB sqlBackend) error
+	startupOperation() string
+	switchOperation(request *PostgreSQLRequest, operation string) error
 }
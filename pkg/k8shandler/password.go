@@ -12,6 +12,8 @@ type pgPasswords struct {
 	database string
 }
 
+// newPgPasswords returns passwords of a new cluster, the password of the
+// database user may be set by the operator environment
 func newPgPasswords() (*pgPasswords, error) {
 	repmgr, err := generatePassword()
 	if err != nil {
@@ -30,6 +32,23 @@ func newPgPasswords() (*pgPasswords, error) {
 	}, nil
 }
 
+// generatePgPasswords returns new random passwords of both roles, used by
+// rotation, which must never keep the previous password
+func generatePgPasswords() (*pgPasswords, error) {
+	repmgr, err := generatePassword()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate password for repmgr user: %v", err)
+	}
+	database, err := generatePassword()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to generate password for database user: %v", err)
+	}
+	return &pgPasswords{
+		repmgr:   repmgr,
+		database: database,
+	}, nil
+}
+
 // generatePassword generates high-entropy random password, 32 characters long, 5 digits, 5 symbols
 // including upper and lower case letters
 func generatePassword() (string, error) {
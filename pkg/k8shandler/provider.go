@@ -0,0 +1,101 @@
+package k8shandler
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// errPasswordsNotFound is returned by a provider which doesn't store passwords of the cluster yet
+var errPasswordsNotFound = errors.New("Passwords not found")
+
+// credentialProvider stores passwords generated by the operator
+type credentialProvider interface {
+	// read returns stored passwords of the cluster or errPasswordsNotFound
+	read(request *PostgreSQLRequest) (*pgPasswords, error)
+	// write stores passwords of the cluster
+	write(request *PostgreSQLRequest, passwords *pgPasswords) error
+}
+
+// newCredentialProvider returns provider configured in the spec of the cluster
+func (request *PostgreSQLRequest) newCredentialProvider() (credentialProvider, error) {
+	spec := request.cluster.Spec.Credentials
+	if spec == nil {
+		return &secretProvider{}, nil
+	}
+	switch spec.Provider {
+	case "", postgresqlv1.CredentialsProviderSecret:
+		return &secretProvider{}, nil
+	case postgresqlv1.CredentialsProviderVault:
+		return newVaultProvider(request.cluster)
+	}
+	return nil, fmt.Errorf("Unknown credentials provider %v", spec.Provider)
+}
+
+// storePasswords writes passwords to the provider and keeps the secret consumed
+// by pods of the cluster in sync
+func (request *PostgreSQLRequest) storePasswords(provider credentialProvider, passwords *pgPasswords) error {
+	if err := provider.write(request, passwords); err != nil {
+		return fmt.Errorf("Failed to store passwords of cluster %v: %v", request.cluster.Name, err)
+	}
+	if _, ok := provider.(*secretProvider); ok {
+		return nil
+	}
+	return writeSecret(request, passwords)
+}
+
+// secretProvider keeps passwords in the secret named after the cluster
+type secretProvider struct{}
+
+func (p *secretProvider) read(request *PostgreSQLRequest) (*pgPasswords, error) {
+	ref := request.credentialsSecret()
+	secretData, err := extractSecret(request.ctx, ref.name, request.cluster.Namespace, request.client)
+	if apierrors.IsNotFound(err) {
+		return nil, errPasswordsNotFound
+	} else if err != nil {
+		return nil, err
+	}
+	return &pgPasswords{
+		database: string(secretData[ref.databaseKey]),
+		repmgr:   string(secretData[ref.repmgrKey]),
+	}, nil
+}
+
+func (p *secretProvider) write(request *PostgreSQLRequest, passwords *pgPasswords) error {
+	return writeSecret(request, passwords)
+}
+
+// writeSecret creates the secret named after the cluster or updates passwords
+// stored in it when they differ
+func writeSecret(request *PostgreSQLRequest, passwords *pgPasswords) error {
+	ref := request.credentialsSecret()
+	secret := newSecret(request, ref.name, passwords)
+	err := request.client.Create(request.ctx, secret)
+	if err == nil || !apierrors.IsAlreadyExists(err) {
+		return err
+	}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		current := &corev1.Secret{}
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, current); err != nil {
+			return err
+		}
+		if string(current.Data[ref.databaseKey]) == passwords.database &&
+			string(current.Data[ref.repmgrKey]) == passwords.repmgr {
+			return nil
+		}
+		if current.Data == nil {
+			current.Data = make(map[string][]byte)
+		}
+		current.Data[ref.databaseKey] = []byte(passwords.database)
+		current.Data[ref.repmgrKey] = []byte(passwords.repmgr)
+		logrus.Infof("Updating passwords in secret %v", secret.Name)
+		return request.client.Update(request.ctx, current)
+	})
+}
@@ -1,22 +1,37 @@
 package k8shandler
 
 import (
+	"context"
+	"time"
+
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	"github.com/sirupsen/logrus"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	client "sigs.k8s.io/controller-runtime/pkg/client"
 )
 
 // PostgreSQLRequest encapsulates variables needed for request handling
 type PostgreSQLRequest struct {
-	client  client.Client
-	cluster *postgresqlv1.PostgreSQL
-	scheme  *runtime.Scheme
+	ctx      context.Context
+	client   client.Client
+	cluster  *postgresqlv1.PostgreSQL
+	scheme   *runtime.Scheme
+	recorder record.EventRecorder
+	executor PodExecutor
 }
 
 // NewPostgreSQLRequest constructs a PostgreSQLRequest
-func NewPostgreSQLRequest(client client.Client, cluster *postgresqlv1.PostgreSQL, scheme *runtime.Scheme) *PostgreSQLRequest {
-	return &PostgreSQLRequest{client: client, cluster: cluster, scheme: scheme}
+func NewPostgreSQLRequest(ctx context.Context, client client.Client, cluster *postgresqlv1.PostgreSQL, scheme *runtime.Scheme, recorder record.EventRecorder, executor PodExecutor) *PostgreSQLRequest {
+	return &PostgreSQLRequest{ctx: ctx, client: client, cluster: cluster, scheme: scheme, recorder: recorder, executor: executor}
+}
+
+// template returns template of the cluster, empty if the cluster doesn't set it
+func (request *PostgreSQLRequest) template() *postgresqlv1.PostgreSQLNodeTemplate {
+	if request.cluster.Spec.Template == nil {
+		return &postgresqlv1.PostgreSQLNodeTemplate{}
+	}
+	return request.cluster.Spec.Template
 }
 
 // Reconcile creates or updates all the resources managed by the operator
@@ -24,15 +39,33 @@ func (request *PostgreSQLRequest) Reconcile() (bool, error) {
 	var err error
 	requeue := false
 	logrus.Info("Reconciling PostgreSQL")
+	defer request.loadClusterState()()
+
+	if request.cluster.Spec.ManagementState == postgresqlv1.ManagementStateHibernated {
+		logrus.Info("Running hibernation of cluster")
+		return request.Hibernate()
+	}
+	if request.cluster.Status.Hibernation != nil {
+		logrus.Info("Running resume of hibernated cluster")
+		if requeue, err := request.resume(); requeue || err != nil {
+			return true, err
+		}
+	}
 
 	logrus.Info("Running create or update for secret")
-	if err := request.CreateOrUpdateSecret(); err != nil {
+	start := time.Now()
+	err = request.CreateOrUpdateSecret()
+	request.observePhase(phaseSecret, start, err)
+	if err != nil {
 		logrus.Errorf("Failed to create or update secret: %v", err)
 		requeue = true
 	}
 
 	logrus.Info("Running create or update for read-only service")
-	if err := request.CreateOrUpdateService("postgresql-ro", ""); err != nil {
+	start = time.Now()
+	err = request.CreateOrUpdateService("postgresql-ro", "")
+	request.observePhase(phaseServices, start, err)
+	if err != nil {
 		logrus.Errorf("Failed to create or update read-only service: %v", err)
 		requeue = true
 	}
@@ -46,5 +79,11 @@ func (request *PostgreSQLRequest) Reconcile() (bool, error) {
 		logrus.Info("Request requeued after create or update of cluster")
 		return true, err
 	}
+
+	logrus.Info("Running create or update for credentials")
+	if err := request.CreateOrUpdateCredentials(); err != nil {
+		logrus.Errorf("Failed to create or update credentials: %v", err)
+		return true, nil
+	}
 	return false, nil
 }
@@ -0,0 +1,91 @@
+package k8shandler
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// ReinitializeAnnotation lists comma separated names of standby nodes to be re-cloned
+	ReinitializeAnnotation = "postgresql.openshift.io/reinitialize"
+
+	// NodeReinitialized is emitted when re-clone of a node starts
+	NodeReinitialized = "NodeReinitialized"
+	// ReinitializeRejected is emitted when re-clone of a node is not allowed
+	ReinitializeRejected = "ReinitializeRejected"
+)
+
+// parseNodeList splits comma separated list of node names
+func parseNodeList(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// reinitializeNodes re-clones standby nodes listed in the reinitialize annotation,
+// handled nodes are removed from the annotation
+func (request *PostgreSQLRequest) reinitializeNodes() error {
+	value, ok := request.cluster.Annotations[ReinitializeAnnotation]
+	if !ok {
+		return nil
+	}
+	var pending []string
+	for _, name := range parseNodeList(value) {
+		node, ok := nodes[name]
+		switch {
+		case !ok:
+			request.recordWarning(ReinitializeRejected, "Node %v can't be reinitialized, it's not present in the cluster", name)
+		case primaryNode == nil:
+			pending = append(pending, name)
+		case name == primaryNode.name():
+			request.recordWarning(ReinitializeRejected, "Node %v is the current primary and can't be reinitialized", name)
+		default:
+			standby, err := isConfirmedStandby(request.ctx, node, primaryNode.dbClient())
+			if err != nil {
+				logrus.Errorf("Failed to check role of node %v: %v", name, err)
+				pending = append(pending, name)
+				continue
+			}
+			if !standby {
+				request.recordWarning(ReinitializeRejected, "Node %v is not a standby and can't be reinitialized", name)
+				continue
+			}
+			if err := node.reinitialize(request, primaryNode.dbClient()); err != nil {
+				logrus.Errorf("Failed to reinitialize node %v: %v", name, err)
+				pending = append(pending, name)
+				continue
+			}
+			request.recordNormal(NodeReinitialized, "Node %v is being reinitialized from primary %v", name, primaryNode.name())
+		}
+	}
+	return request.updateAnnotation(ReinitializeAnnotation, strings.Join(pending, ","))
+}
+
+// isConfirmedStandby checks that repmgr records the node as a standby and that
+// the node runs in recovery if it's reachable, the current primary may not be
+// known yet, as failover is detected only after the nodes are reinitialized
+func isConfirmedStandby(ctx context.Context, node Node, writableDB sqlBackend) (bool, error) {
+	info, err := writableDB.getNodeInfo(ctx, node.name())
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if info.role != postgresqlv1.PostgreSQLNodeRoleStandby {
+		return false, nil
+	}
+	if !node.isReady() {
+		return true, nil
+	}
+	return node.dbClient().isInRecovery(ctx)
+}
@@ -0,0 +1,71 @@
+package k8shandler
+
+import (
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func TestParseNodeList(t *testing.T) {
+	table := []struct {
+		value    string
+		expected []string
+	}{
+		{"", nil},
+		{"node-two", []string{"node-two"}},
+		{"node-two, node-three,", []string{"node-two", "node-three"}},
+	}
+	for _, tt := range table {
+		actual := parseNodeList(tt.value)
+		if !reflect.DeepEqual(actual, tt.expected) {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestReinitializeNodes(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	table := []struct {
+		name   string
+		change func(repmgr *fakeRepmgr)
+		// wiped reports whether deployment of node-two is expected to be deleted
+		wiped  bool
+		reason string
+	}{
+		{
+			name:   "standby reinitialized",
+			change: func(repmgr *fakeRepmgr) {},
+			wiped:  true,
+			reason: NodeReinitialized,
+		},
+		{
+			name:   "node promoted behind the operator rejected",
+			change: func(repmgr *fakeRepmgr) { repmgr.Promote("node-two") },
+			reason: ReinitializeRejected,
+		},
+	}
+	for _, tt := range table {
+		request, repmgr, recorder := newSplitBrainTest(t)
+		tt.change(repmgr)
+		if err := request.updateAnnotation(ReinitializeAnnotation, "node-two"); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if err := request.reinitializeNodes(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-two", Namespace: "default"}, &appsv1.Deployment{})
+		if wiped := errors.IsNotFound(err); wiped != tt.wiped {
+			t.Errorf("Test %v failed, expected wiped: '%v', got: '%v'", tt.name, tt.wiped, err)
+		}
+		if reasons := recordedReasons(recorder); !containsString(reasons, tt.reason) {
+			t.Errorf("Test %v failed, expected event: '%v', got: '%v'", tt.name, tt.reason, reasons)
+		}
+		if value, ok := request.cluster.Annotations[ReinitializeAnnotation]; ok {
+			t.Errorf("Test %v failed, expected handled annotation, got: '%v'", tt.name, value)
+		}
+	}
+}
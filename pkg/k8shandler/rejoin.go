@@ -0,0 +1,138 @@
+package k8shandler
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// RejoinStrategyRejoin rejoins the node by replaying WAL of the primary
+	RejoinStrategyRejoin = "rejoin"
+	// RejoinStrategyRewind rewinds the diverged data directory using pg_rewind
+	RejoinStrategyRewind = "rewind"
+	// RejoinStrategyClone wipes the data volume and clones a fresh copy from the primary
+	RejoinStrategyClone = "clone"
+
+	// RejoinEscalated is emitted when a rejoin attempt failed and a stronger strategy is used
+	RejoinEscalated = "RejoinEscalated"
+
+	// rejoinTimeout is time given to the node to become ready before the next strategy is tried
+	rejoinTimeout = 5 * time.Minute
+)
+
+// nextRejoinStrategy returns the strategy to be used when the previous one
+// failed, empty string is returned if there is nothing else to try
+func nextRejoinStrategy(strategy string) string {
+	switch strategy {
+	case RejoinStrategyRejoin:
+		return RejoinStrategyRewind
+	case RejoinStrategyRewind:
+		return RejoinStrategyClone
+	default:
+		return ""
+	}
+}
+
+// recordRejoinState keeps state of the rejoins in status of the nodes, so the
+// escalation continues where it stopped once the operator is restarted
+func recordRejoinState(clusterStatus *postgresqlv1.PostgreSQLStatus) {
+	for name, node := range nodes {
+		status, ok := clusterStatus.Nodes[name]
+		if !ok {
+			continue
+		}
+		status.RejoinStrategy, status.Rejoin = node.rejoinState()
+		clusterStatus.Nodes[name] = status
+	}
+}
+
+// chooseRejoinStrategy picks pg_rewind for nodes which history diverged
+// from the primary, plain rejoin otherwise
+func chooseRejoinStrategy(ctx context.Context, nodeDB, primaryDB sqlBackend) string {
+	diverged, err := isDiverged(ctx, nodeDB, primaryDB)
+	if err != nil {
+		logrus.Errorf("Failed to detect timeline divergence: %v", err)
+		return RejoinStrategyRejoin
+	}
+	if diverged {
+		return RejoinStrategyRewind
+	}
+	return RejoinStrategyRejoin
+}
+
+// isDiverged checks whether the node wrote WAL past the point at which
+// the primary switched to its timeline
+func isDiverged(ctx context.Context, nodeDB, primaryDB sqlBackend) (bool, error) {
+	nodeTimeline, err := nodeDB.timeline(ctx)
+	if err != nil {
+		return false, err
+	}
+	nodeLSN, err := nodeDB.currentLSN(ctx)
+	if err != nil {
+		return false, err
+	}
+	primaryTimeline, err := primaryDB.timeline(ctx)
+	if err != nil {
+		return false, err
+	}
+	if nodeTimeline >= primaryTimeline {
+		// both nodes were promoted independently, histories can't match
+		return true, nil
+	}
+	history, err := primaryDB.timelineHistory(ctx, primaryTimeline)
+	if err != nil {
+		return false, err
+	}
+	switchpoint, err := timelineSwitchpoint(history, nodeTimeline)
+	if err != nil {
+		return false, err
+	}
+	position, err := parseLSN(nodeLSN)
+	if err != nil {
+		return false, err
+	}
+	return position > switchpoint, nil
+}
+
+// timelineSwitchpoint finds the location at which the timeline ended in
+// the content of a timeline history file
+func timelineSwitchpoint(history string, timeline int) (uint64, error) {
+	for _, line := range strings.Split(history, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+		parent, err := strconv.Atoi(fields[0])
+		if err != nil {
+			continue
+		}
+		if parent == timeline {
+			return parseLSN(fields[1])
+		}
+	}
+	return 0, fmt.Errorf("Timeline %v not found in history", timeline)
+}
+
+// parseLSN converts textual representation of WAL location to a number
+func parseLSN(lsn string) (uint64, error) {
+	parts := strings.Split(lsn, "/")
+	if len(parts) != 2 {
+		return 0, fmt.Errorf("Invalid LSN %q", lsn)
+	}
+	high, err := strconv.ParseUint(parts[0], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid LSN %q: %v", lsn, err)
+	}
+	low, err := strconv.ParseUint(parts[1], 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid LSN %q: %v", lsn, err)
+	}
+	return high<<32 | low, nil
+}
@@ -0,0 +1,216 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestParseLSN(t *testing.T) {
+	table := []struct {
+		lsn      string
+		expected uint64
+		valid    bool
+	}{
+		{"0/3000060", 0x3000060, true},
+		{"1/0", 1 << 32, true},
+		{"A/FF", 0xA<<32 | 0xFF, true},
+		{"", 0, false},
+		{"0/XYZ", 0, false},
+	}
+	for _, tt := range table {
+		actual, err := parseLSN(tt.lsn)
+		if (err == nil) != tt.valid {
+			t.Errorf("Test failed, unexpected error for '%v': %v", tt.lsn, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestTimelineSwitchpoint(t *testing.T) {
+	history := "1\t0/3000060\tno recovery target specified\n\n2\t0/5000000\tno recovery target specified\n"
+	table := []struct {
+		timeline int
+		expected uint64
+		valid    bool
+	}{
+		{1, 0x3000060, true},
+		{2, 0x5000000, true},
+		{3, 0, false},
+	}
+	for _, tt := range table {
+		actual, err := timelineSwitchpoint(history, tt.timeline)
+		if (err == nil) != tt.valid {
+			t.Errorf("Test failed, unexpected error for timeline %v: %v", tt.timeline, err)
+		}
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestNextRejoinStrategy(t *testing.T) {
+	table := []struct {
+		strategy string
+		expected string
+	}{
+		{RejoinStrategyRejoin, RejoinStrategyRewind},
+		{RejoinStrategyRewind, RejoinStrategyClone},
+		{RejoinStrategyClone, ""},
+	}
+	for _, tt := range table {
+		actual := nextRejoinStrategy(tt.strategy)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestRejoinEscalation(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	// times are stored with a precision of seconds
+	started := metav1.NewTime(time.Now().Truncate(time.Second))
+	expired := metav1.NewTime(started.Add(-2 * rejoinTimeout))
+	getDeployment := func(request *PostgreSQLRequest) (*appsv1.Deployment, error) {
+		deployment := &appsv1.Deployment{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-two", Namespace: "default"}, deployment)
+		return deployment, err
+	}
+	verifyDeployment := func(request *PostgreSQLRequest, operation string) error {
+		deployment, err := getDeployment(request)
+		if err != nil {
+			return err
+		}
+		if actual := containerEnv(deployment, "STARTUP_OPERATION"); actual != operation {
+			return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", operation, actual)
+		}
+		if id := containerEnv(deployment, "NODE_ID"); id != "2" {
+			return fmt.Errorf("expected NODE_ID: '2', got: '%v'", id)
+		}
+		return nil
+	}
+
+	// the operator is restarted with the rejoin of node-two recorded in the status
+	table := []struct {
+		name     string
+		deployed map[string]int
+		// running reports whether server of node-two accepts connections
+		running  bool
+		strategy string
+		rejoin   *postgresqlv1.PostgreSQLRejoinStatus
+		verify   func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error
+	}{
+		{
+			name:     "rejoined standby finishes the rejoin",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			running:  true,
+			strategy: RejoinStrategyRejoin,
+			rejoin:   &postgresqlv1.PostgreSQLRejoinStatus{StartTime: started},
+			verify: func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error {
+				if status.Rejoin != nil {
+					return fmt.Errorf("expected finished rejoin, got: '%v'", status.Rejoin)
+				}
+				if !containsString(reasons, NodeRejoined) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", NodeRejoined, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "rejoin in progress is awaited",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			strategy: RejoinStrategyRejoin,
+			rejoin:   &postgresqlv1.PostgreSQLRejoinStatus{StartTime: started},
+			verify: func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error {
+				if status.RejoinStrategy != RejoinStrategyRejoin || status.Rejoin == nil || !status.Rejoin.StartTime.Equal(&started) {
+					return fmt.Errorf("expected rejoin started at '%v', got: '%v', '%v'", started, status.RejoinStrategy, status.Rejoin)
+				}
+				if containsString(reasons, RejoinEscalated) {
+					return fmt.Errorf("unexpected event: '%v'", RejoinEscalated)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "timed out rejoin escalated to rewind",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			strategy: RejoinStrategyRejoin,
+			rejoin:   &postgresqlv1.PostgreSQLRejoinStatus{StartTime: expired},
+			verify: func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error {
+				if status.RejoinStrategy != RejoinStrategyRewind || status.Rejoin == nil || !status.Rejoin.StartTime.After(expired.Time) {
+					return fmt.Errorf("expected rewind in progress, got: '%v', '%v'", status.RejoinStrategy, status.Rejoin)
+				}
+				if !containsString(reasons, RejoinEscalated) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", RejoinEscalated, reasons)
+				}
+				return verifyDeployment(request, NodeRewind)
+			},
+		},
+		{
+			name:     "timed out rewind escalated to clone",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			strategy: RejoinStrategyRewind,
+			rejoin:   &postgresqlv1.PostgreSQLRejoinStatus{StartTime: expired},
+			verify: func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error {
+				if status.RejoinStrategy != RejoinStrategyClone || status.Rejoin == nil || !status.Rejoin.Wiping || status.Rejoin.NodeID != 2 {
+					return fmt.Errorf("expected wipe of node with id 2, got: '%v', '%v'", status.RejoinStrategy, status.Rejoin)
+				}
+				if _, err := getDeployment(request); !errors.IsNotFound(err) {
+					return fmt.Errorf("expected deployment of node-two to be deleted, got: '%v'", err)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "interrupted wipe resumed",
+			deployed: map[string]int{"node-one": 1},
+			strategy: RejoinStrategyClone,
+			rejoin:   &postgresqlv1.PostgreSQLRejoinStatus{StartTime: expired, Wiping: true, NodeID: 2},
+			verify: func(request *PostgreSQLRequest, status postgresqlv1.PostgreSQLNodeStatus, reasons []string) error {
+				if status.Rejoin == nil || status.Rejoin.Wiping {
+					return fmt.Errorf("expected clone in progress, got: '%v'", status.Rejoin)
+				}
+				return verifyDeployment(request, StandbyRegister)
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		if !tt.running {
+			repmgr.Stop("node-two")
+		}
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, tt.deployed)
+		request.cluster.Status.Nodes["node-two"] = postgresqlv1.PostgreSQLNodeStatus{
+			DeploymentName: "node-two",
+			ServiceName:    "node-two",
+			RejoinStrategy: tt.strategy,
+			Rejoin:         tt.rejoin,
+		}
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.verify(request, request.cluster.Status.Nodes["node-two"], recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
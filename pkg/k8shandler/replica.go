@@ -0,0 +1,177 @@
+package k8shandler
+
+import (
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// PromoteAnnotation requests promotion of the replica cluster to an independent cluster
+	PromoteAnnotation = "postgresql.openshift.io/promote"
+
+	// upstreamHostEnv and upstreamPortEnv set the server the node is cloned from
+	upstreamHostEnv = "UPSTREAM_HOST"
+	upstreamPortEnv = "UPSTREAM_PORT"
+)
+
+// isReplicaCluster checks whether the cluster streams from its source cluster
+// or is being promoted, nodes of such cluster are read-only and not registered
+// in repmgr
+func isReplicaCluster(cluster *postgresqlv1.PostgreSQL) bool {
+	if cluster.Spec.Replica == nil {
+		return false
+	}
+	status := cluster.Status.Replica
+	return status == nil || status.Phase != postgresqlv1.ReplicaPhasePromoted
+}
+
+func (request *PostgreSQLRequest) isReplica() bool {
+	return isReplicaCluster(request.cluster)
+}
+
+// leadNode returns the lead node recorded in the status of the replica cluster
+func (request *PostgreSQLRequest) leadNode() Node {
+	if !request.isReplica() || request.cluster.Status.Replica == nil {
+		return nil
+	}
+	node, ok := nodes[request.cluster.Status.Replica.LeadNode]
+	if !ok {
+		return nil
+	}
+	logrus.Infof("Lead node %v of replica cluster discovered.", node.name())
+	return node
+}
+
+// setUpstreamEnv configures the server the node is cloned from, the lead node
+// of the replica cluster streams from the source cluster and other nodes
+// cascade from the lead node, the clone import copies the external server
+func (request *PostgreSQLRequest) setUpstreamEnv(container *corev1.Container, name string) {
+	var host string
+	var port int32
+	replica := request.cluster.Spec.Replica
+	imp := request.importSpec()
+	switch operation := containerEnvValue(container, "STARTUP_OPERATION"); {
+	case operation == ReplicaClone && replica != nil:
+		host, port = replica.Source.Host, replica.Source.Port
+		// the lead node is created first, when there is no primary node yet
+		if primaryNode != nil && primaryNode.name() != name {
+			host, port = primaryNode.name(), postgresqlPort
+		}
+	case operation == ImportClone && imp != nil:
+		host, port = imp.Source.Host, imp.Source.Port
+		setContainerEnvVar(container, newSecretEnvVar(importUserEnv, imp.Source.CredentialsSecret, corev1.BasicAuthUsernameKey))
+		setContainerEnvVar(container, newSecretEnvVar(importPasswordEnv, imp.Source.CredentialsSecret, corev1.BasicAuthPasswordKey))
+	default:
+		for _, env := range []string{upstreamHostEnv, upstreamPortEnv, importUserEnv, importPasswordEnv} {
+			removeContainerEnv(container, env)
+		}
+		return
+	}
+	if port == 0 {
+		port = postgresqlPort
+	}
+	setContainerEnv(container, upstreamHostEnv, host)
+	setContainerEnv(container, upstreamPortEnv, fmt.Sprintf("%v", port))
+}
+
+// reconcileReplica records the lead node of the replica cluster and promotes
+// the cluster when requested, the lead node is promoted first, standbys are
+// registered to it once it accepts writes
+func (request *PostgreSQLRequest) reconcileReplica(clusterStatus *postgresqlv1.PostgreSQLStatus) (bool, error) {
+	_, promote := request.cluster.Annotations[PromoteAnnotation]
+	if promote {
+		if err := request.updateAnnotation(PromoteAnnotation, ""); err != nil {
+			return true, fmt.Errorf("Failed to remove %v annotation: %v", PromoteAnnotation, err)
+		}
+	}
+	if !request.isReplica() {
+		if promote {
+			request.recordWarning(PromotionRejected, "Cluster %v is not a replica cluster", request.cluster.Name)
+		}
+		return false, nil
+	}
+	if primaryNode == nil {
+		return true, nil
+	}
+	status := clusterStatus.Replica
+	if status == nil {
+		status = &postgresqlv1.PostgreSQLReplicaStatus{LeadNode: primaryNode.name(), Phase: postgresqlv1.ReplicaPhaseReplicating}
+		clusterStatus.Replica = status
+	}
+
+	switch status.Phase {
+	case postgresqlv1.ReplicaPhaseReplicating:
+		if !promote {
+			return false, nil
+		}
+		if !primaryNode.isReady() {
+			request.recordWarning(PromotionRejected, "Lead node %v is not ready, cluster %v can't be promoted", primaryNode.name(), request.cluster.Name)
+			return false, nil
+		}
+		if err := primaryNode.switchOperation(request, ReplicaPromote); err != nil {
+			return true, fmt.Errorf("Failed to promote lead node %v: %v", primaryNode.name(), err)
+		}
+		status.Phase = postgresqlv1.ReplicaPhasePromoting
+		request.recordNormal(PromotionStarted, "Lead node %v is being promoted to primary", primaryNode.name())
+		return true, nil
+	case postgresqlv1.ReplicaPhasePromoting:
+		if !primaryNode.isReady() {
+			return true, nil
+		}
+		inRecovery, err := primaryNode.dbClient().isInRecovery(request.ctx)
+		if err != nil || inRecovery {
+			return true, err
+		}
+		for name, node := range nodes {
+			if name == primaryNode.name() || node.startupOperation() != ReplicaClone {
+				continue
+			}
+			if err := node.switchOperation(request, StandbyRegister); err != nil {
+				return true, fmt.Errorf("Failed to register node %v to the promoted primary: %v", name, err)
+			}
+		}
+		status.Phase = postgresqlv1.ReplicaPhasePromoted
+		request.recordNormal(ClusterPromoted, "Cluster %v was promoted, node %v is the primary", request.cluster.Name, primaryNode.name())
+		return true, nil
+	}
+	return false, nil
+}
+
+// sourcePasswords reads passwords of the source cluster of the replica cluster
+func (request *PostgreSQLRequest) sourcePasswords() (*pgPasswords, error) {
+	name := request.cluster.Spec.Replica.Source.CredentialsSecret
+	secretData, err := extractSecret(request.ctx, name, request.cluster.Namespace, request.client)
+	if err != nil {
+		return nil, err
+	}
+	ref := credentialsSecret{name: name, databaseKey: defaultDatabasePasswordKey, repmgrKey: defaultRepmgrPasswordKey}
+	if err := ref.validateSecretData(secretData); err != nil {
+		return nil, err
+	}
+	return &pgPasswords{
+		database: string(secretData[defaultDatabasePasswordKey]),
+		repmgr:   string(secretData[defaultRepmgrPasswordKey]),
+	}, nil
+}
+
+// copySourcePasswords stores passwords of the source cluster using the
+// provider, database roles of the replica cluster are a copy of the source
+// cluster, so the passwords stay valid after the cluster is promoted
+func (request *PostgreSQLRequest) copySourcePasswords(provider credentialProvider, current *pgPasswords) error {
+	source, err := request.sourcePasswords()
+	if err != nil {
+		return fmt.Errorf("Failed to read passwords of the source cluster: %v", err)
+	}
+	if current != nil && *current == *source {
+		if _, ok := provider.(*secretProvider); ok {
+			return nil
+		}
+		return writeSecret(request, source)
+	}
+	logrus.Infof("Copying passwords of the source cluster to cluster %v", request.cluster.Name)
+	return request.storePasswords(provider, source)
+}
@@ -0,0 +1,205 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileReplica(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	replica := &postgresqlv1.PostgreSQLReplicaSpec{
+		Source: postgresqlv1.PostgreSQLReplicaSource{Host: "source.example.com", Port: 5433, CredentialsSecret: "source-credentials"},
+	}
+	getDeployment := func(request *PostgreSQLRequest, name string) (*appsv1.Deployment, error) {
+		deployment := &appsv1.Deployment{}
+		err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: "default"}, deployment)
+		return deployment, err
+	}
+
+	table := []struct {
+		name     string
+		deployed map[string]int
+		status   *postgresqlv1.PostgreSQLReplicaStatus
+		promote  bool
+		setup    func(repmgr *fakeRepmgr)
+		// cloning lists deployed nodes started by replica clone operation
+		cloning []string
+		primary string
+		verify  func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error
+	}{
+		{
+			name:    "lead node cloned from source",
+			primary: "node-one",
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				expected := map[string][]string{
+					"node-one": {"source.example.com", "5433"},
+					"node-two": {"node-one", "5432"},
+				}
+				for name, upstream := range expected {
+					deployment, err := getDeployment(request, name)
+					if err != nil {
+						return err
+					}
+					if value := containerEnv(deployment, "STARTUP_OPERATION"); value != ReplicaClone {
+						return fmt.Errorf("expected STARTUP_OPERATION of %v: '%v', got: '%v'", name, ReplicaClone, value)
+					}
+					host, port := containerEnv(deployment, upstreamHostEnv), containerEnv(deployment, upstreamPortEnv)
+					if host != upstream[0] || port != upstream[1] {
+						return fmt.Errorf("expected upstream of %v: '%v', got: '%v:%v'", name, upstream, host, port)
+					}
+				}
+				status := request.cluster.Status.Replica
+				if status == nil || status.LeadNode != "node-one" || status.Phase != postgresqlv1.ReplicaPhaseReplicating {
+					return fmt.Errorf("expected replicating lead node 'node-one', got: '%v'", status)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "lead node discovered and source metadata kept",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   &postgresqlv1.PostgreSQLReplicaStatus{LeadNode: "node-two", Phase: postgresqlv1.ReplicaPhaseReplicating},
+			primary:  "node-two",
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if _, ok := repmgr.records["source-one"]; !ok {
+					return fmt.Errorf("node of the source cluster was unregistered")
+				}
+				if priority := repmgr.records["node-one"].priority; priority != 50 {
+					return fmt.Errorf("expected priority of read-only node: '50', got: '%v'", priority)
+				}
+				if role := request.cluster.Status.Nodes["node-two"].Role; role != postgresqlv1.PostgreSQLNodeRoleStandby {
+					return fmt.Errorf("expected role: '%v', got: '%v'", postgresqlv1.PostgreSQLNodeRoleStandby, role)
+				}
+				if err := request.CreateOrUpdateSecret(); err != nil {
+					return err
+				}
+				secretData, err := extractSecret(request.ctx, "example", "default", request.client)
+				if err != nil {
+					return err
+				}
+				if password := string(secretData[defaultRepmgrPasswordKey]); password != "source-repmgr" {
+					return fmt.Errorf("expected password of the source cluster, got: '%v'", password)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "lead node promoted",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   &postgresqlv1.PostgreSQLReplicaStatus{LeadNode: "node-one", Phase: postgresqlv1.ReplicaPhaseReplicating},
+			promote:  true,
+			cloning:  []string{"node-one", "node-two"},
+			primary:  "node-one",
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				deployment, err := getDeployment(request, "node-one")
+				if err != nil {
+					return err
+				}
+				if value := containerEnv(deployment, "STARTUP_OPERATION"); value != ReplicaPromote {
+					return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", ReplicaPromote, value)
+				}
+				if host := containerEnv(deployment, upstreamHostEnv); host != "" {
+					return fmt.Errorf("expected no upstream of the promoted node, got: '%v'", host)
+				}
+				if phase := request.cluster.Status.Replica.Phase; phase != postgresqlv1.ReplicaPhasePromoting {
+					return fmt.Errorf("expected phase: '%v', got: '%v'", postgresqlv1.ReplicaPhasePromoting, phase)
+				}
+				if _, ok := request.cluster.Annotations[PromoteAnnotation]; ok {
+					return fmt.Errorf("expected %v annotation removed", PromoteAnnotation)
+				}
+				if !containsString(reasons, PromotionStarted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", PromotionStarted, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "standbys registered to promoted lead node",
+			deployed: map[string]int{"node-one": 1, "node-two": 2},
+			status:   &postgresqlv1.PostgreSQLReplicaStatus{LeadNode: "node-one", Phase: postgresqlv1.ReplicaPhasePromoting},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.Promote("node-one")
+			},
+			cloning: []string{"node-two"},
+			primary: "node-one",
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				deployment, err := getDeployment(request, "node-two")
+				if err != nil {
+					return err
+				}
+				if value := containerEnv(deployment, "STARTUP_OPERATION"); value != StandbyRegister {
+					return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", StandbyRegister, value)
+				}
+				if phase := request.cluster.Status.Replica.Phase; phase != postgresqlv1.ReplicaPhasePromoted {
+					return fmt.Errorf("expected phase: '%v', got: '%v'", postgresqlv1.ReplicaPhasePromoted, phase)
+				}
+				if !containsString(reasons, ClusterPromoted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ClusterPromoted, reasons)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		// repmgr metadata is replicated from the source cluster
+		repmgr.Register("source-one", 7, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRoleStandby, 50)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		if tt.setup != nil {
+			tt.setup(repmgr)
+		}
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, tt.deployed)
+		source := &corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "source-credentials", Namespace: "default"},
+			Data: map[string][]byte{
+				defaultDatabasePasswordKey: []byte("source-database"),
+				defaultRepmgrPasswordKey:   []byte("source-repmgr"),
+			},
+		}
+		if err := request.client.Create(request.ctx, source); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		for _, name := range tt.cloning {
+			deployment, err := getDeployment(request, name)
+			if err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+			setContainerEnv(&deployment.Spec.Template.Spec.Containers[0], "STARTUP_OPERATION", ReplicaClone)
+			setContainerEnv(&deployment.Spec.Template.Spec.Containers[0], upstreamHostEnv, "source.example.com")
+			if err := request.client.Update(request.ctx, deployment); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+		}
+		if tt.promote {
+			request.cluster.Annotations = map[string]string{PromoteAnnotation: ""}
+		}
+		request.cluster.Spec.Replica = replica
+		request.cluster.Status.Replica = tt.status
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if primary := primaryNode.name(); primary != tt.primary {
+			t.Errorf("Test %v failed, expected: '%v', got: '%v'", tt.name, tt.primary, primary)
+		}
+		if err := tt.verify(request, repmgr, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
 	"github.com/sirupsen/logrus"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
@@ -14,6 +15,7 @@ import (
 )
 
 func newSecret(request *PostgreSQLRequest, name string, passwords *pgPasswords) *corev1.Secret {
+	ref := request.credentialsSecret()
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: corev1.SchemeGroupVersion.String(),
@@ -24,8 +26,8 @@ func newSecret(request *PostgreSQLRequest, name string, passwords *pgPasswords)
 			Namespace: request.cluster.Namespace,
 		},
 		StringData: map[string]string{
-			"database-password": passwords.database,
-			"repmgr-password":   passwords.repmgr,
+			ref.databaseKey: passwords.database,
+			ref.repmgrKey:   passwords.repmgr,
 		},
 	}
 	// Set PostgreSQL instance as the owner and controller
@@ -33,29 +35,118 @@ func newSecret(request *PostgreSQLRequest, name string, passwords *pgPasswords)
 	return secret
 }
 
+// credentialsSecret describes the secret holding passwords of the cluster
+type credentialsSecret struct {
+	name        string
+	databaseKey string
+	repmgrKey   string
+	// external secret is provided by the user and never modified by the operator
+	external bool
+}
+
+func (request *PostgreSQLRequest) credentialsSecret() credentialsSecret {
+	return newCredentialsSecret(request.cluster)
+}
+
+// newCredentialsSecret returns the secret referenced in the spec, or the secret
+// named after the cluster generated by the operator
+func newCredentialsSecret(cluster *postgresqlv1.PostgreSQL) credentialsSecret {
+	ref := credentialsSecret{
+		name:        cluster.Name,
+		databaseKey: defaultDatabasePasswordKey,
+		repmgrKey:   defaultRepmgrPasswordKey,
+	}
+	spec := cluster.Spec.Credentials
+	if spec == nil {
+		return ref
+	}
+	if spec.SecretName != "" {
+		ref.name = spec.SecretName
+		ref.external = true
+	}
+	if spec.DatabasePasswordKey != "" {
+		ref.databaseKey = spec.DatabasePasswordKey
+	}
+	if spec.RepmgrPasswordKey != "" {
+		ref.repmgrKey = spec.RepmgrPasswordKey
+	}
+	return ref
+}
+
+// validateSecretData checks that all passwords are present in the secret
+func (ref credentialsSecret) validateSecretData(secretData map[string][]byte) error {
+	for _, key := range []string{ref.databaseKey, ref.repmgrKey} {
+		if len(secretData[key]) == 0 {
+			return fmt.Errorf("Key %v is missing in secret %v", key, ref.name)
+		}
+	}
+	return nil
+}
+
+// validateCredentialsSecret checks that the secret holds all passwords, pods
+// of the nodes can't start without them
+func (request *PostgreSQLRequest) validateCredentialsSecret() error {
+	ref := request.credentialsSecret()
+	secretData, err := extractSecret(request.ctx, ref.name, request.cluster.Namespace, request.client)
+	if err != nil {
+		return err
+	}
+	return ref.validateSecretData(secretData)
+}
+
 // CreateOrUpdateSecret creates a new Secret if doesn't exists and ensures all its
-// attributes has desired values
+// attributes has desired values, the secret provided by the user is only validated
 func (request *PostgreSQLRequest) CreateOrUpdateSecret() error {
-	_, err := extractSecret(request.cluster.Name, request.cluster.Namespace, request.client)
-	if errors.IsNotFound(err) {
-		logrus.Infof("Generating secret for cluster %v", request.cluster.Name)
-		passwords, err := newPgPasswords()
+	ref := request.credentialsSecret()
+	secretData, err := extractSecret(request.ctx, ref.name, request.cluster.Namespace, request.client)
+	if ref.external {
+		if err == nil {
+			err = ref.validateSecretData(secretData)
+		}
+		if err != nil {
+			request.recordWarning(InvalidCredentialsSecret, "Secret %v can't be used: %v", ref.name, err)
+		}
+		return err
+	}
+	if errors.IsNotFound(err) && request.cluster.Status.Credentials != nil && request.cluster.Status.Credentials.SecretVersion != "" {
+		request.recordDrift("Secret", ref.name, "was deleted")
+	}
+	provider, err := request.newCredentialProvider()
+	if err != nil {
+		return err
+	}
+	passwords, err := provider.read(request)
+	if request.isReplica() && (err == nil || err == errPasswordsNotFound) {
+		return request.copySourcePasswords(provider, passwords)
+	}
+	if err == errPasswordsNotFound {
+		logrus.Infof("Generating passwords for cluster %v", request.cluster.Name)
+		passwords, err = newPgPasswords()
 		if err != nil {
 			logrus.Errorf("Failed to generate passwords: %v", err)
 			return err
 		}
-		secret := newSecret(request, request.cluster.Name, passwords)
-		if err := request.client.Create(context.TODO(), secret); err != nil {
-			if !errors.IsAlreadyExists(err) {
-				return fmt.Errorf("Failure constructing %v secret: %v", secret.Name, err)
-			}
+		return request.storePasswords(provider, passwords)
+	} else if err != nil {
+		return fmt.Errorf("Failed to read passwords of cluster %v: %v", request.cluster.Name, err)
+	}
+	if _, ok := provider.(*secretProvider); ok {
+		return nil
+	}
+	// Secret consumed by pods mirrors passwords stored by the provider
+	return writeSecret(request, passwords)
+}
 
-		}
+func extractSecret(ctx context.Context, secretName, namespace string, client client.Client) (map[string][]byte, error) {
+	secret, err := getSecret(ctx, secretName, namespace, client)
+	if err != nil {
+		return nil, err
 	}
-	return nil
+	return secret.Data, nil
 }
 
-func extractSecret(secretName, namespace string, client client.Client) (map[string][]byte, error) {
+// getSecret retrieves the secret
+func getSecret(ctx context.Context, secretName, namespace string, client client.Client) (*corev1.Secret, error) {
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Secret",
@@ -67,7 +158,7 @@ func extractSecret(secretName, namespace string, client client.Client) (map[stri
 		},
 	}
 
-	if err := client.Get(context.TODO(), types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, secret); err != nil {
+	if err := client.Get(ctx, types.NamespacedName{Name: secret.Name, Namespace: secret.Namespace}, secret); err != nil {
 		if errors.IsNotFound(err) {
 			logrus.Errorf("Failed to find secret %v: %v", secret.Name, err)
 		} else {
@@ -75,5 +166,5 @@ func extractSecret(secretName, namespace string, client client.Client) (map[stri
 		}
 		return nil, err
 	}
-	return secret.Data, nil
+	return secret, nil
 }
@@ -0,0 +1,55 @@
+package k8shandler
+
+import (
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestGeneratedSecretKeys(t *testing.T) {
+	request, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	request.cluster.Name = "custom"
+	request.cluster.Spec.Credentials = &postgresqlv1.PostgreSQLCredentialsSpec{DatabasePasswordKey: "db", RepmgrPasswordKey: "rm"}
+	if err := request.CreateOrUpdateSecret(); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	secret := &corev1.Secret{}
+	if err := request.client.Get(request.ctx, types.NamespacedName{Name: "custom", Namespace: "default"}, secret); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	for _, key := range []string{"db", "rm"} {
+		if _, ok := secret.StringData[key]; !ok {
+			t.Errorf("Test failed, expected key: '%v', got: '%v'", key, secret.StringData)
+		}
+	}
+}
+
+func TestInvalidExternalSecret(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	nodes = nil
+	primaryNode = nil
+	idSequence = 0
+	connections = &fakeConnector{repmgr: newFakeRepmgr()}
+	request, _ := newTestRequest(t, map[string]int{"node-one": 100}, nil)
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "user-secret", Namespace: "default"},
+		Data:       map[string][]byte{defaultDatabasePasswordKey: []byte("database-secret")},
+	}
+	if err := request.client.Create(request.ctx, secret); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	request.cluster.Spec.Credentials = &postgresqlv1.PostgreSQLCredentialsSpec{SecretName: "user-secret"}
+	if _, err := request.CreateOrUpdateCluster(); err == nil {
+		t.Errorf("Test failed, expected error of the invalid secret")
+	}
+	err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-one", Namespace: "default"}, &appsv1.Deployment{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("Test failed, node was created with invalid secret: '%v'", err)
+	}
+}
@@ -1,8 +1,9 @@
 package k8shandler
 
 import (
-	"context"
 	"fmt"
+	"reflect"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -13,9 +14,12 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
+// selectorAnnotation records the node selected by the service when it was last updated
+const selectorAnnotation = "postgresql.openshift.io/selector"
+
 func newService(request *PostgreSQLRequest, name string, selectorName string) *corev1.Service {
 	var selectorLabels map[string]string
-	selectorLabels = newLabels(request.cluster.Name, selectorName)
+	selectorLabels = newSelector(request.cluster.Name, selectorName)
 	service := &corev1.Service{
 		TypeMeta: metav1.TypeMeta{
 			Kind:       "Service",
@@ -25,6 +29,9 @@ func newService(request *PostgreSQLRequest, name string, selectorName string) *c
 			Name:      name,
 			Namespace: request.cluster.Namespace,
 			Labels:    newLabels(request.cluster.Name, name),
+			Annotations: map[string]string{
+				selectorAnnotation: selectorName,
+			},
 		},
 		Spec: corev1.ServiceSpec{
 			Selector: selectorLabels,
@@ -44,13 +51,14 @@ func newService(request *PostgreSQLRequest, name string, selectorName string) *c
 // attributes has desired values
 func (request *PostgreSQLRequest) CreateOrUpdateService(name string, selectorName string) error {
 	service := newService(request, name, selectorName)
-	if err := request.client.Create(context.TODO(), service); err != nil {
+	if err := request.client.Create(request.ctx, service); err != nil {
 		if !errors.IsAlreadyExists(err) {
 			return fmt.Errorf("Failed to construct service %v: %v", service.Name, err)
 		}
+		drift := ""
 		current := service.DeepCopy()
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
-			if err = request.client.Get(context.TODO(), types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, current); err != nil {
+			if err = request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, current); err != nil {
 				if errors.IsNotFound(err) {
 					// the object doesn't exist -- it was likely culled
 					// recreate it on the next time through if necessary
@@ -58,11 +66,16 @@ func (request *PostgreSQLRequest) CreateOrUpdateService(name string, selectorNam
 				}
 				return fmt.Errorf("Failed to get service %v: %v", service.Name, err)
 			}
+			drift = serviceDrift(request.cluster.Name, current, service)
 			current.Spec.Ports = service.Spec.Ports
 			current.Spec.Selector = service.Spec.Selector
 			current.Spec.PublishNotReadyAddresses = service.Spec.PublishNotReadyAddresses
 			current.Labels = service.Labels
-			if err = request.client.Update(context.TODO(), current); err != nil {
+			if current.Annotations == nil {
+				current.Annotations = make(map[string]string)
+			}
+			current.Annotations[selectorAnnotation] = service.Annotations[selectorAnnotation]
+			if err = request.client.Update(request.ctx, current); err != nil {
 				return err
 			}
 			return nil
@@ -70,6 +83,62 @@ func (request *PostgreSQLRequest) CreateOrUpdateService(name string, selectorNam
 		if retryErr != nil {
 			return retryErr
 		}
+		if drift != "" {
+			request.recordDrift("Service", name, drift)
+		}
+	} else if request.isServiceExpected(name) {
+		request.recordDrift("Service", name, "was deleted")
 	}
 	return nil
 }
+
+// newSelector returns labels of pods selected by the service, the witness
+// node has its own labels
+func newSelector(clusterName, selectorName string) map[string]string {
+	if selectorName == witnessName(clusterName) {
+		return newWitnessLabels(clusterName)
+	}
+	if selectorName == "" {
+		// pods of all data nodes except fenced ones
+		selector := newLabels(clusterName, "")
+		selector[fencedLabel] = strconv.FormatBool(false)
+		return selector
+	}
+	return newLabels(clusterName, selectorName)
+}
+
+// serviceDrift describes modifications of the service made outside of the operator
+func serviceDrift(clusterName string, current, desired *corev1.Service) string {
+	selectorName, ok := current.Annotations[selectorAnnotation]
+	if ok && !reflect.DeepEqual(current.Spec.Selector, newSelector(clusterName, selectorName)) {
+		return "selector was modified"
+	}
+	if len(current.Spec.Ports) != len(desired.Spec.Ports) {
+		return "ports were modified"
+	}
+	for i, port := range desired.Spec.Ports {
+		if current.Spec.Ports[i].Port != port.Port || current.Spec.Ports[i].Protocol != port.Protocol {
+			return "ports were modified"
+		}
+	}
+	return ""
+}
+
+// isServiceExpected checks whether the service should already exist in the running cluster
+func (request *PostgreSQLRequest) isServiceExpected(name string) bool {
+	if len(request.cluster.Status.Nodes) == 0 {
+		return false
+	}
+	if name == "postgresql-primary" || name == "postgresql-ro" {
+		return true
+	}
+	if witness := request.cluster.Status.Witness; witness != nil && witness.ServiceName == name {
+		return true
+	}
+	for _, node := range request.cluster.Status.Nodes {
+		if node.ServiceName == name {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package k8shandler
+
+import (
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestServiceDrift(t *testing.T) {
+	newTestService := func(selectorName, annotation string, port int32) *corev1.Service {
+		return &corev1.Service{
+			ObjectMeta: metav1.ObjectMeta{
+				Annotations: map[string]string{selectorAnnotation: annotation},
+			},
+			Spec: corev1.ServiceSpec{
+				Selector: newLabels("example-cluster", selectorName),
+				Ports:    []corev1.ServicePort{{Port: port, Protocol: "TCP"}},
+			},
+		}
+	}
+	desired := newTestService("node-2", "node-2", postgresqlPort)
+	table := []struct {
+		current  *corev1.Service
+		expected string
+	}{
+		{newTestService("node-1", "node-1", postgresqlPort), ""},
+		{newTestService("node-1-fenced", "node-1-fenced", postgresqlPort), ""},
+		{newTestService("node-3", "node-1", postgresqlPort), "selector was modified"},
+		{newTestService("node-1", "node-1", 5433), "ports were modified"},
+		{&corev1.Service{Spec: corev1.ServiceSpec{Ports: desired.Spec.Ports}}, ""},
+	}
+	for _, tt := range table {
+		if actual := serviceDrift("example-cluster", tt.current, desired); actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestDeploymentDrift(t *testing.T) {
+	newTestContainer := func(image, memory, operation string) *corev1.Container {
+		return &corev1.Container{
+			Image: image,
+			Resources: corev1.ResourceRequirements{
+				Limits: corev1.ResourceList{corev1.ResourceMemory: resource.MustParse(memory)},
+			},
+			Env: []corev1.EnvVar{{Name: "STARTUP_OPERATION", Value: operation}},
+		}
+	}
+	applied := newTestContainer("postgresql:10", "1Gi", PrimaryRegister)
+	table := []struct {
+		current  *corev1.Container
+		expected string
+	}{
+		{newTestContainer("postgresql:10", "1024Mi", PrimaryRegister), ""},
+		{newTestContainer("postgresql:11", "1Gi", PrimaryRegister), "container image modified"},
+		{newTestContainer("postgresql:10", "2Gi", PrimaryRegister), "container resources modified"},
+		{newTestContainer("postgresql:11", "1Gi", StandbyRegister), "container image, environment modified"},
+	}
+	for _, tt := range table {
+		if actual := deploymentDrift(applied, tt.current); actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
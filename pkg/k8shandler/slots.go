@@ -0,0 +1,166 @@
+package k8shandler
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const (
+	// slotPrefix is the prefix of replication slots created by repmgr, slots
+	// with other names are never modified by the operator
+	slotPrefix = "repmgr_slot_"
+	// useSlotsEnv makes repmgr of the node stream from a replication slot
+	useSlotsEnv = "REPMGR_USE_REPLICATION_SLOTS"
+)
+
+// slotName returns name of the replication slot of the node, as named by repmgr
+func slotName(nodeID int) string {
+	return fmt.Sprintf("%s%d", slotPrefix, nodeID)
+}
+
+// replication returns replication settings of the cluster, empty if the cluster doesn't set them
+func (request *PostgreSQLRequest) replication() *postgresqlv1.PostgreSQLReplicationSpec {
+	if request.cluster.Spec.Replication == nil {
+		return &postgresqlv1.PostgreSQLReplicationSpec{}
+	}
+	return request.cluster.Spec.Replication
+}
+
+// setSlotsEnv configures the container to stream from a replication slot if the cluster uses slots
+func (request *PostgreSQLRequest) setSlotsEnv(container *corev1.Container) {
+	if request.replication().UseSlots {
+		setContainerEnv(container, useSlotsEnv, "true")
+	} else {
+		removeContainerEnv(container, useSlotsEnv)
+	}
+}
+
+// reconcileSlots keeps replication slot of every registered standby on the
+// primary, drops orphaned slots and checks the limit of WAL retained by slots,
+// slots are managed while the cluster uses them and until the slots used before
+// are dropped
+func (request *PostgreSQLRequest) reconcileSlots(specNodes map[string]postgresqlv1.PostgreSQLNode, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	replication := request.replication()
+	if !replication.UseSlots && len(clusterStatus.ReplicationSlots) == 0 || request.isReplica() {
+		return nil
+	}
+	if primaryNode == nil || !primaryNode.isReady() {
+		return nil
+	}
+	db := primaryNode.dbClient()
+	existing, err := db.replicationSlots(request.ctx)
+	if err != nil {
+		return fmt.Errorf("Failed to list replication slots: %v", err)
+	}
+	desired := make(map[string]string)
+	if replication.UseSlots {
+		for name := range nodes {
+			if _, ok := specNodes[name]; !ok || name == primaryNode.name() {
+				continue
+			}
+			// slot of the standby is created once the standby is registered
+			info, err := db.getNodeInfo(request.ctx, name)
+			if err != nil || info.id < 0 {
+				continue
+			}
+			desired[slotName(info.id)] = name
+		}
+	}
+
+	slots := make(map[string]postgresqlv1.PostgreSQLReplicationSlotStatus)
+	var exceeded []string
+	for _, slot := range existing {
+		if !strings.HasPrefix(slot.name, slotPrefix) {
+			continue
+		}
+		node, ok := desired[slot.name]
+		if !ok {
+			if slot.active {
+				continue
+			}
+			if err := db.dropReplicationSlot(request.ctx, slot.name); err != nil {
+				logrus.Errorf("Failed to drop orphaned replication slot %v: %v", slot.name, err)
+				continue
+			}
+			request.deleteSeries(replicationSlotRetained, slot.name)
+			request.recordNormal(ReplicationSlotDropped, "Orphaned replication slot %v dropped", slot.name)
+			continue
+		}
+		if replication.MaxSlotRetainedWAL != nil && slot.retainedBytes > replication.MaxSlotRetainedWAL.Value() {
+			// active slot can't be dropped, the standby streaming from it is reported instead
+			if replication.SlotLimitAction == postgresqlv1.SlotLimitActionInvalidate && !slot.active {
+				if err := db.dropReplicationSlot(request.ctx, slot.name); err != nil {
+					logrus.Errorf("Failed to invalidate replication slot %v: %v", slot.name, err)
+				} else {
+					request.deleteSeries(replicationSlotRetained, slot.name)
+					request.recordWarning(ReplicationSlotInvalidated, "Replication slot %v of node %v retained %v bytes of WAL and was dropped, the node may need to be reinitialized",
+						slot.name, node, slot.retainedBytes)
+					slots[slot.name] = postgresqlv1.PostgreSQLReplicationSlotStatus{
+						Node:        node,
+						RetainedWAL: *resource.NewQuantity(slot.retainedBytes, resource.BinarySI),
+						Invalidated: true,
+					}
+					delete(desired, slot.name)
+					continue
+				}
+			}
+			exceeded = append(exceeded, fmt.Sprintf("%v (%v)", slot.name, node))
+			request.recordWarning(ReplicationSlotLimitExceeded, "Replication slot %v of node %v retains %v bytes of WAL", slot.name, node, slot.retainedBytes)
+		}
+		replicationSlotRetained.WithLabelValues(request.seriesLabels(replicationSlotRetained, slot.name)...).Set(float64(slot.retainedBytes))
+		slots[slot.name] = postgresqlv1.PostgreSQLReplicationSlotStatus{
+			Node:        node,
+			Active:      slot.active,
+			RetainedWAL: *resource.NewQuantity(slot.retainedBytes, resource.BinarySI),
+		}
+		delete(desired, slot.name)
+	}
+	for slot, node := range desired {
+		// the standby missed WAL removed after its slot was invalidated, the
+		// slot is recreated once the standby is recloned
+		if previous, ok := clusterStatus.ReplicationSlots[slot]; ok && previous.Invalidated && previous.Node == node && !isRecloning(nodes[node]) {
+			logrus.Infof("Replication slot %v of node %v was invalidated, waiting for the node to be reinitialized", slot, node)
+			slots[slot] = previous
+			continue
+		}
+		if err := db.createReplicationSlot(request.ctx, slot); err != nil {
+			logrus.Errorf("Failed to create replication slot %v of node %v: %v", slot, node, err)
+			continue
+		}
+		logrus.Infof("Replication slot %v of node %v created on %v", slot, node, primaryNode.name())
+		slots[slot] = postgresqlv1.PostgreSQLReplicationSlotStatus{Node: node, RetainedWAL: *resource.NewQuantity(0, resource.BinarySI)}
+	}
+	clusterStatus.ReplicationSlots = nil
+	if len(slots) > 0 {
+		clusterStatus.ReplicationSlots = slots
+	}
+	updateSlotLimitCondition(clusterStatus, exceeded)
+	return nil
+}
+
+// isRecloning checks whether the node rejoins the cluster by cloning a fresh
+// copy of the primary
+func isRecloning(node Node) bool {
+	strategy, rejoin := node.rejoinState()
+	return rejoin != nil && strategy == RejoinStrategyClone
+}
+
+// updateSlotLimitCondition reports slots retaining more WAL than the limit
+func updateSlotLimitCondition(clusterStatus *postgresqlv1.PostgreSQLStatus, exceeded []string) {
+	if len(exceeded) > 0 {
+		sort.Strings(exceeded)
+		setCondition(clusterStatus, postgresqlv1.ReplicationSlotLimitExceeded, corev1.ConditionTrue, "RetainedWALLimit",
+			fmt.Sprintf("Replication slots retaining more WAL than the limit: %v", strings.Join(exceeded, ", ")))
+		return
+	}
+	if condition := getCondition(clusterStatus, postgresqlv1.ReplicationSlotLimitExceeded); condition != nil && condition.Status == corev1.ConditionTrue {
+		setCondition(clusterStatus, postgresqlv1.ReplicationSlotLimitExceeded, corev1.ConditionFalse, "Resolved", "Replication slots retain less WAL than the limit")
+	}
+}
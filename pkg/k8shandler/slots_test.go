@@ -0,0 +1,192 @@
+package k8shandler
+
+import (
+	"fmt"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileSlots(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	limit := resource.MustParse("1Ki")
+
+	table := []struct {
+		name        string
+		replication *postgresqlv1.PostgreSQLReplicationSpec
+		status      map[string]postgresqlv1.PostgreSQLReplicationSlotStatus
+		nodeStatus  *postgresqlv1.PostgreSQLNodeStatus
+		setup       func(repmgr *fakeRepmgr)
+		verify      func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error
+	}{
+		{
+			name:        "slot of standby created",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{UseSlots: true},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if !repmgr.HasSlot("repmgr_slot_2") || repmgr.HasSlot("repmgr_slot_1") {
+					return fmt.Errorf("expected slot only for node-two")
+				}
+				if node := request.cluster.Status.ReplicationSlots["repmgr_slot_2"].Node; node != "node-two" {
+					return fmt.Errorf("expected slot status of 'node-two', got: '%v'", node)
+				}
+				deployment := &appsv1.Deployment{}
+				if err := request.client.Get(request.ctx, types.NamespacedName{Name: "node-two", Namespace: "default"}, deployment); err != nil {
+					return err
+				}
+				if value := containerEnv(deployment, useSlotsEnv); value != "true" {
+					return fmt.Errorf("expected %v: 'true', got: '%v'", useSlotsEnv, value)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "orphaned slot dropped",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{UseSlots: true},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddSlot("node-three", "repmgr_slot_3")
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if repmgr.HasSlot("repmgr_slot_3") {
+					return fmt.Errorf("orphaned slot was not dropped")
+				}
+				if !containsString(reasons, ReplicationSlotDropped) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ReplicationSlotDropped, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "slots dropped after they are disabled",
+			replication: nil,
+			status:      map[string]postgresqlv1.PostgreSQLReplicationSlotStatus{"repmgr_slot_2": {Node: "node-two"}},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+				repmgr.Stop("node-two")
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("slot of node-two was not dropped")
+				}
+				if slots := request.cluster.Status.ReplicationSlots; slots != nil {
+					return fmt.Errorf("expected no slots in status, got: '%v'", slots)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "limit of retained WAL exceeded",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{UseSlots: true, MaxSlotRetainedWAL: &limit},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+				repmgr.SetLag("node-two", 4096)
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				condition := getCondition(&request.cluster.Status, postgresqlv1.ReplicationSlotLimitExceeded)
+				if condition == nil || condition.Status != corev1.ConditionTrue {
+					return fmt.Errorf("expected condition %v, got: '%v'", postgresqlv1.ReplicationSlotLimitExceeded, condition)
+				}
+				if retained := request.cluster.Status.ReplicationSlots["repmgr_slot_2"].RetainedWAL; retained.Value() != 4096 {
+					return fmt.Errorf("expected retained WAL: '4096', got: '%v'", retained.Value())
+				}
+				if !repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("slot exceeding the limit was dropped with alert action")
+				}
+				return nil
+			},
+		},
+		{
+			name: "slot exceeding the limit invalidated",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{
+				UseSlots:           true,
+				MaxSlotRetainedWAL: &limit,
+				SlotLimitAction:    postgresqlv1.SlotLimitActionInvalidate,
+			},
+			setup: func(repmgr *fakeRepmgr) {
+				repmgr.AddSlot("node-two", "repmgr_slot_2")
+				repmgr.SetLag("node-two", 4096)
+				repmgr.Stop("node-two")
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("slot exceeding the limit was not dropped")
+				}
+				if !containsString(reasons, ReplicationSlotInvalidated) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", ReplicationSlotInvalidated, reasons)
+				}
+				if !request.cluster.Status.ReplicationSlots["repmgr_slot_2"].Invalidated {
+					return fmt.Errorf("expected invalidated slot in status, got: '%v'", request.cluster.Status.ReplicationSlots)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "invalidated slot not recreated",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{UseSlots: true},
+			status:      map[string]postgresqlv1.PostgreSQLReplicationSlotStatus{"repmgr_slot_2": {Node: "node-two", Invalidated: true}},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("invalidated slot was recreated")
+				}
+				if !request.cluster.Status.ReplicationSlots["repmgr_slot_2"].Invalidated {
+					return fmt.Errorf("expected invalidated slot in status, got: '%v'", request.cluster.Status.ReplicationSlots)
+				}
+				return nil
+			},
+		},
+		{
+			name:        "invalidated slot recreated once node is recloned",
+			replication: &postgresqlv1.PostgreSQLReplicationSpec{UseSlots: true},
+			status:      map[string]postgresqlv1.PostgreSQLReplicationSlotStatus{"repmgr_slot_2": {Node: "node-two", Invalidated: true}},
+			nodeStatus: &postgresqlv1.PostgreSQLNodeStatus{
+				DeploymentName: "node-two",
+				ServiceName:    "node-two",
+				RejoinStrategy: RejoinStrategyClone,
+				Rejoin:         &postgresqlv1.PostgreSQLRejoinStatus{StartTime: metav1.Now(), Wiping: true, NodeID: 2},
+			},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				if !repmgr.HasSlot("repmgr_slot_2") {
+					return fmt.Errorf("slot of recloned node was not created")
+				}
+				if request.cluster.Status.ReplicationSlots["repmgr_slot_2"].Invalidated {
+					return fmt.Errorf("expected slot no longer invalidated, got: '%v'", request.cluster.Status.ReplicationSlots)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		if tt.setup != nil {
+			tt.setup(repmgr)
+		}
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, map[string]int{"node-one": 1, "node-two": 2})
+		request.cluster.Spec.Replication = tt.replication
+		request.cluster.Status.ReplicationSlots = tt.status
+		if tt.nodeStatus != nil {
+			request.cluster.Status.Nodes["node-two"] = *tt.nodeStatus
+		}
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if err := tt.verify(request, repmgr, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
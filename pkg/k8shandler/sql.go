@@ -1,14 +1,25 @@
 package k8shandler
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"flag"
 	"fmt"
 	"strings"
+	"time"
 
-	_ "github.com/lib/pq" // initialize pq package
+	"github.com/lib/pq"
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+	"github.com/sirupsen/logrus"
 )
 
+// repmgrUser is the role and the database used by repmgr
+const repmgrUser = "repmgr"
+
+var statementTimeout = flag.Duration("statement-timeout", 10*time.Second,
+	"Maximal duration of a single SQL statement run by the operator on a PostgreSQL node")
+
 type databaseInfo struct {
 	host     string
 	port     int
@@ -16,112 +27,134 @@ type databaseInfo struct {
 	dbname   string
 	password string
 	sslmode  string
+	// statementTimeout is enforced by both the server and the client
+	statementTimeout time.Duration
 }
 
 type database struct {
-	info      databaseInfo
-	engine    *sql.DB
-	cachedErr error
+	info   databaseInfo
+	engine *sql.DB
+	// lastCheck is time of the last successful health check of the connection
+	lastCheck time.Time
 }
 
+// errNotConnected is returned by queries of a client which wasn't opened yet
+var errNotConnected = errors.New("Database connection is not open")
+
 func newRepmgrDatabase(host string, password string) *database {
 	return &database{
 		info: databaseInfo{
-			host:     host,
-			port:     postgresqlPort,
-			user:     "repmgr",
-			password: password,
-			dbname:   "repmgr",
-			sslmode:  "disable",
+			host:             host,
+			port:             postgresqlPort,
+			user:             repmgrUser,
+			password:         password,
+			dbname:           repmgrUser,
+			sslmode:          "disable",
+			statementTimeout: *statementTimeout,
 		},
-		cachedErr: nil,
 	}
 }
 
 func (info *databaseInfo) connectionString() string {
-	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=2",
-		info.host, info.port, info.user, info.password, info.dbname, info.sslmode)
+	return fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=%s connect_timeout=2 statement_timeout=%d",
+		info.host, info.port, info.user, quoteConninfo(info.password), info.dbname, info.sslmode, info.statementTimeout.Nanoseconds()/int64(time.Millisecond))
 }
 
-func (db *database) err() error {
-	err := db.cachedErr
-	db.cachedErr = nil
-	return err
+// quoteConninfo quotes a value of the connection string
+func quoteConninfo(value string) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	return "'" + strings.Replace(value, "'", `\'`, -1) + "'"
 }
 
-func (db *database) initialize() {
-	if db.cachedErr == nil {
-		db.engine, db.cachedErr = sql.Open("postgres", db.info.connectionString())
+// withTimeout limits the context of a single statement by the statement timeout,
+// a hung node can't block the reconcile for longer
+func (db *database) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if db.info.statementTimeout <= 0 {
+		return context.WithCancel(ctx)
 	}
+	return context.WithTimeout(ctx, db.info.statementTimeout)
 }
 
-func (db *database) ping() error {
-	if db.cachedErr != nil {
-		return db.cachedErr
+// open creates connection pool of the client, connections are established lazily
+func (db *database) open() error {
+	engine, err := sql.Open("postgres", db.info.connectionString())
+	if err != nil {
+		return err
 	}
+	engine.SetMaxOpenConns(maxOpenConnections)
+	engine.SetMaxIdleConns(maxIdleConnections)
+	engine.SetConnMaxLifetime(connectionMaxLifetime)
+	db.engine = engine
+	db.lastCheck = time.Time{}
+	return nil
+}
 
-	return db.engine.Ping()
+// close closes all connections of the client
+func (db *database) close() error {
+	if db.engine == nil {
+		return nil
+	}
+	err := db.engine.Close()
+	db.engine = nil
+	return err
 }
 
-// version returns version of PostgreSQL server
-func (db *database) version() string {
-	var version string
+// queryRow runs the query bounded by the statement timeout and scans the single result row
+func (db *database) queryRow(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	if db.engine == nil {
+		return errNotConnected
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	return db.engine.QueryRowContext(ctx, query, args...).Scan(dest...)
+}
 
-	if db.cachedErr != nil {
-		return "unknown"
+// exec runs the statement bounded by the statement timeout
+func (db *database) exec(ctx context.Context, stmt string, args ...interface{}) error {
+	if db.engine == nil {
+		return errNotConnected
 	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	_, err := db.engine.ExecContext(ctx, stmt, args...)
+	return err
+}
 
-	row := db.engine.QueryRow("SELECT version()")
-	if db.cachedErr = row.Scan(&version); db.cachedErr != nil {
-		return "unknown"
+// version returns version of PostgreSQL server
+func (db *database) version(ctx context.Context) (string, error) {
+	var version string
+	if err := db.queryRow(ctx, "SELECT version()", nil, &version); err != nil {
+		return "unknown", err
 	}
 	fields := strings.Fields(version)
 	if len(fields) <= 2 {
-		db.cachedErr = fmt.Errorf("Failed to retrieve PostgreSQL version")
-		return "unknown"
+		return "unknown", fmt.Errorf("Failed to retrieve PostgreSQL version")
 	}
-	return fields[1]
+	return fields[1], nil
 }
 
 // repmgrNodesExists checks whether repmgr.nodes table was created already
-func (db *database) repmgrNodesExists() bool {
+func (db *database) repmgrNodesExists(ctx context.Context) (bool, error) {
 	var result bool
-
-	if db.cachedErr != nil {
-		return false
-	}
-
-	row := db.engine.QueryRow("SELECT EXISTS ( SELECT 1 FROM information_schema.tables WHERE table_schema = 'repmgr' AND table_name = 'nodes')")
-	if db.cachedErr = row.Scan(&result); db.cachedErr != nil {
-		return false
-	}
-	return result
+	err := db.queryRow(ctx, "SELECT EXISTS ( SELECT 1 FROM information_schema.tables WHERE table_schema = 'repmgr' AND table_name = 'nodes')", nil, &result)
+	return result, err
 }
 
 // isRegistered checks whether node name is present in repmgr.nodes table
-func (db *database) isRegistered(nodeName string) bool {
+func (db *database) isRegistered(ctx context.Context, nodeName string) (bool, error) {
 	var result int
-	var stmt *sql.Stmt
-
-	if db.cachedErr != nil {
-		return false
-	}
 
 	// if repmgr.nodes table is missing, node is not registered
-	exists := db.repmgrNodesExists()
-	if db.cachedErr != nil || !exists {
-		return false
+	exists, err := db.repmgrNodesExists(ctx)
+	if err != nil || !exists {
+		return false, err
 	}
 
 	// repmgr.nodes table exists, check if it contains row for the node
-	stmt, db.cachedErr = db.engine.Prepare("SELECT COUNT(*) FROM repmgr.nodes WHERE node_name = $1")
-	if db.cachedErr != nil {
-		return false
-	}
-	if db.cachedErr = stmt.QueryRow(nodeName).Scan(&result); db.cachedErr != nil {
-		return false
+	if err := db.queryRow(ctx, "SELECT COUNT(*) FROM repmgr.nodes WHERE node_name = $1", []interface{}{nodeName}, &result); err != nil {
+		return false, err
 	}
-	return result == 1
+	return result == 1, nil
 }
 
 type nodeInfo struct {
@@ -130,42 +163,371 @@ type nodeInfo struct {
 	priority int
 }
 
-// getRole retrieves current node role inside repmgr cluster
-func (db *database) getNodeInfo(nodeName string) *nodeInfo {
+// getNodeInfo retrieves id, role and priority of the node inside repmgr cluster
+func (db *database) getNodeInfo(ctx context.Context, nodeName string) (*nodeInfo, error) {
 	info := &nodeInfo{-1, postgresqlv1.PostgreSQLNodeRoleUnknown, -1}
-	var stmt *sql.Stmt
-
-	if db.cachedErr != nil {
-		return info
-	}
 
 	// if repmgr.nodes table is missing, role is unknown
-	exists := db.repmgrNodesExists()
-	if db.cachedErr != nil || !exists {
-		return info
+	exists, err := db.repmgrNodesExists(ctx)
+	if err != nil || !exists {
+		return info, err
 	}
 
 	// repmgr.nodes table exists, check the role
-	stmt, db.cachedErr = db.engine.Prepare("SELECT node_id, type, priority FROM repmgr.nodes WHERE node_name = $1")
-	if db.cachedErr != nil {
-		return info
+	result := &nodeInfo{}
+	if err := db.queryRow(ctx, "SELECT node_id, type, priority FROM repmgr.nodes WHERE node_name = $1", []interface{}{nodeName},
+		&result.id, &result.role, &result.priority); err != nil {
+		return info, err
 	}
-	if db.cachedErr = stmt.QueryRow(nodeName).Scan(&info.id, &info.role, &info.priority); db.cachedErr != nil {
-		return info
-	}
-	return info
+	return result, nil
 }
 
 // updateNodePriority updates failover priority of selected node
-func (db *database) updateNodePriority(nodeName string, priority int) {
-	if db.cachedErr != nil {
-		return
-	}
+func (db *database) updateNodePriority(ctx context.Context, nodeName string, priority int) error {
 	// can't update priority, if repmgr.nodes table is missing
-	exists := db.repmgrNodesExists()
-	if db.cachedErr != nil || !exists {
-		return
+	exists, err := db.repmgrNodesExists(ctx)
+	if err != nil || !exists {
+		return err
+	}
+	return db.exec(ctx, "UPDATE repmgr.nodes SET priority = $1 WHERE node_name = $2", priority, nodeName)
+}
+
+// isInRecovery checks whether the server is running in recovery mode as a standby
+func (db *database) isInRecovery(ctx context.Context) (bool, error) {
+	var result bool
+	err := db.queryRow(ctx, "SELECT pg_is_in_recovery()", nil, &result)
+	return result, err
+}
+
+// timelineQuery returns the timeline the server currently runs on. The
+// timeline of the latest checkpoint stays behind until the first checkpoint
+// after a promotion, so the primary reads it from the name of the current WAL
+// segment and the standby from its WAL receiver.
+const timelineQuery = `SELECT CASE WHEN pg_is_in_recovery() THEN
+  coalesce((SELECT received_tli FROM pg_stat_wal_receiver), (SELECT timeline_id FROM pg_control_checkpoint()))
+ELSE ('x' || substr(pg_walfile_name(pg_current_wal_lsn()), 1, 8))::bit(32)::int END`
+
+// timeline returns the current timeline id of the server
+func (db *database) timeline(ctx context.Context) (int, error) {
+	var result int
+	if err := db.queryRow(ctx, timelineQuery, nil, &result); err != nil {
+		return -1, err
+	}
+	return result, nil
+}
+
+// currentLSN returns the latest WAL location written by primary or replayed by standby
+func (db *database) currentLSN(ctx context.Context) (string, error) {
+	var result string
+	err := db.queryRow(ctx, "SELECT (CASE WHEN pg_is_in_recovery() THEN pg_last_wal_replay_lsn() ELSE pg_current_wal_lsn() END)::text", nil, &result)
+	return result, err
+}
+
+// timelineHistory returns content of the history file of the given timeline
+func (db *database) timelineHistory(ctx context.Context, timeline int) (string, error) {
+	var result string
+	err := db.queryRow(ctx, "SELECT pg_read_file(format('pg_wal/%s.history', lpad(upper(to_hex($1::int)), 8, '0')))", []interface{}{timeline}, &result)
+	return result, err
+}
+
+// unregisterNode removes record of the node from repmgr.nodes table
+func (db *database) unregisterNode(ctx context.Context, nodeName string) error {
+	// nothing to remove, if repmgr.nodes table is missing
+	exists, err := db.repmgrNodesExists(ctx)
+	if err != nil || !exists {
+		return err
+	}
+	return db.exec(ctx, "DELETE FROM repmgr.nodes WHERE node_name = $1", nodeName)
+}
+
+// registeredNodes returns names of all nodes in repmgr.nodes table
+func (db *database) registeredNodes(ctx context.Context) ([]string, error) {
+	// nothing is registered, if repmgr.nodes table is missing
+	exists, err := db.repmgrNodesExists(ctx)
+	if err != nil || !exists {
+		return []string{}, err
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	rows, err := db.engine.QueryContext(ctx, "SELECT node_name FROM repmgr.nodes ORDER BY node_id")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// dropNodeSlot drops replication slot recorded for the node in repmgr.nodes
+// table, returns name of the dropped slot, empty if the node has no slot
+func (db *database) dropNodeSlot(ctx context.Context, nodeName string) (string, error) {
+	var slot sql.NullString
+	if err := db.queryRow(ctx, "SELECT slot_name FROM repmgr.nodes WHERE node_name = $1", []interface{}{nodeName}, &slot); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	if !slot.Valid || slot.String == "" {
+		return "", nil
+	}
+	var active bool
+	if err := db.queryRow(ctx, "SELECT active FROM pg_replication_slots WHERE slot_name = $1", []interface{}{slot.String}, &active); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	if active {
+		return "", fmt.Errorf("Replication slot %v is still in use", slot.String)
+	}
+	return slot.String, db.exec(ctx, "SELECT pg_drop_replication_slot($1)", slot.String)
+}
+
+// replicationStats returns state and replay lag of standbys streaming from the node
+func (db *database) replicationStats(ctx context.Context) ([]replicationStat, error) {
+	if db.engine == nil {
+		return nil, errNotConnected
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	rows, err := db.engine.QueryContext(ctx, "SELECT application_name, state, COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), replay_lsn), 0)::bigint FROM pg_stat_replication")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	stats := []replicationStat{}
+	for rows.Next() {
+		stat := replicationStat{}
+		if err := rows.Scan(&stat.applicationName, &stat.state, &stat.lagBytes); err != nil {
+			return nil, err
+		}
+		stats = append(stats, stat)
+	}
+	return stats, rows.Err()
+}
+
+// replicationSlots returns physical replication slots of the node
+func (db *database) replicationSlots(ctx context.Context) ([]replicationSlot, error) {
+	if db.engine == nil {
+		return nil, errNotConnected
+	}
+	ctx, cancel := db.withTimeout(ctx)
+	defer cancel()
+	rows, err := db.engine.QueryContext(ctx, "SELECT slot_name, active, COALESCE(pg_wal_lsn_diff(pg_current_wal_lsn(), restart_lsn), 0)::bigint FROM pg_replication_slots WHERE slot_type = 'physical'")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	slots := []replicationSlot{}
+	for rows.Next() {
+		slot := replicationSlot{}
+		if err := rows.Scan(&slot.name, &slot.active, &slot.retainedBytes); err != nil {
+			return nil, err
+		}
+		slots = append(slots, slot)
+	}
+	return slots, rows.Err()
+}
+
+// createReplicationSlot creates physical replication slot, which reserves WAL immediately
+func (db *database) createReplicationSlot(ctx context.Context, slot string) error {
+	return db.exec(ctx, "SELECT pg_create_physical_replication_slot($1, true)", slot)
+}
+
+// dropReplicationSlot drops replication slot, which is not in use
+func (db *database) dropReplicationSlot(ctx context.Context, slot string) error {
+	return db.exec(ctx, "SELECT pg_drop_replication_slot($1)", slot)
+}
+
+// checkpoint forces a checkpoint, so the following shutdown doesn't need to flush much data
+func (db *database) checkpoint(ctx context.Context) error {
+	return db.exec(ctx, "CHECKPOINT")
+}
+
+// setPassword changes password of the database role
+func (db *database) setPassword(ctx context.Context, role, password string) error {
+	stmt := fmt.Sprintf("ALTER ROLE %s WITH PASSWORD %s", quoteIdentifier(role), quoteLiteral(password))
+	return db.exec(ctx, stmt)
+}
+
+// inDatabase returns client of another database of the same server, logical
+// replication objects belong to a single database, the client must be closed
+func (db *database) inDatabase(dbname string) (*database, error) {
+	if db.engine == nil {
+		return nil, errNotConnected
+	}
+	other := &database{info: db.info}
+	other.info.dbname = dbname
+	if err := other.open(); err != nil {
+		return nil, err
+	}
+	return other, nil
+}
+
+// publications returns logical replication publications of the database
+func (db *database) publications(ctx context.Context, dbname string) ([]publication, error) {
+	appDB, err := db.inDatabase(dbname)
+	if err != nil {
+		return nil, err
+	}
+	defer appDB.close()
+	ctx, cancel := appDB.withTimeout(ctx)
+	defer cancel()
+	rows, err := appDB.engine.QueryContext(ctx, `SELECT p.pubname, p.puballtables,
+		COALESCE(array_agg(t.schemaname || '.' || t.tablename ORDER BY t.schemaname, t.tablename) FILTER (WHERE NOT p.puballtables), '{}')
+		FROM pg_publication p LEFT JOIN pg_publication_tables t ON t.pubname = p.pubname
+		GROUP BY p.pubname, p.puballtables`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	pubs := []publication{}
+	for rows.Next() {
+		pub := publication{}
+		if err := rows.Scan(&pub.name, &pub.allTables, pq.Array(&pub.tables)); err != nil {
+			return nil, err
+		}
+		pubs = append(pubs, pub)
+	}
+	return pubs, rows.Err()
+}
+
+// createPublication creates publication of the listed tables or of all tables
+func (db *database) createPublication(ctx context.Context, dbname string, pub publication) error {
+	target := "ALL TABLES"
+	if !pub.allTables {
+		target = "TABLE " + quoteTables(pub.tables)
+	}
+	return db.execIn(ctx, dbname, fmt.Sprintf("CREATE PUBLICATION %s FOR %s", quoteIdentifier(pub.name), target))
+}
+
+// alterPublication replaces tables of the publication, publication of all
+// tables can't be altered
+func (db *database) alterPublication(ctx context.Context, dbname string, pub publication) error {
+	return db.execIn(ctx, dbname, fmt.Sprintf("ALTER PUBLICATION %s SET TABLE %s", quoteIdentifier(pub.name), quoteTables(pub.tables)))
+}
+
+// dropPublication drops the publication
+func (db *database) dropPublication(ctx context.Context, dbname, name string) error {
+	return db.execIn(ctx, dbname, fmt.Sprintf("DROP PUBLICATION IF EXISTS %s", quoteIdentifier(name)))
+}
+
+// subscriptions returns logical replication subscriptions of the database and
+// state of their apply workers
+func (db *database) subscriptions(ctx context.Context, dbname string) ([]subscription, error) {
+	appDB, err := db.inDatabase(dbname)
+	if err != nil {
+		return nil, err
+	}
+	defer appDB.close()
+	ctx, cancel := appDB.withTimeout(ctx)
+	defer cancel()
+	rows, err := appDB.engine.QueryContext(ctx, `SELECT s.subname, s.subconninfo, s.subpublications, s.subenabled, st.pid IS NOT NULL,
+		COALESCE(EXTRACT(EPOCH FROM now() - st.latest_end_time), -1)::float8
+		FROM pg_subscription s LEFT JOIN pg_stat_subscription st ON st.subid = s.oid AND st.relid IS NULL
+		WHERE s.subdbid = (SELECT oid FROM pg_database WHERE datname = current_database())`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	subs := []subscription{}
+	for rows.Next() {
+		sub := subscription{}
+		var lag float64
+		if err := rows.Scan(&sub.name, &sub.conninfo, pq.Array(&sub.publications), &sub.enabled, &sub.streaming, &lag); err != nil {
+			return nil, err
+		}
+		sub.lag = time.Duration(lag * float64(time.Second))
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+// createSubscription creates subscription, which copies existing data of the
+// published tables and creates replication slot on the publisher
+func (db *database) createSubscription(ctx context.Context, dbname string, sub subscription) error {
+	return db.execIn(ctx, dbname, fmt.Sprintf("CREATE SUBSCRIPTION %s CONNECTION %s PUBLICATION %s",
+		quoteIdentifier(sub.name), quoteLiteral(sub.conninfo), quoteIdentifiers(sub.publications)))
+}
+
+// alterSubscription updates connection string and publications of the subscription
+func (db *database) alterSubscription(ctx context.Context, dbname string, sub subscription) error {
+	if err := db.execIn(ctx, dbname, fmt.Sprintf("ALTER SUBSCRIPTION %s CONNECTION %s", quoteIdentifier(sub.name), quoteLiteral(sub.conninfo))); err != nil {
+		return err
+	}
+	return db.execIn(ctx, dbname, fmt.Sprintf("ALTER SUBSCRIPTION %s SET PUBLICATION %s", quoteIdentifier(sub.name), quoteIdentifiers(sub.publications)))
+}
+
+// dropSubscription drops the subscription together with its slot on the
+// publisher, the slot is left behind if the publisher is unreachable
+func (db *database) dropSubscription(ctx context.Context, dbname, name string) error {
+	err := db.execIn(ctx, dbname, fmt.Sprintf("DROP SUBSCRIPTION IF EXISTS %s", quoteIdentifier(name)))
+	if err == nil {
+		return nil
+	}
+	logrus.Warnf("Failed to drop subscription %v, dropping it without its replication slot: %v", name, err)
+	for _, stmt := range []string{
+		fmt.Sprintf("ALTER SUBSCRIPTION %s DISABLE", quoteIdentifier(name)),
+		fmt.Sprintf("ALTER SUBSCRIPTION %s SET (slot_name = NONE)", quoteIdentifier(name)),
+		fmt.Sprintf("DROP SUBSCRIPTION %s", quoteIdentifier(name)),
+	} {
+		if err := db.execIn(ctx, dbname, stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// execIn runs the statement in another database of the same server
+func (db *database) execIn(ctx context.Context, dbname, stmt string) error {
+	appDB, err := db.inDatabase(dbname)
+	if err != nil {
+		return err
+	}
+	defer appDB.close()
+	return appDB.exec(ctx, stmt)
+}
+
+// quoteTables quotes names of the tables qualified by schema
+func quoteTables(tables []string) string {
+	quoted := make([]string, 0, len(tables))
+	for _, table := range tables {
+		parts := strings.SplitN(table, ".", 2)
+		for i := range parts {
+			parts[i] = quoteIdentifier(parts[i])
+		}
+		quoted = append(quoted, strings.Join(parts, "."))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteIdentifiers quotes the list of identifiers
+func quoteIdentifiers(names []string) string {
+	quoted := make([]string, 0, len(names))
+	for _, name := range names {
+		quoted = append(quoted, quoteIdentifier(name))
+	}
+	return strings.Join(quoted, ", ")
+}
+
+// quoteIdentifier quotes an identifier to be used as a part of SQL statement
+func quoteIdentifier(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// quoteLiteral quotes a string literal to be used as a part of SQL statement
+func quoteLiteral(literal string) string {
+	literal = strings.Replace(literal, `'`, `''`, -1)
+	if strings.Contains(literal, `\`) {
+		return `E'` + strings.Replace(literal, `\`, `\\`, -1) + `'`
 	}
-	stmt := "UPDATE repmgr.nodes SET priority = $1 WHERE node_name = $2"
-	_, db.cachedErr = db.engine.Exec(stmt, priority, nodeName)
+	return `'` + literal + `'`
 }
@@ -0,0 +1,59 @@
+package k8shandler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestQuoteIdentifier(t *testing.T) {
+	table := []struct {
+		name     string
+		expected string
+	}{
+		{"repmgr", `"repmgr"`},
+		{`we"ird`, `"we""ird"`},
+	}
+	for _, tt := range table {
+		actual := quoteIdentifier(tt.name)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestQuoteLiteral(t *testing.T) {
+	table := []struct {
+		literal  string
+		expected string
+	}{
+		{"secret", `'secret'`},
+		{"it's", `'it''s'`},
+		{`back\slash`, `E'back\\slash'`},
+	}
+	for _, tt := range table {
+		actual := quoteLiteral(tt.literal)
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestConnectionString(t *testing.T) {
+	table := []struct {
+		password string
+		timeout  time.Duration
+		expected string
+	}{
+		{"secret", 10 * time.Second, "host=node-one port=5432 user=repmgr password='secret' dbname=repmgr sslmode=disable connect_timeout=2 statement_timeout=10000"},
+		{"secret", 0, "host=node-one port=5432 user=repmgr password='secret' dbname=repmgr sslmode=disable connect_timeout=2 statement_timeout=0"},
+		{"a b='c", 0, `host=node-one port=5432 user=repmgr password='a b=\'c' dbname=repmgr sslmode=disable connect_timeout=2 statement_timeout=0`},
+	}
+	for _, tt := range table {
+		db := newRepmgrDatabase("node-one", tt.password)
+		db.info.statementTimeout = tt.timeout
+		actual := db.info.connectionString()
+		if actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package k8shandler
+
+import (
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// clusterState keeps nodes of a single cluster between reconciles
+type clusterState struct {
+	nodes       map[string]Node
+	primaryNode Node
+	idSequence  int
+}
+
+var (
+	statesMutex   sync.Mutex
+	clusterStates = make(map[types.NamespacedName]*clusterState)
+)
+
+// loadClusterState makes nodes of the cluster current for the reconcile, the
+// returned function stores them back, so every cluster keeps its own nodes
+// and clusters are reconciled one at a time
+func (request *PostgreSQLRequest) loadClusterState() func() {
+	statesMutex.Lock()
+	key := types.NamespacedName{Namespace: request.cluster.Namespace, Name: request.cluster.Name}
+	if state, ok := clusterStates[key]; ok {
+		nodes, primaryNode, idSequence = state.nodes, state.primaryNode, state.idSequence
+	} else {
+		nodes, primaryNode, idSequence = nil, nil, 0
+	}
+	return func() {
+		clusterStates[key] = &clusterState{nodes: nodes, primaryNode: primaryNode, idSequence: idSequence}
+		nodes, primaryNode, idSequence = nil, nil, 0
+		statesMutex.Unlock()
+	}
+}
+
+// DeleteClusterState forgets nodes of the removed cluster
+func DeleteClusterState(namespace, cluster string) {
+	statesMutex.Lock()
+	defer statesMutex.Unlock()
+	delete(clusterStates, types.NamespacedName{Namespace: namespace, Name: cluster})
+}
@@ -0,0 +1,40 @@
+package k8shandler
+
+import (
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestClusterState(t *testing.T) {
+	newRequest := func(name string) *PostgreSQLRequest {
+		return &PostgreSQLRequest{cluster: &postgresqlv1.PostgreSQL{ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"}}}
+	}
+	store := newRequest("first").loadClusterState()
+	nodes = map[string]Node{"node-one": &deploymentNode{}}
+	idSequence = 1
+	store()
+
+	store = newRequest("second").loadClusterState()
+	if len(nodes) != 0 || idSequence != 0 {
+		t.Errorf("Test failed, second cluster inherited nodes of the first one: '%v'", nodes)
+	}
+	store()
+
+	store = newRequest("first").loadClusterState()
+	if _, ok := nodes["node-one"]; !ok || idSequence != 1 {
+		t.Errorf("Test failed, nodes of the first cluster were not kept: '%v'", nodes)
+	}
+	store()
+
+	DeleteClusterState("default", "first")
+	store = newRequest("first").loadClusterState()
+	if len(nodes) != 0 || idSequence != 0 {
+		t.Errorf("Test failed, nodes of the deleted cluster were kept: '%v'", nodes)
+	}
+	store()
+	DeleteClusterState("default", "first")
+	DeleteClusterState("default", "second")
+}
@@ -1,7 +1,6 @@
 package k8shandler
 
 import (
-	"context"
 	"fmt"
 	"reflect"
 
@@ -12,16 +11,16 @@ import (
 
 // UpdateClusterStatus compares current and new status and perfroms the update if needed.
 func UpdateClusterStatus(request *PostgreSQLRequest, clusterStatus *postgresqlv1.PostgreSQLStatus) error {
-	if !reflect.DeepEqual(request.cluster.Status, clusterStatus) {
+	if !reflect.DeepEqual(request.cluster.Status, *clusterStatus) {
 		nretries := -1
 		retryErr := retry.RetryOnConflict(retry.DefaultRetry, func() error {
 			nretries++
-			if err := request.client.Get(context.TODO(), types.NamespacedName{Name: request.cluster.Name, Namespace: request.cluster.Namespace}, request.cluster); err != nil {
+			if err := request.client.Get(request.ctx, types.NamespacedName{Name: request.cluster.Name, Namespace: request.cluster.Namespace}, request.cluster); err != nil {
 				return fmt.Errorf("Couldn't get cluster: %v", err)
 			}
-			request.cluster.Status.Nodes = clusterStatus.Nodes
+			request.cluster.Status = *clusterStatus
 
-			if err := request.client.Update(context.TODO(), request.cluster); err != nil {
+			if err := request.client.Update(request.ctx, request.cluster); err != nil {
 				return fmt.Errorf("Failed to update cluster status: %v", err)
 			}
 			return nil
@@ -32,3 +31,24 @@ func UpdateClusterStatus(request *PostgreSQLRequest, clusterStatus *postgresqlv1
 	}
 	return nil
 }
+
+// updateAnnotation sets annotation of the cluster resource, empty value removes the annotation
+func (request *PostgreSQLRequest) updateAnnotation(key, value string) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: request.cluster.Name, Namespace: request.cluster.Namespace}, request.cluster); err != nil {
+			return fmt.Errorf("Couldn't get cluster: %v", err)
+		}
+		if current, ok := request.cluster.Annotations[key]; ok && current == value || !ok && value == "" {
+			return nil
+		}
+		if value == "" {
+			delete(request.cluster.Annotations, key)
+		} else {
+			if request.cluster.Annotations == nil {
+				request.cluster.Annotations = make(map[string]string)
+			}
+			request.cluster.Annotations[key] = value
+		}
+		return request.client.Update(request.ctx, request.cluster)
+	})
+}
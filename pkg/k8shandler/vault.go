@@ -0,0 +1,173 @@
+package k8shandler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path"
+	"strings"
+	"time"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+const vaultTimeout = 10 * time.Second
+
+var (
+	vaultMount = flag.String("vault-mount", "secret",
+		"Mount of the KV version 2 secrets engine used by the vault credentials provider")
+	vaultPathPrefix = flag.String("vault-path-prefix", "postgresql",
+		"Vault path under which passwords of clusters are kept, each cluster is limited to <prefix>/<namespace>/<name>")
+)
+
+// vaultProvider keeps passwords in Vault KV version 2 secrets engine
+type vaultProvider struct {
+	address string
+	token   string
+	mount   string
+	path    string
+	client  *http.Client
+}
+
+// vaultSecret is the body of KV version 2 read response and write request
+type vaultSecret struct {
+	Data map[string]string `json:"data"`
+}
+
+// newVaultProvider creates provider for the cluster, address and token of Vault
+// are taken from VAULT_ADDR and VAULT_TOKEN variables of the operator. The
+// token is shared by all clusters, so the path in the spec is allowed only
+// under the root of the cluster.
+func newVaultProvider(cluster *postgresqlv1.PostgreSQL) (*vaultProvider, error) {
+	address := os.Getenv("VAULT_ADDR")
+	token := os.Getenv("VAULT_TOKEN")
+	if address == "" || token == "" {
+		return nil, fmt.Errorf("VAULT_ADDR and VAULT_TOKEN must be set to use vault credentials provider")
+	}
+	root := vaultClusterPath(cluster)
+	provider := &vaultProvider{
+		address: address,
+		token:   token,
+		mount:   strings.Trim(*vaultMount, "/"),
+		path:    root,
+		client:  &http.Client{Timeout: vaultTimeout},
+	}
+	if vault := cluster.Spec.Credentials.Vault; vault != nil {
+		if vault.Mount != "" && strings.Trim(vault.Mount, "/") != provider.mount {
+			return nil, fmt.Errorf("Vault mount %v is not allowed, passwords are kept in mount %v", vault.Mount, provider.mount)
+		}
+		if vault.Path != "" {
+			vaultPath := strings.Trim(vault.Path, "/")
+			if !isVaultSubpath(root, vaultPath) {
+				return nil, fmt.Errorf("Vault path %v is not allowed, it must be %v or below it", vault.Path, root)
+			}
+			provider.path = vaultPath
+		}
+	}
+	return provider, nil
+}
+
+// vaultClusterPath returns the root of Vault paths of the cluster
+func vaultClusterPath(cluster *postgresqlv1.PostgreSQL) string {
+	return strings.Trim(path.Join(*vaultPathPrefix, cluster.Namespace, cluster.Name), "/")
+}
+
+// isVaultSubpath checks whether the path is the root or lies below it
+func isVaultSubpath(root, vaultPath string) bool {
+	for _, segment := range strings.Split(vaultPath, "/") {
+		if segment == "" || segment == "." || segment == ".." {
+			return false
+		}
+	}
+	return vaultPath == root || strings.HasPrefix(vaultPath, root+"/")
+}
+
+// url returns address of the data endpoint of the secret
+func (p *vaultProvider) url() string {
+	return fmt.Sprintf("%v/v1/%v/data/%v", strings.TrimSuffix(p.address, "/"), strings.Trim(p.mount, "/"), strings.Trim(p.path, "/"))
+}
+
+func (p *vaultProvider) read(request *PostgreSQLRequest) (*pgPasswords, error) {
+	resp, err := p.do(request.ctx, http.MethodGet, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errPasswordsNotFound
+	}
+	if err := vaultError(resp); err != nil {
+		return nil, err
+	}
+	body := struct {
+		Data vaultSecret `json:"data"`
+	}{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("Failed to decode response of Vault: %v", err)
+	}
+	passwords := &pgPasswords{
+		database: body.Data.Data[defaultDatabasePasswordKey],
+		repmgr:   body.Data.Data[defaultRepmgrPasswordKey],
+	}
+	// Deleted or destroyed versions are returned without data
+	if passwords.database == "" || passwords.repmgr == "" {
+		return nil, errPasswordsNotFound
+	}
+	return passwords, nil
+}
+
+func (p *vaultProvider) write(request *PostgreSQLRequest, passwords *pgPasswords) error {
+	body, err := json.Marshal(vaultSecret{
+		Data: map[string]string{
+			defaultDatabasePasswordKey: passwords.database,
+			defaultRepmgrPasswordKey:   passwords.repmgr,
+		},
+	})
+	if err != nil {
+		return err
+	}
+	resp, err := p.do(request.ctx, http.MethodPost, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return vaultError(resp)
+}
+
+// do sends request authenticated by the token to the data endpoint of the secret
+func (p *vaultProvider) do(ctx context.Context, method string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, p.url(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("X-Vault-Token", p.token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to connect to Vault: %v", err)
+	}
+	return resp, nil
+}
+
+// vaultError converts unsuccessful response to an error including messages returned by Vault
+func vaultError(resp *http.Response) error {
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	body := struct {
+		Errors []string `json:"errors"`
+	}{}
+	content, _ := ioutil.ReadAll(resp.Body)
+	if err := json.Unmarshal(content, &body); err == nil && len(body.Errors) > 0 {
+		return fmt.Errorf("Vault returned %v: %v", resp.Status, strings.Join(body.Errors, ", "))
+	}
+	return fmt.Errorf("Vault returned %v", resp.Status)
+}
@@ -0,0 +1,141 @@
+package k8shandler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// newVaultStub returns server emulating KV version 2 secrets engine
+func newVaultStub(t *testing.T, token string) *httptest.Server {
+	secrets := make(map[string]map[string]string)
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != token {
+			w.WriteHeader(http.StatusForbidden)
+			w.Write([]byte(`{"errors":["permission denied"]}`))
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			data, ok := secrets[r.URL.Path]
+			if !ok {
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"errors":[]}`))
+				return
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"data": vaultSecret{Data: data}})
+		case http.MethodPost:
+			body := vaultSecret{}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				t.Errorf("Test failed, invalid request body: %v", err)
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+			secrets[r.URL.Path] = body.Data
+			w.Write([]byte(`{"data":{"version":1}}`))
+		default:
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		}
+	}))
+}
+
+func TestVaultProvider(t *testing.T) {
+	server := newVaultStub(t, "test-token")
+	defer server.Close()
+	provider := &vaultProvider{
+		address: server.URL,
+		token:   "test-token",
+		mount:   *vaultMount,
+		path:    "postgresql/test/cluster",
+		client:  server.Client(),
+	}
+	request := &PostgreSQLRequest{ctx: context.Background()}
+
+	if _, err := provider.read(request); err != errPasswordsNotFound {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", errPasswordsNotFound, err)
+	}
+	expected := &pgPasswords{database: "database-secret", repmgr: "repmgr-secret"}
+	if err := provider.write(request, expected); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	actual, err := provider.read(request)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if *actual != *expected {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", *expected, *actual)
+	}
+
+	provider.token = "invalid-token"
+	if _, err := provider.read(request); err == nil || err == errPasswordsNotFound {
+		t.Errorf("Test failed, expected permission error, got: '%v'", err)
+	}
+}
+
+func TestVaultProviderURL(t *testing.T) {
+	var urlTests = []struct {
+		address  string
+		mount    string
+		path     string
+		expected string
+	}{
+		{"http://vault:8200", "secret", "postgresql/ns/test", "http://vault:8200/v1/secret/data/postgresql/ns/test"},
+		{"http://vault:8200/", "/kv/", "/db/test/", "http://vault:8200/v1/kv/data/db/test"},
+	}
+	for _, tt := range urlTests {
+		provider := &vaultProvider{address: tt.address, mount: tt.mount, path: tt.path}
+		if actual := provider.url(); actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
+
+func TestNewVaultProvider(t *testing.T) {
+	for name, value := range map[string]string{"VAULT_ADDR": "http://vault:8200", "VAULT_TOKEN": "test-token"} {
+		defer os.Setenv(name, os.Getenv(name))
+		os.Setenv(name, value)
+	}
+	table := []struct {
+		vault    *postgresqlv1.PostgreSQLVaultSpec
+		expected string
+		valid    bool
+	}{
+		{nil, "secret/postgresql/ns/test", true},
+		{&postgresqlv1.PostgreSQLVaultSpec{Mount: "/secret/"}, "secret/postgresql/ns/test", true},
+		{&postgresqlv1.PostgreSQLVaultSpec{Path: "postgresql/ns/test/passwords"}, "secret/postgresql/ns/test/passwords", true},
+		{&postgresqlv1.PostgreSQLVaultSpec{Mount: "kv"}, "", false},
+		{&postgresqlv1.PostgreSQLVaultSpec{Path: "postgresql/other/test"}, "", false},
+		{&postgresqlv1.PostgreSQLVaultSpec{Path: "postgresql/ns/test-other"}, "", false},
+		{&postgresqlv1.PostgreSQLVaultSpec{Path: "postgresql/ns/test/../../other/test"}, "", false},
+	}
+	for _, tt := range table {
+		cluster := &postgresqlv1.PostgreSQL{
+			ObjectMeta: metav1.ObjectMeta{Name: "test", Namespace: "ns"},
+			Spec: postgresqlv1.PostgreSQLSpec{Credentials: &postgresqlv1.PostgreSQLCredentialsSpec{
+				Provider: postgresqlv1.CredentialsProviderVault,
+				Vault:    tt.vault,
+			}},
+		}
+		provider, err := newVaultProvider(cluster)
+		if !tt.valid {
+			if err == nil {
+				t.Errorf("Test %v failed, expected the location to be rejected", tt.vault)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.vault, err)
+			continue
+		}
+		if actual := provider.mount + "/" + provider.path; actual != tt.expected {
+			t.Errorf("Test failed, expected: '%v', got: '%v'", tt.expected, actual)
+		}
+	}
+}
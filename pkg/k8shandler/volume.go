@@ -1,7 +1,6 @@
 package k8shandler
 
 import (
-	"context"
 	"fmt"
 
 	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
@@ -13,8 +12,11 @@ import (
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
 )
 
-func newVolume(request *PostgreSQLRequest, name string, specVol *postgresqlv1.PostgreSQLStorageSpec) corev1.Volume {
+// newVolume returns data volume of the node, storage not set on the node is
+// taken from the template of the cluster
+func newVolume(request *PostgreSQLRequest, name string, nodeVol *postgresqlv1.PostgreSQLStorageSpec) corev1.Volume {
 	volSource := corev1.VolumeSource{}
+	specVol := mergeStorage(request.template(), nodeVol)
 
 	switch {
 	case specVol.StorageClassName != nil && specVol.Size != nil:
@@ -38,6 +40,7 @@ func newVolume(request *PostgreSQLRequest, name string, specVol *postgresqlv1.Po
 		err := createPersistentVolumeClaim(request, volSpec, claimName)
 		if err != nil {
 			logrus.Errorf("Unable to create PersistentVolumeClaim: %v", err)
+			request.recordWarning(PVCCreateFailed, "Failed to create PersistentVolumeClaim %v: %v", claimName, err)
 		}
 
 	case specVol.Size != nil:
@@ -58,7 +61,7 @@ func createPersistentVolumeClaim(request *PostgreSQLRequest, pvc corev1.Persiste
 
 	claim := newPersistentVolumeClaim(newName, request.cluster.Namespace, pvc)
 	controllerutil.SetControllerReference(request.cluster, claim, request.scheme)
-	if err := request.client.Create(context.TODO(), claim); err != nil {
+	if err := request.client.Create(request.ctx, claim); err != nil {
 		if !errors.IsAlreadyExists(err) {
 			return fmt.Errorf("Unable to create PVC: %v", err)
 		}
@@ -19,11 +19,18 @@ func TestNewVolume(t *testing.T) {
 	}
 	testRequest.cluster.Name = "test-cluster"
 
+	templateSize, _ := resource.ParseQuantity("1Gi")
+	template := &postgresqlv1.PostgreSQLNodeTemplate{
+		Storage: postgresqlv1.PostgreSQLStorageSpec{Size: &templateSize},
+	}
+
 	table := []struct {
+		template *postgresqlv1.PostgreSQLNodeTemplate
 		specVol  postgresqlv1.PostgreSQLStorageSpec
 		expected corev1.Volume
 	}{
 		{
+			nil,
 			postgresqlv1.PostgreSQLStorageSpec{
 				StorageClassName: nil,
 				Size:             nil,
@@ -35,6 +42,7 @@ func TestNewVolume(t *testing.T) {
 			},
 		},
 		{
+			nil,
 			postgresqlv1.PostgreSQLStorageSpec{
 				StorageClassName: nil,
 				Size:             &testSize,
@@ -47,8 +55,33 @@ func TestNewVolume(t *testing.T) {
 				},
 			},
 		},
+		{
+			template,
+			postgresqlv1.PostgreSQLStorageSpec{},
+			corev1.Volume{
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{
+						SizeLimit: &templateSize,
+					},
+				},
+			},
+		},
+		{
+			template,
+			postgresqlv1.PostgreSQLStorageSpec{
+				Size: &testSize,
+			},
+			corev1.Volume{
+				VolumeSource: corev1.VolumeSource{
+					EmptyDir: &corev1.EmptyDirVolumeSource{
+						SizeLimit: &testSize,
+					},
+				},
+			},
+		},
 	}
 	for _, tt := range table {
+		testRequest.cluster.Spec.Template = tt.template
 		actual := newVolume(&testRequest, "test", &tt.specVol)
 		eq := reflect.DeepEqual(actual.VolumeSource, tt.expected.VolumeSource)
 		if !eq {
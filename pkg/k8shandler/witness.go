@@ -0,0 +1,222 @@
+package k8shandler
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sirupsen/logrus"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+// witnessName returns name of the deployment and the service of the witness node
+func witnessName(clusterName string) string {
+	return fmt.Sprintf("%s-witness", clusterName)
+}
+
+// newWitnessLabels returns labels of the witness node, the witness doesn't
+// have the cluster-name label, so its pod is selected neither by the read-only
+// service nor by the scale selector and it's never attached as a data node
+func newWitnessLabels(clusterName string) map[string]string {
+	return map[string]string{
+		"witness-of": clusterName,
+		"node-name":  witnessName(clusterName),
+	}
+}
+
+// newWitnessDeployment returns Deployment of the witness node, the witness
+// keeps only repmgr metadata, so it has no data volume
+func newWitnessDeployment(request *PostgreSQLRequest, nodeID int) *appsv1.Deployment {
+	var single int32 = 1
+	name := witnessName(request.cluster.Name)
+	labels := newWitnessLabels(request.cluster.Name)
+	witness := request.cluster.Spec.Witness
+	// resources of the template are meant for data nodes, the witness falls back to operator defaults
+	resourceRequirements := newResourceRequirements(&postgresqlv1.PostgreSQLNodeTemplate{}, witness.Resources)
+	deployment := &appsv1.Deployment{
+		TypeMeta: metav1.TypeMeta{
+			Kind:       "Deployment",
+			APIVersion: appsv1.SchemeGroupVersion.String(),
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: request.cluster.Namespace,
+			Labels:    labels,
+		},
+		Spec: appsv1.DeploymentSpec{
+			Replicas: &single,
+			Selector: &metav1.LabelSelector{
+				MatchLabels: labels,
+			},
+			Strategy: appsv1.DeploymentStrategy{
+				Type: "Recreate",
+			},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{
+					Labels: labels,
+				},
+				Spec: corev1.PodSpec{
+					Hostname:   name,
+					Containers: []corev1.Container{newContainer(name, request.credentialsSecret(), newImage(request.template(), witness.Image), resourceRequirements, nodeID, WitnessRegister)},
+					Volumes: []corev1.Volume{{
+						Name:         name,
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					}},
+				},
+			},
+		},
+	}
+	controllerutil.SetControllerReference(request.cluster, deployment, request.scheme)
+	return deployment
+}
+
+// reconcileWitness creates or updates the witness node once the primary is
+// ready, as the witness registers to it, the witness removed from the spec is
+// deleted and unregistered
+func (request *PostgreSQLRequest) reconcileWitness(specNodes map[string]postgresqlv1.PostgreSQLNode, clusterStatus *postgresqlv1.PostgreSQLStatus) (bool, error) {
+	name := witnessName(request.cluster.Name)
+	witness := request.cluster.Spec.Witness
+	if witness == nil {
+		if clusterStatus.Witness == nil {
+			return false, nil
+		}
+		err := request.deleteWitness(clusterStatus)
+		return err != nil, err
+	}
+	if _, ok := specNodes[name]; ok {
+		return true, fmt.Errorf("Node %v conflicts with the witness node of the cluster", name)
+	}
+	if request.isReplica() {
+		logrus.Infof("Witness node %v is created once the replica cluster is promoted", name)
+		return false, nil
+	}
+	if primaryNode == nil || !primaryNode.isReady() {
+		logrus.Infof("Primary node is not ready, witness node %v postponed", name)
+		return true, nil
+	}
+	info, err := primaryNode.dbClient().getNodeInfo(request.ctx, name)
+	if err != nil {
+		info = &nodeInfo{-1, postgresqlv1.PostgreSQLNodeRoleUnknown, -1}
+	}
+
+	current := &appsv1.Deployment{}
+	err = request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, current)
+	switch {
+	case errors.IsNotFound(err):
+		if err := request.validateCredentialsSecret(); err != nil {
+			return true, fmt.Errorf("Witness node %v not created: %v", name, err)
+		}
+		id := info.id
+		if id < 0 {
+			id = request.nextNodeID()
+		}
+		current = newWitnessDeployment(request, id)
+		if err := request.client.Create(request.ctx, current); err != nil && !errors.IsAlreadyExists(err) {
+			return true, fmt.Errorf("Failed to create witness node %v: %v", name, err)
+		}
+		if clusterStatus.Witness != nil {
+			request.recordDrift("Deployment", name, "was deleted")
+		} else {
+			request.recordNormal(WitnessCreated, "Witness node %v created with id %v", name, id)
+		}
+	case err != nil:
+		return true, fmt.Errorf("Failed to get witness node %v: %v", name, err)
+	default:
+		var single int32 = 1
+		container := &current.Spec.Template.Spec.Containers[0]
+		container.Image = newImage(request.template(), witness.Image)
+		container.Resources = newResourceRequirements(&postgresqlv1.PostgreSQLNodeTemplate{}, witness.Resources)
+		secret := request.credentialsSecret()
+		setContainerEnvVar(container, newSecretEnvVar("POSTGRESQL_PASSWORD", secret.name, secret.databaseKey))
+		setContainerEnvVar(container, newSecretEnvVar("REPMGR_PASSWORD", secret.name, secret.repmgrKey))
+		// the witness is scaled down during hibernation
+		current.Spec.Replicas = &single
+		if err := request.client.Update(request.ctx, current); err != nil {
+			return true, fmt.Errorf("Failed to update witness node %v: %v", name, err)
+		}
+	}
+	if err := request.CreateOrUpdateService(name, name); err != nil {
+		return true, fmt.Errorf("Failed to create service of witness node %v: %v", name, err)
+	}
+
+	status := &postgresqlv1.PostgreSQLWitnessStatus{
+		DeploymentName: name,
+		ServiceName:    name,
+		Ready:          current.Status.ReadyReplicas == 1,
+		Registered:     info.id > 0 && info.role == postgresqlv1.PostgreSQLNodeRoleWitness,
+	}
+	if status.Registered && (clusterStatus.Witness == nil || !clusterStatus.Witness.Registered) {
+		request.recordNormal(WitnessRegistered, "Witness node %v registered with id %v", name, info.id)
+	}
+	clusterStatus.Witness = status
+	return !status.Ready || !status.Registered, nil
+}
+
+// restartWitness recreates pod of the witness node, the witness keeps no data,
+// so it initializes its database with passwords from the secret again
+func (request *PostgreSQLRequest) restartWitness() error {
+	if request.cluster.Spec.Witness == nil {
+		return nil
+	}
+	name := witnessName(request.cluster.Name)
+	current := &appsv1.Deployment{}
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		if err := request.client.Get(request.ctx, types.NamespacedName{Name: name, Namespace: request.cluster.Namespace}, current); err != nil {
+			if errors.IsNotFound(err) {
+				return nil
+			}
+			return err
+		}
+		if current.Spec.Template.ObjectMeta.Annotations == nil {
+			current.Spec.Template.ObjectMeta.Annotations = make(map[string]string)
+		}
+		current.Spec.Template.ObjectMeta.Annotations[restartedAtAnnotation] = time.Now().Format(time.RFC3339)
+		return request.client.Update(request.ctx, current)
+	})
+}
+
+// nextNodeID returns id higher than ids of all nodes registered in repmgr,
+// the sequence starts from zero when the operator restarts
+func (request *PostgreSQLRequest) nextNodeID() int {
+	db := primaryNode.dbClient()
+	registered, err := db.registeredNodes(request.ctx)
+	if err != nil {
+		logrus.Errorf("Failed to list nodes registered in repmgr: %v", err)
+	}
+	for _, name := range registered {
+		if info, err := db.getNodeInfo(request.ctx, name); err == nil && info.id > idSequence {
+			idSequence = info.id
+		}
+	}
+	idSequence++
+	return idSequence
+}
+
+// deleteWitness deletes the witness node and drops its repmgr record, the
+// status is kept until the record is dropped
+func (request *PostgreSQLRequest) deleteWitness(clusterStatus *postgresqlv1.PostgreSQLStatus) error {
+	name := witnessName(request.cluster.Name)
+	meta := metav1.ObjectMeta{Name: name, Namespace: request.cluster.Namespace}
+	for _, obj := range []runtime.Object{&appsv1.Deployment{ObjectMeta: meta}, &corev1.Service{ObjectMeta: meta}} {
+		if err := request.client.Delete(request.ctx, obj); err != nil && !errors.IsNotFound(err) {
+			return fmt.Errorf("Failed to delete witness node %v: %v", name, err)
+		}
+	}
+	if primaryNode == nil || !primaryNode.isReady() {
+		return fmt.Errorf("Primary node is not ready, witness node %v can't be unregistered", name)
+	}
+	if err := primaryNode.dbClient().unregisterNode(request.ctx, name); err != nil {
+		return fmt.Errorf("Failed to unregister witness node %v: %v", name, err)
+	}
+	clusterStatus.Witness = nil
+	request.recordNormal(WitnessDeleted, "Witness node %v deleted", name)
+	return nil
+}
@@ -0,0 +1,147 @@
+package k8shandler
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+)
+
+func TestReconcileWitness(t *testing.T) {
+	defer func(c connector) { connections = c }(connections)
+	witness := types.NamespacedName{Name: "example-witness", Namespace: "default"}
+
+	table := []struct {
+		name    string
+		witness *postgresqlv1.PostgreSQLWitnessSpec
+		// deployed creates ready witness registered with the given id
+		deployed int
+		verify   func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error
+	}{
+		{
+			name:    "witness created",
+			witness: &postgresqlv1.PostgreSQLWitnessSpec{Image: "postgresql:witness"},
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				deployment := &appsv1.Deployment{}
+				if err := request.client.Get(request.ctx, witness, deployment); err != nil {
+					return err
+				}
+				if value := containerEnv(deployment, "STARTUP_OPERATION"); value != WitnessRegister {
+					return fmt.Errorf("expected STARTUP_OPERATION: '%v', got: '%v'", WitnessRegister, value)
+				}
+				if image := deployment.Spec.Template.Spec.Containers[0].Image; image != "postgresql:witness" {
+					return fmt.Errorf("expected image: 'postgresql:witness', got: '%v'", image)
+				}
+				if volume := deployment.Spec.Template.Spec.Volumes[0]; volume.PersistentVolumeClaim != nil {
+					return fmt.Errorf("expected no data volume, got claim: '%v'", volume.PersistentVolumeClaim.ClaimName)
+				}
+				if _, ok := deployment.Labels["cluster-name"]; ok {
+					return fmt.Errorf("witness must not be selected by services of data nodes")
+				}
+				service := &corev1.Service{}
+				if err := request.client.Get(request.ctx, witness, service); err != nil {
+					return err
+				}
+				if !reflect.DeepEqual(service.Spec.Selector, deployment.Spec.Template.Labels) {
+					return fmt.Errorf("expected selector: '%v', got: '%v'", deployment.Spec.Template.Labels, service.Spec.Selector)
+				}
+				status := request.cluster.Status.Witness
+				if status == nil || status.Ready || status.Registered {
+					return fmt.Errorf("expected witness not ready, got: '%v'", status)
+				}
+				if !containsString(reasons, WitnessCreated) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", WitnessCreated, reasons)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "witness registered",
+			witness:  &postgresqlv1.PostgreSQLWitnessSpec{},
+			deployed: 3,
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				status := request.cluster.Status.Witness
+				if status == nil || !status.Ready || !status.Registered {
+					return fmt.Errorf("expected witness ready and registered, got: '%v'", status)
+				}
+				if _, ok := repmgr.records["example-witness"]; !ok {
+					return fmt.Errorf("witness was unregistered as a removed node")
+				}
+				if _, ok := request.cluster.Status.Nodes["example-witness"]; ok {
+					return fmt.Errorf("witness reported as a data node")
+				}
+				if instances := request.cluster.Status.Instances; instances != 2 {
+					return fmt.Errorf("expected instances: '2', got: '%v'", instances)
+				}
+				return nil
+			},
+		},
+		{
+			name:     "witness removed",
+			witness:  nil,
+			deployed: 3,
+			verify: func(request *PostgreSQLRequest, repmgr *fakeRepmgr, reasons []string) error {
+				err := request.client.Get(request.ctx, witness, &appsv1.Deployment{})
+				if !errors.IsNotFound(err) {
+					return fmt.Errorf("expected witness deployment deleted, got: '%v'", err)
+				}
+				if _, ok := repmgr.records["example-witness"]; ok {
+					return fmt.Errorf("witness was not unregistered")
+				}
+				if status := request.cluster.Status.Witness; status != nil {
+					return fmt.Errorf("expected no witness status, got: '%v'", status)
+				}
+				if !containsString(reasons, WitnessDeleted) {
+					return fmt.Errorf("expected event: '%v', got: '%v'", WitnessDeleted, reasons)
+				}
+				return nil
+			},
+		},
+	}
+	for _, tt := range table {
+		nodes = nil
+		primaryNode = nil
+		idSequence = 0
+		repmgr := newFakeRepmgr()
+		repmgr.AddNode("node-one", 1, postgresqlv1.PostgreSQLNodeRolePrimary, 100)
+		repmgr.AddNode("node-two", 2, postgresqlv1.PostgreSQLNodeRoleStandby, 80)
+		connections = &fakeConnector{repmgr: repmgr}
+
+		request, recorder := newTestRequest(t, map[string]int{"node-one": 100, "node-two": 80}, map[string]int{"node-one": 1, "node-two": 2})
+		if tt.deployed > 0 {
+			repmgr.Register("example-witness", tt.deployed, postgresqlv1.PostgreSQLNodeRoleWitness, 0)
+			request.cluster.Spec.Witness = &postgresqlv1.PostgreSQLWitnessSpec{}
+			deployment := newWitnessDeployment(request, tt.deployed)
+			deployment.Status.ReadyReplicas = 1
+			if err := request.client.Create(request.ctx, deployment); err != nil {
+				t.Fatalf("Test failed, unexpected error: %v", err)
+			}
+			request.cluster.Status.Witness = &postgresqlv1.PostgreSQLWitnessStatus{
+				DeploymentName: witness.Name,
+				ServiceName:    witness.Name,
+				Ready:          true,
+				Registered:     true,
+			}
+		}
+		request.cluster.Spec.Witness = tt.witness
+		if err := request.client.Update(request.ctx, request.cluster); err != nil {
+			t.Fatalf("Test failed, unexpected error: %v", err)
+		}
+		if _, err := request.CreateOrUpdateCluster(); err != nil {
+			t.Errorf("Test %v failed, unexpected error: %v", tt.name, err)
+			continue
+		}
+		if primary := primaryNode.name(); primary != "node-one" {
+			t.Errorf("Test %v failed, expected: '%v', got: '%v'", tt.name, "node-one", primary)
+		}
+		if err := tt.verify(request, repmgr, recordedReasons(recorder)); err != nil {
+			t.Errorf("Test %v failed, %v", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package webhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	logf "sigs.k8s.io/controller-runtime/pkg/runtime/log"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+	postgresqlv2 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v2"
+)
+
+var log = logf.Log.WithName("webhook")
+
+// ConversionPath is the path the conversion webhook is served at
+const ConversionPath = "/convert"
+
+// conversionHandler converts PostgreSQL resources between versions of the API
+// as requested by the API server
+type conversionHandler struct{}
+
+func (h *conversionHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	review := &apiextensionsv1beta1.ConversionReview{}
+	if err := json.NewDecoder(r.Body).Decode(review); err != nil || review.Request == nil {
+		http.Error(w, fmt.Sprintf("Invalid conversion review: %v", err), http.StatusBadRequest)
+		return
+	}
+	review.Response = convertReview(review.Request)
+	review.Request = nil
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(review); err != nil {
+		log.Error(err, "Failed to write conversion response")
+	}
+}
+
+// convertReview converts all objects of the request, the conversion fails as a whole
+func convertReview(request *apiextensionsv1beta1.ConversionRequest) *apiextensionsv1beta1.ConversionResponse {
+	response := &apiextensionsv1beta1.ConversionResponse{UID: request.UID}
+	for _, obj := range request.Objects {
+		converted, err := convert(obj.Raw, request.DesiredAPIVersion)
+		if err != nil {
+			log.Error(err, "Failed to convert object", "desiredAPIVersion", request.DesiredAPIVersion)
+			response.ConvertedObjects = nil
+			response.Result = metav1.Status{Status: metav1.StatusFailure, Message: err.Error()}
+			return response
+		}
+		response.ConvertedObjects = append(response.ConvertedObjects, runtime.RawExtension{Raw: converted})
+	}
+	response.Result = metav1.Status{Status: metav1.StatusSuccess}
+	return response
+}
+
+// convert converts PostgreSQL resource encoded in JSON to the desired version,
+// v1 is used as the hub all other versions are converted through
+func convert(raw []byte, desiredAPIVersion string) ([]byte, error) {
+	typeMeta := metav1.TypeMeta{}
+	if err := json.Unmarshal(raw, &typeMeta); err != nil {
+		return nil, fmt.Errorf("Failed to decode object: %v", err)
+	}
+	if typeMeta.Kind != "PostgreSQL" {
+		return nil, fmt.Errorf("Unexpected kind %v", typeMeta.Kind)
+	}
+	if typeMeta.APIVersion == desiredAPIVersion {
+		return raw, nil
+	}
+
+	hub := &postgresqlv1.PostgreSQL{}
+	switch typeMeta.APIVersion {
+	case postgresqlv1.SchemeGroupVersion.String():
+		if err := json.Unmarshal(raw, hub); err != nil {
+			return nil, fmt.Errorf("Failed to decode object: %v", err)
+		}
+	case postgresqlv2.SchemeGroupVersion.String():
+		src := &postgresqlv2.PostgreSQL{}
+		if err := json.Unmarshal(raw, src); err != nil {
+			return nil, fmt.Errorf("Failed to decode object: %v", err)
+		}
+		if err := src.ConvertTo(hub); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("Unsupported version %v", typeMeta.APIVersion)
+	}
+
+	var dst runtime.Object
+	switch desiredAPIVersion {
+	case postgresqlv1.SchemeGroupVersion.String():
+		dst = hub
+	case postgresqlv2.SchemeGroupVersion.String():
+		converted := &postgresqlv2.PostgreSQL{}
+		if err := converted.ConvertFrom(hub); err != nil {
+			return nil, err
+		}
+		dst = converted
+	default:
+		return nil, fmt.Errorf("Unsupported version %v", desiredAPIVersion)
+	}
+	dst.GetObjectKind().SetGroupVersionKind(schema.FromAPIVersionAndKind(desiredAPIVersion, typeMeta.Kind))
+	return json.Marshal(dst)
+}
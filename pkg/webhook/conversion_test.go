@@ -0,0 +1,98 @@
+package webhook
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	apiextensionsv1beta1 "k8s.io/apiextensions-apiserver/pkg/apis/apiextensions/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+
+	postgresqlv1 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v1"
+	postgresqlv2 "github.com/mcyprian/postgresql-operator/pkg/apis/postgresql/v2"
+)
+
+func newReview(t *testing.T, desiredAPIVersion string, obj runtime.Object) *apiextensionsv1beta1.ConversionReview {
+	raw, err := json.Marshal(obj)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	return &apiextensionsv1beta1.ConversionReview{
+		Request: &apiextensionsv1beta1.ConversionRequest{
+			UID:               "test-uid",
+			DesiredAPIVersion: desiredAPIVersion,
+			Objects:           []runtime.RawExtension{{Raw: raw}},
+		},
+	}
+}
+
+func sendReview(t *testing.T, review *apiextensionsv1beta1.ConversionReview) *apiextensionsv1beta1.ConversionResponse {
+	body, err := json.Marshal(review)
+	if err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	recorder := httptest.NewRecorder()
+	(&conversionHandler{}).ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, ConversionPath, bytes.NewReader(body)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("Test failed, expected: '%v', got: '%v'", http.StatusOK, recorder.Code)
+	}
+	result := &apiextensionsv1beta1.ConversionReview{}
+	if err := json.NewDecoder(recorder.Body).Decode(result); err != nil || result.Response == nil {
+		t.Fatalf("Test failed, invalid response: %v", err)
+	}
+	if result.Response.UID != "test-uid" {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", "test-uid", result.Response.UID)
+	}
+	return result.Response
+}
+
+func TestConversionWebhook(t *testing.T) {
+	cluster := &postgresqlv2.PostgreSQL{
+		TypeMeta:   metav1.TypeMeta{APIVersion: postgresqlv2.SchemeGroupVersion.String(), Kind: "PostgreSQL"},
+		ObjectMeta: metav1.ObjectMeta{Name: "example", Namespace: "default"},
+		Spec: postgresqlv2.PostgreSQLSpec{
+			ManagementState: postgresqlv2.ManagementStateManaged,
+			Template:        &postgresqlv2.PostgreSQLNodeTemplate{Image: "postgresql:10"},
+			Nodes:           []postgresqlv2.PostgreSQLNode{{Name: "node-one", Priority: 100}},
+		},
+	}
+	response := sendReview(t, newReview(t, postgresqlv1.SchemeGroupVersion.String(), cluster))
+	if response.Result.Status != metav1.StatusSuccess || len(response.ConvertedObjects) != 1 {
+		t.Fatalf("Test failed, unexpected result: %v", response.Result)
+	}
+	stored := &postgresqlv1.PostgreSQL{}
+	if err := json.Unmarshal(response.ConvertedObjects[0].Raw, stored); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if stored.APIVersion != postgresqlv1.SchemeGroupVersion.String() {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", postgresqlv1.SchemeGroupVersion.String(), stored.APIVersion)
+	}
+	if stored.Spec.Template == nil || stored.Spec.Template.Image != "postgresql:10" {
+		t.Errorf("Test failed, expected: '%v', got: '%v'", "postgresql:10", stored.Spec.Template)
+	}
+
+	response = sendReview(t, newReview(t, postgresqlv2.SchemeGroupVersion.String(), stored))
+	if response.Result.Status != metav1.StatusSuccess || len(response.ConvertedObjects) != 1 {
+		t.Fatalf("Test failed, unexpected result: %v", response.Result)
+	}
+	actual := &postgresqlv2.PostgreSQL{}
+	if err := json.Unmarshal(response.ConvertedObjects[0].Raw, actual); err != nil {
+		t.Fatalf("Test failed, unexpected error: %v", err)
+	}
+	if actual.Spec.Template == nil || actual.Spec.Template.Image != "postgresql:10" || actual.Spec.Nodes[0].Image != "" {
+		t.Errorf("Test failed, template was not converted: %v", actual.Spec)
+	}
+}
+
+func TestConversionWebhookUnsupportedVersion(t *testing.T) {
+	cluster := &postgresqlv1.PostgreSQL{
+		TypeMeta: metav1.TypeMeta{APIVersion: postgresqlv1.SchemeGroupVersion.String(), Kind: "PostgreSQL"},
+	}
+	response := sendReview(t, newReview(t, "postgresql.openshift.io/v3", cluster))
+	if response.Result.Status != metav1.StatusFailure || len(response.ConvertedObjects) != 0 {
+		t.Errorf("Test failed, expected failure, got: '%v'", response.Result)
+	}
+}
@@ -0,0 +1,52 @@
+package webhook
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"time"
+)
+
+const shutdownTimeout = 10 * time.Second
+
+// Server serves webhooks of the operator over TLS, the certificate is read
+// from tls.crt and tls.key files in CertDir on every handshake, so rotated
+// certificates are used without restart
+type Server struct {
+	Port    int
+	CertDir string
+}
+
+// Start runs the server until the stop channel is closed
+func (s *Server) Start(stop <-chan struct{}) error {
+	mux := http.NewServeMux()
+	mux.Handle(ConversionPath, &conversionHandler{})
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", s.Port),
+		Handler: mux,
+		TLSConfig: &tls.Config{
+			GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+				cert, err := tls.LoadX509KeyPair(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+				if err != nil {
+					return nil, fmt.Errorf("Failed to load webhook certificate: %v", err)
+				}
+				return &cert, nil
+			},
+		},
+	}
+	errs := make(chan error, 1)
+	go func() {
+		log.Info("Serving webhooks", "port", s.Port)
+		errs <- server.ListenAndServeTLS("", "")
+	}()
+	select {
+	case <-stop:
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		return server.Shutdown(ctx)
+	case err := <-errs:
+		return err
+	}
+}